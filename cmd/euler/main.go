@@ -0,0 +1,169 @@
+// Команда euler запускает проверочные расчёты для уравнений Эйлера:
+//
+//	sod  — задача Сода о распаде разрыва, сравнение плотности
+//	       с точным решением задачи Римана при t = 0.2;
+//	step — течение с числом Маха 3 в канале со ступенькой
+//	       (Woodward, Colella, 1984).
+//
+//	go run ./cmd/euler -case sod -flux hllc -nx 400
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"test.com/utils"
+)
+
+const gamma = 1.4
+
+func main() {
+	name := flag.String("case", "sod", "проверочный расчёт: sod или step")
+	flux := flag.String("flux", "hllc", "численный поток: rusanov, roe или hllc")
+	order := flag.Int("order", 2, "порядок реконструкции: 1 или 2")
+	limiter := flag.String("limiter", "bj", "ограничитель градиента: bj (Барт–Джесперсен) или venkat (Венкатакришнан)")
+	nx := flag.Int("nx", 200, "число ячеек по x")
+	tEnd := flag.Float64("t", 0, "время окончания расчёта (0 — по умолчанию для расчёта)")
+	flag.Parse()
+
+	gas := utils.Gas{Gamma: gamma, Order: *order}
+	switch *flux {
+	case "rusanov":
+		gas.Flux = utils.Rusanov
+	case "roe":
+		gas.Flux = utils.Roe
+	case "hllc":
+		gas.Flux = utils.HLLC
+	default:
+		fmt.Fprintln(os.Stderr, "error", fmt.Errorf("неизвестный поток %q", *flux))
+		os.Exit(1)
+	}
+	switch *limiter {
+	case "bj":
+		gas.Limiter = utils.BarthJespersen
+	case "venkat":
+		gas.Limiter = utils.Venkatakrishnan
+	default:
+		fmt.Fprintln(os.Stderr, "error", fmt.Errorf("неизвестный ограничитель %q", *limiter))
+		os.Exit(1)
+	}
+
+	var err error
+	switch *name {
+	case "sod":
+		if *tEnd == 0 {
+			*tEnd = 0.2
+		}
+		err = sod(gas, *nx, *tEnd)
+	case "step":
+		if *tEnd == 0 {
+			*tEnd = 4.0
+		}
+		err = step(gas, *nx, *tEnd)
+	default:
+		err = fmt.Errorf("неизвестный расчёт %q", *name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error", err)
+		os.Exit(1)
+	}
+}
+
+func sod(gas utils.Gas, nx int, tEnd float64) error {
+	grid, err := utils.Rect_grid(0, 0, 1, 0.1, nx, 2, nil)
+	if err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("ends", func(p utils.Point) bool { return p.X < 1e-9 || p.X > 1-1e-9 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("walls", func(p utils.Point) bool { return true }); err != nil {
+		return err
+	}
+	left := utils.GasState{Rho: 1.0, P: 1.0}
+	right := utils.GasState{Rho: 0.125, P: 0.1}
+
+	var es utils.EulerSolver
+	es.Set_grid(grid)
+	if err := es.Set_gas(gas); err != nil {
+		return err
+	}
+	es.Set_bc("ends", utils.GasBC{Type: utils.SupersonicOutflow})
+	es.Set_bc("walls", utils.GasBC{Type: utils.SlipWall})
+	es.Set_initial(func(p utils.Point) utils.GasState {
+		if p.X < 0.5 {
+			return left
+		}
+		return right
+	})
+	steps, err := es.Advance(tEnd)
+	if err != nil {
+		return err
+	}
+
+	exact := utils.Riemann_exact(left, right, gamma)
+	l1 := 0.0
+	volume := 0.0
+	for i, c := range grid.Cell_centers {
+		v := math.Abs(grid.Cell_volumes[i])
+		l1 += math.Abs(es.State(i).Rho-exact((c.X-0.5)/tEnd).Rho) * v
+		volume += v
+	}
+	fmt.Printf("Sod shock tube, %d cells, t = %g: %d steps\n", len(grid.Cells), es.Time(), steps)
+	fmt.Printf("%8s %10s %10s\n", "x", "rho", "exact")
+	for i, c := range grid.Cell_centers {
+		if c.Y < 0.05 && i%(nx/20) == 0 {
+			fmt.Printf("%8.4f %10.5f %10.5f\n", c.X, es.State(i).Rho, exact((c.X-0.5)/tEnd).Rho)
+		}
+	}
+	fmt.Printf("L1(rho - rho_exact) = %.4e\n", l1/volume)
+	return nil
+}
+
+func step(gas utils.Gas, nx int, tEnd float64) error {
+	ny := nx / 3
+	grid, err := utils.Rect_grid(0, 0, 3, 1, nx, ny, func(c utils.Point) bool {
+		return c.X < 0.6 || c.Y > 0.2
+	})
+	if err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("inlet", func(p utils.Point) bool { return p.X < 1e-9 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("outlet", func(p utils.Point) bool { return p.X > 3-1e-9 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("walls", func(p utils.Point) bool { return true }); err != nil {
+		return err
+	}
+	inflow := utils.GasState{Rho: gamma, U: 3.0, P: 1.0}
+
+	var es utils.EulerSolver
+	es.Set_grid(grid)
+	if err := es.Set_gas(gas); err != nil {
+		return err
+	}
+	es.Set_bc("inlet", utils.GasBC{Type: utils.SupersonicInflow, State: inflow})
+	es.Set_bc("outlet", utils.GasBC{Type: utils.SupersonicOutflow})
+	es.Set_bc("walls", utils.GasBC{Type: utils.SlipWall})
+	es.Set_initial(func(p utils.Point) utils.GasState { return inflow })
+	steps, err := es.Advance(tEnd)
+	if err != nil {
+		return err
+	}
+
+	rhoMin, rhoMax := math.Inf(1), math.Inf(-1)
+	pMax := math.Inf(-1)
+	for i := range grid.Cells {
+		w := es.State(i)
+		rhoMin = math.Min(rhoMin, w.Rho)
+		rhoMax = math.Max(rhoMax, w.Rho)
+		pMax = math.Max(pMax, w.P)
+	}
+	fmt.Printf("Mach 3 forward-facing step, %d cells, t = %g: %d steps\n", len(grid.Cells), es.Time(), steps)
+	fmt.Printf("rho in [%.4f, %.4f], max p = %.4f\n", rhoMin, rhoMax, pMax)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Команда flow запускает проверочные расчёты течения несжимаемой жидкости:
+//
+//	cavity  — каверна с движущейся крышкой при Re = 100, сравнение профиля
+//	          скорости u на вертикальной оси с данными Ghia, Ghia, Shin (1982);
+//	channel — течение Пуазейля в канале, сравнение профиля скорости
+//	          и перепада давления с точным решением.
+//
+//	go run ./cmd/flow -case cavity -mesh test_data/tetragrid_10000.vtk
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"test.com/utils"
+)
+
+// Профиль u(0.5, y) для Re = 100 из работы Ghia, Ghia, Shin (1982)
+var ghia_y = []float64{0.9766, 0.9688, 0.9609, 0.9531, 0.8516, 0.7344, 0.6172, 0.5,
+	0.4531, 0.2813, 0.1719, 0.1016, 0.0703, 0.0625, 0.0547}
+var ghia_u = []float64{0.84123, 0.78871, 0.73722, 0.68717, 0.23151, 0.00332, -0.13641, -0.20581,
+	-0.2109, -0.15662, -0.1015, -0.06434, -0.04775, -0.04192, -0.03717}
+
+func main() {
+	name := flag.String("case", "cavity", "проверочный расчёт: cavity или channel")
+	mesh := flag.String("mesh", "test_data/tetragrid_2000.vtk", "сетка в единичном квадрате")
+	algorithm := flag.String("algorithm", "simplec", "simple или simplec")
+	maxIter := flag.Int("iter", 3000, "максимальное число итераций")
+	flag.Parse()
+
+	grid, err := utils.Grid(*mesh)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error", err)
+		os.Exit(1)
+	}
+	grid.Need_cell_centers()
+	flow := utils.Flow{Viscosity: 0.01, Max_iter: *maxIter}
+	if *algorithm == "simple" {
+		flow.Algorithm = utils.SIMPLE
+	} else {
+		flow.Algorithm = utils.SIMPLEC
+	}
+
+	switch *name {
+	case "cavity":
+		err = cavity(grid, flow)
+	case "channel":
+		err = channel(grid, flow)
+	default:
+		err = fmt.Errorf("неизвестный расчёт %q", *name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error", err)
+		os.Exit(1)
+	}
+}
+
+func cavity(grid utils.VTKGrid, flow utils.Flow) error {
+	if err := grid.Add_bnd_patch("lid", func(p utils.Point) bool { return p.Y > 1-1e-6 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("walls", func(p utils.Point) bool { return true }); err != nil {
+		return err
+	}
+	var fs utils.FlowSolver
+	fs.Set_grid(grid)
+	if err := fs.Set_flow(flow); err != nil {
+		return err
+	}
+	if err := fs.Set_bc("lid", utils.FlowBC{Type: utils.Wall, Velocity: func(p utils.Point) utils.Point {
+		return utils.Point{X: 1.0}
+	}}); err != nil {
+		return err
+	}
+	if err := fs.Set_bc("walls", utils.FlowBC{Type: utils.Wall}); err != nil {
+		return err
+	}
+	iters, err := fs.Solve()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("lid-driven cavity, Re = 100: %d iterations\n", iters)
+	fmt.Printf("%8s %10s %10s %10s\n", "y", "u", "Ghia", "diff")
+	maxDiff := 0.0
+	for i, y := range ghia_y {
+		vel, _, err := fs.Probe(utils.Point{X: 0.5, Y: y})
+		if err != nil {
+			return err
+		}
+		diff := vel.X - ghia_u[i]
+		maxDiff = math.Max(maxDiff, math.Abs(diff))
+		fmt.Printf("%8.4f %10.5f %10.5f %10.5f\n", y, vel.X, ghia_u[i], diff)
+	}
+	fmt.Printf("max |u - u_Ghia| = %.4e\n", maxDiff)
+	return nil
+}
+
+func channel(grid utils.VTKGrid, flow utils.Flow) error {
+	if err := grid.Add_bnd_patch("inlet", func(p utils.Point) bool { return p.X < 1e-6 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("outlet", func(p utils.Point) bool { return p.X > 1-1e-6 }); err != nil {
+		return err
+	}
+	if err := grid.Add_bnd_patch("walls", func(p utils.Point) bool { return true }); err != nil {
+		return err
+	}
+	// Течение Пуазейля со средней скоростью 1 в канале высоты 1
+	exact := func(y float64) float64 { return 6.0 * y * (1.0 - y) }
+	var fs utils.FlowSolver
+	fs.Set_grid(grid)
+	if err := fs.Set_flow(flow); err != nil {
+		return err
+	}
+	if err := fs.Set_bc("inlet", utils.FlowBC{Type: utils.Inlet, Velocity: func(p utils.Point) utils.Point {
+		return utils.Point{X: exact(p.Y)}
+	}}); err != nil {
+		return err
+	}
+	if err := fs.Set_bc("outlet", utils.FlowBC{Type: utils.Outlet}); err != nil {
+		return err
+	}
+	if err := fs.Set_bc("walls", utils.FlowBC{Type: utils.Wall}); err != nil {
+		return err
+	}
+	iters, err := fs.Solve()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Poiseuille channel flow, Re = 100: %d iterations\n", iters)
+	fmt.Printf("%8s %10s %10s %10s\n", "y", "u", "exact", "diff")
+	maxDiff := 0.0
+	for y := 0.1; y < 0.95; y += 0.1 {
+		vel, _, err := fs.Probe(utils.Point{X: 0.75, Y: y})
+		if err != nil {
+			return err
+		}
+		diff := vel.X - exact(y)
+		maxDiff = math.Max(maxDiff, math.Abs(diff))
+		fmt.Printf("%8.4f %10.5f %10.5f %10.5f\n", y, vel.X, exact(y), diff)
+	}
+	fmt.Printf("max |u - u_exact| = %.4e\n", maxDiff)
+	_, p1, err := fs.Probe(utils.Point{X: 0.25, Y: 0.5})
+	if err != nil {
+		return err
+	}
+	_, p2, err := fs.Probe(utils.Point{X: 0.75, Y: 0.5})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("dp/dx = %.5f, exact %.5f\n", (p2-p1)/0.5, -12.0*flow.Viscosity)
+	return nil
+}
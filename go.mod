@@ -1,3 +1,3 @@
-module test.com
-
-go 1.18
+module test.com
+
+go 1.18
@@ -0,0 +1,560 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// Smoother selects the relaxation of AMG
+type Smoother int
+
+const (
+	GaussSeidelSmoother Smoother = iota // Forward sweeps before, backward sweeps after the coarse correction
+	JacobiSmoother                      // Damped Jacobi
+)
+
+// CycleType selects how often the coarse levels are visited
+type CycleType int
+
+const (
+	VCycle CycleType = iota
+	WCycle
+)
+
+// AMG is a smoothed aggregation algebraic multigrid. It is a Preconditioner
+// (one cycle per Apply) and a LinearSolver (cycles until convergence).
+// Zero values of the parameters select the defaults.
+type AMG struct {
+	Theta      float64   // Strength threshold: |a_ij| >= Theta sqrt(|a_ii a_jj|), 0.08 by default
+	Smoother   Smoother  // Gauss-Seidel by default
+	Omega      float64   // Damping of the Jacobi smoother, 2/3 by default
+	Sweeps     int       // Pre- and post-smoothing sweeps, 1 by default
+	Cycle      CycleType // V-cycle by default
+	CoarseSize int       // Levels of at most this size are solved directly, 300 by default
+	MaxLevels  int       // 20 by default
+
+	// Used only by SolveFrom and Solve
+	MaxIter   int
+	Tolerance float64
+	Relative  bool // Compare ||r|| / ||b|| instead of ||r|| with Tolerance
+
+	levels []amgLevel
+	lu     []float64 // LU factors of the coarsest matrix, nil if it is smoothed instead
+	pivot  []int
+}
+
+// amgLevel holds the matrix of one level and the transfer operators
+// to the next coarser one
+type amgLevel struct {
+	A    *matrix.CSRMatrix
+	P, R *matrix.CSRMatrix // Prolongation and restriction, nil on the coarsest level
+	diag []float64
+
+	x, b, r []float64 // Work vectors
+}
+
+func init() {
+	Register("amg", func(opts Options) (LinearSolver, error) {
+		maxIter, tol, _, err := opts.krylov()
+		if err != nil {
+			return nil, err
+		}
+		amg := NewAMG()
+		amg.MaxIter, amg.Tolerance, amg.Relative = maxIter, tol, true
+		return amg, nil
+	})
+}
+
+// NewAMG creates a smoothed aggregation AMG with default parameters
+func NewAMG() *AMG {
+	return &AMG{}
+}
+
+// Setup builds the hierarchy of coarse levels for A
+func (amg *AMG) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	if A.Rows == 0 {
+		return fmt.Errorf("matrix is empty")
+	}
+	theta := amg.Theta
+	if theta <= 0 {
+		theta = 0.08
+	}
+	coarseSize := amg.CoarseSize
+	if coarseSize <= 0 {
+		coarseSize = 300
+	}
+	maxLevels := amg.MaxLevels
+	if maxLevels <= 0 {
+		maxLevels = 20
+	}
+
+	amg.levels = amg.levels[:0]
+	a := A
+	for {
+		diag, err := diagonal(a)
+		if err != nil {
+			return fmt.Errorf("level %d: %v", len(amg.levels), err)
+		}
+		lvl := amgLevel{A: a, diag: diag, r: make([]float64, a.Rows)}
+		if len(amg.levels) > 0 {
+			lvl.x, lvl.b = make([]float64, a.Rows), make([]float64, a.Rows)
+		}
+		if a.Rows <= coarseSize || len(amg.levels)+1 >= maxLevels {
+			amg.levels = append(amg.levels, lvl)
+			break
+		}
+		agg, count := aggregate(a, diag, theta)
+		if count*10 > a.Rows*9 {
+			// Coarsening stalled, e.g. no strong connections left
+			amg.levels = append(amg.levels, lvl)
+			break
+		}
+		lvl.P = prolongation(a, diag, agg, count, theta)
+		lvl.R = transpose(lvl.P)
+		amg.levels = append(amg.levels, lvl)
+		a = multiply(lvl.R, multiply(a, lvl.P))
+	}
+
+	amg.lu, amg.pivot = nil, nil
+	if last := amg.levels[len(amg.levels)-1].A; last.Rows <= coarseSize {
+		// A coarsest level left larger by MaxLevels or stalled coarsening
+		// is smoothed, as is a singular one, e.g. of a pure Neumann problem
+		amg.lu, amg.pivot = factorize(last)
+	}
+	return nil
+}
+
+// Levels returns the number of rows on each level, from the finest
+func (amg *AMG) Levels() []int {
+	sizes := make([]int, len(amg.levels))
+	for i, lvl := range amg.levels {
+		sizes[i] = lvl.A.Rows
+	}
+	return sizes
+}
+
+// Apply runs one cycle for Az = r starting from zero
+func (amg *AMG) Apply(z, r []float64) {
+	for i := range z {
+		z[i] = 0
+	}
+	amg.cycle(0, z, r)
+}
+
+// SolveFrom iterates cycles for the matrix set by Setup, starting from x0
+func (amg *AMG) SolveFrom(b, x0 []float64) (Result, error) {
+	if len(amg.levels) == 0 {
+		return Result{}, fmt.Errorf("solver has no matrix, call Setup first")
+	}
+	A := amg.levels[0].A
+	if A.Rows != len(b) {
+		return Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+	if amg.Relative && norm(b) == 0 {
+		return zeroSolution(len(b)), nil
+	}
+	x, err := initialGuess(x0, len(b))
+	if err != nil {
+		return Result{}, err
+	}
+	r, err := residual(A, b, x)
+	if err != nil {
+		return Result{}, err
+	}
+	tol := amg.Tolerance
+	if amg.Relative {
+		tol *= norm(b)
+	}
+	rnorm := norm(r)
+	res := Result{X: x, Residuals: []float64{rnorm}}
+	for rnorm >= tol {
+		if res.Iterations >= amg.MaxIter {
+			return res, nil
+		}
+		amg.cycle(0, x, b)
+		if r, err = residual(A, b, x); err != nil {
+			return Result{}, err
+		}
+		rnorm = norm(r)
+		res.Iterations++
+		res.Residuals = append(res.Residuals, rnorm)
+	}
+	res.Converged = true
+	return res, nil
+}
+
+// Free releases the hierarchy
+func (amg *AMG) Free() {
+	amg.levels, amg.lu, amg.pivot = nil, nil, nil
+}
+
+// Solve solves the system Ax = b using AMG as a standalone solver
+func (amg *AMG) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
+	if err := amg.Setup(A); err != nil {
+		return nil, err
+	}
+	res, err := amg.SolveFrom(b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("maximum iterations reached without convergence")
+	}
+	return res.X, nil
+}
+
+// cycle improves x for A_l x = b on level l
+func (amg *AMG) cycle(l int, x, b []float64) {
+	lvl := &amg.levels[l]
+	if l == len(amg.levels)-1 {
+		if amg.lu != nil {
+			solveFactorized(amg.lu, amg.pivot, x, b)
+			return
+		}
+		for s := 0; s < 10; s++ {
+			amg.smooth(lvl, x, b, true)
+			amg.smooth(lvl, x, b, false)
+		}
+		return
+	}
+	sweeps := amg.Sweeps
+	if sweeps <= 0 {
+		sweeps = 1
+	}
+	for s := 0; s < sweeps; s++ {
+		amg.smooth(lvl, x, b, true)
+	}
+
+	next := &amg.levels[l+1]
+	spmv(lvl.A, x, lvl.r)
+	for i := range lvl.r {
+		lvl.r[i] = b[i] - lvl.r[i]
+	}
+	spmv(lvl.R, lvl.r, next.b)
+	for i := range next.x {
+		next.x[i] = 0
+	}
+	visits := 1
+	if amg.Cycle == WCycle {
+		visits = 2
+	}
+	for v := 0; v < visits; v++ {
+		amg.cycle(l+1, next.x, next.b)
+	}
+	spmv(lvl.P, next.x, lvl.r)
+	for i := range x {
+		x[i] += lvl.r[i]
+	}
+
+	for s := 0; s < sweeps; s++ {
+		amg.smooth(lvl, x, b, false)
+	}
+}
+
+// smooth performs one relaxation sweep; Gauss-Seidel goes forward
+// before and backward after the coarse correction to keep the cycle
+// symmetric
+func (amg *AMG) smooth(lvl *amgLevel, x, b []float64, forward bool) {
+	A := lvl.A
+	if amg.Smoother == JacobiSmoother {
+		omega := amg.Omega
+		if omega <= 0 {
+			omega = 2.0 / 3.0
+		}
+		spmv(A, x, lvl.r)
+		for i := range x {
+			x[i] += omega * (b[i] - lvl.r[i]) / lvl.diag[i]
+		}
+		return
+	}
+	row := func(i int) {
+		sum := b[i]
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if j := A.ColIndices[k]; j != i {
+				sum -= A.Values[k] * x[j]
+			}
+		}
+		x[i] = sum / lvl.diag[i]
+	}
+	if forward {
+		for i := 0; i < A.Rows; i++ {
+			row(i)
+		}
+	} else {
+		for i := A.Rows - 1; i >= 0; i-- {
+			row(i)
+		}
+	}
+}
+
+// strong reports whether the entry at position k of row i is a strong
+// connection
+func strong(A *matrix.CSRMatrix, diag []float64, theta float64, i, k int) bool {
+	j := A.ColIndices[k]
+	return j != i && A.Values[k]*A.Values[k] > theta*theta*math.Abs(diag[i]*diag[j])
+}
+
+// aggregate groups the rows of A into aggregates of strongly connected
+// neighbours and returns the aggregate of every row and their number
+func aggregate(A *matrix.CSRMatrix, diag []float64, theta float64) ([]int, int) {
+	n := A.Rows
+	agg := make([]int, n)
+	for i := range agg {
+		agg[i] = -1
+	}
+	count := 0
+
+	// Phase 1: rows whose strong neighbourhood is entirely free seed aggregates
+	for i := 0; i < n; i++ {
+		if agg[i] >= 0 {
+			continue
+		}
+		free, connected := true, false
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if strong(A, diag, theta, i, k) {
+				connected = true
+				if agg[A.ColIndices[k]] >= 0 {
+					free = false
+					break
+				}
+			}
+		}
+		if !free || !connected {
+			continue
+		}
+		agg[i] = count
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if strong(A, diag, theta, i, k) {
+				agg[A.ColIndices[k]] = count
+			}
+		}
+		count++
+	}
+
+	// Phase 2: remaining rows join the aggregate of their strongest
+	// neighbour aggregated in phase 1
+	seeded := make([]int, n)
+	copy(seeded, agg)
+	for i := 0; i < n; i++ {
+		if agg[i] >= 0 {
+			continue
+		}
+		best, weight := -1, 0.0
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			j := A.ColIndices[k]
+			if strong(A, diag, theta, i, k) && seeded[j] >= 0 && math.Abs(A.Values[k]) > weight {
+				best, weight = seeded[j], math.Abs(A.Values[k])
+			}
+		}
+		agg[i] = best
+	}
+
+	// Phase 3: what is left forms new aggregates with free strong neighbours
+	for i := 0; i < n; i++ {
+		if agg[i] >= 0 {
+			continue
+		}
+		agg[i] = count
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if j := A.ColIndices[k]; strong(A, diag, theta, i, k) && agg[j] < 0 {
+				agg[j] = count
+			}
+		}
+		count++
+	}
+	return agg, count
+}
+
+// prolongation returns P = (I - omega D_f^-1 A_f) P_0, where P_0 is the
+// piecewise constant interpolation from the aggregates and A_f is A with
+// weak connections lumped into the diagonal. Omega is 4/3 over the
+// Gershgorin estimate of the spectral radius of D_f^-1 A_f.
+func prolongation(A *matrix.CSRMatrix, diag []float64, agg []int, count int, theta float64) *matrix.CSRMatrix {
+	n := A.Rows
+	df := make([]float64, n)
+	rho := 0.0
+	for i := 0; i < n; i++ {
+		df[i] = diag[i]
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if j := A.ColIndices[k]; j != i && !strong(A, diag, theta, i, k) {
+				df[i] += A.Values[k]
+			}
+		}
+		if df[i] == 0 {
+			df[i] = diag[i]
+		}
+		sum := math.Abs(df[i])
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if strong(A, diag, theta, i, k) {
+				sum += math.Abs(A.Values[k])
+			}
+		}
+		if r := sum / math.Abs(df[i]); r > rho {
+			rho = r
+		}
+	}
+	omega := 4.0 / 3.0 / rho
+
+	P := &matrix.CSRMatrix{Rows: n, Cols: count, RowPtr: make([]int, 1, n+1)}
+	marker := make([]int, count)
+	for i := range marker {
+		marker[i] = -1
+	}
+	add := func(start, col int, v float64) {
+		if marker[col] < start {
+			marker[col] = len(P.Values)
+			P.ColIndices = append(P.ColIndices, col)
+			P.Values = append(P.Values, v)
+		} else {
+			P.Values[marker[col]] += v
+		}
+	}
+	for i := 0; i < n; i++ {
+		start := len(P.Values)
+		add(start, agg[i], 1-omega)
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if strong(A, diag, theta, i, k) {
+				add(start, agg[A.ColIndices[k]], -omega*A.Values[k]/df[i])
+			}
+		}
+		P.RowPtr = append(P.RowPtr, len(P.Values))
+	}
+	return P
+}
+
+// transpose returns A^T
+func transpose(A *matrix.CSRMatrix) *matrix.CSRMatrix {
+	T := &matrix.CSRMatrix{
+		Rows:       A.Cols,
+		Cols:       A.Rows,
+		RowPtr:     make([]int, A.Cols+1),
+		ColIndices: make([]int, len(A.Values)),
+		Values:     make([]float64, len(A.Values)),
+	}
+	for _, j := range A.ColIndices {
+		T.RowPtr[j+1]++
+	}
+	for j := 0; j < A.Cols; j++ {
+		T.RowPtr[j+1] += T.RowPtr[j]
+	}
+	next := make([]int, A.Cols)
+	copy(next, T.RowPtr)
+	for i := 0; i < A.Rows; i++ {
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			j := A.ColIndices[k]
+			T.ColIndices[next[j]] = i
+			T.Values[next[j]] = A.Values[k]
+			next[j]++
+		}
+	}
+	return T
+}
+
+// multiply returns the sparse product AB
+func multiply(A, B *matrix.CSRMatrix) *matrix.CSRMatrix {
+	C := &matrix.CSRMatrix{Rows: A.Rows, Cols: B.Cols, RowPtr: make([]int, 1, A.Rows+1)}
+	marker := make([]int, B.Cols)
+	for i := range marker {
+		marker[i] = -1
+	}
+	for i := 0; i < A.Rows; i++ {
+		start := len(C.Values)
+		for ka := A.RowPtr[i]; ka < A.RowPtr[i+1]; ka++ {
+			k, va := A.ColIndices[ka], A.Values[ka]
+			for kb := B.RowPtr[k]; kb < B.RowPtr[k+1]; kb++ {
+				j := B.ColIndices[kb]
+				if marker[j] < start {
+					marker[j] = len(C.Values)
+					C.ColIndices = append(C.ColIndices, j)
+					C.Values = append(C.Values, va*B.Values[kb])
+				} else {
+					C.Values[marker[j]] += va * B.Values[kb]
+				}
+			}
+		}
+		C.RowPtr = append(C.RowPtr, len(C.Values))
+	}
+	return C
+}
+
+// spmv computes y = Ax without allocating
+func spmv(A *matrix.CSRMatrix, x, y []float64) {
+	for i := 0; i < A.Rows; i++ {
+		sum := 0.0
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			sum += A.Values[k] * x[A.ColIndices[k]]
+		}
+		y[i] = sum
+	}
+}
+
+// factorize returns the dense LU factors of A with partial pivoting,
+// or nil if A is singular
+func factorize(A *matrix.CSRMatrix) ([]float64, []int) {
+	n := A.Rows
+	lu := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			lu[i*n+A.ColIndices[k]] += A.Values[k]
+		}
+	}
+	scale := 0.0
+	for _, v := range lu {
+		scale = math.Max(scale, math.Abs(v))
+	}
+	pivot := make([]int, n)
+	for c := 0; c < n; c++ {
+		p := c
+		for i := c + 1; i < n; i++ {
+			if math.Abs(lu[i*n+c]) > math.Abs(lu[p*n+c]) {
+				p = i
+			}
+		}
+		if math.Abs(lu[p*n+c]) <= 1e-14*scale {
+			return nil, nil
+		}
+		pivot[c] = p
+		if p != c {
+			for j := 0; j < n; j++ {
+				lu[c*n+j], lu[p*n+j] = lu[p*n+j], lu[c*n+j]
+			}
+		}
+		for i := c + 1; i < n; i++ {
+			f := lu[i*n+c] / lu[c*n+c]
+			lu[i*n+c] = f
+			if f != 0 {
+				for j := c + 1; j < n; j++ {
+					lu[i*n+j] -= f * lu[c*n+j]
+				}
+			}
+		}
+	}
+	return lu, pivot
+}
+
+// solveFactorized solves LUx = b with the factors from factorize
+func solveFactorized(lu []float64, pivot []int, x, b []float64) {
+	n := len(pivot)
+	copy(x, b)
+	for c := 0; c < n; c++ {
+		if p := pivot[c]; p != c {
+			x[c], x[p] = x[p], x[c]
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			x[i] -= lu[i*n+j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= lu[i*n+j] * x[j]
+		}
+		x[i] /= lu[i*n+i]
+	}
+}
@@ -0,0 +1,153 @@
+package solvers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAMGHierarchy(t *testing.T) {
+	amg := NewAMG()
+	if err := amg.Setup(laplacian2D(60)); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	sizes := amg.Levels()
+	if len(sizes) < 3 || sizes[0] != 3600 || sizes[len(sizes)-1] > 300 {
+		t.Fatalf("Unexpected hierarchy %v", sizes)
+	}
+	for l := 1; l < len(sizes); l++ {
+		if sizes[l]*3 > sizes[l-1] {
+			t.Errorf("Level %d coarsens %d rows only to %d", l, sizes[l-1], sizes[l])
+		}
+	}
+}
+
+// Only a coarsest level within CoarseSize is factorized; a larger one,
+// left by MaxLevels, is smoothed
+func TestAMGCoarsestLevel(t *testing.T) {
+	A := laplacian2D(60)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = 1
+	}
+	for _, coarseSize := range []int{0, 2000} {
+		amg := NewAMG()
+		amg.MaxLevels, amg.CoarseSize = 2, coarseSize
+		amg.MaxIter, amg.Tolerance, amg.Relative = 200, 1e-8, true
+		if err := amg.Setup(A); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		sizes := amg.Levels()
+		direct := sizes[len(sizes)-1] <= coarseSize
+		if len(sizes) != 2 || sizes[1] <= 300 || (amg.lu != nil) != direct {
+			t.Fatalf("CoarseSize %d: levels %v, factorized %v", coarseSize, sizes, amg.lu != nil)
+		}
+		res, err := amg.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("SolveFrom failed: %v", err)
+		}
+		checkSolution(t, "two levels", A, b, res, 1e-8)
+	}
+}
+
+func TestAMGStandalone(t *testing.T) {
+	A := laplacian2D(60)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = math.Sin(0.1 * float64(i))
+	}
+	cases := []struct {
+		name     string
+		smoother Smoother
+		cycle    CycleType
+		maxIter  int
+	}{
+		{"V Gauss-Seidel", GaussSeidelSmoother, VCycle, 40},
+		{"W Gauss-Seidel", GaussSeidelSmoother, WCycle, 40},
+		{"V Jacobi", JacobiSmoother, VCycle, 80},
+	}
+	iterations := make(map[string]int)
+	for _, c := range cases {
+		amg := NewAMG()
+		amg.Smoother, amg.Cycle = c.smoother, c.cycle
+		amg.MaxIter, amg.Tolerance, amg.Relative = c.maxIter, 1e-8, true
+		if err := amg.Setup(A); err != nil {
+			t.Fatalf("%s: Setup failed: %v", c.name, err)
+		}
+		res, err := amg.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("%s: SolveFrom failed: %v", c.name, err)
+		}
+		checkSolution(t, c.name, A, b, res, 1e-8)
+		iterations[c.name] = res.Iterations
+	}
+	if iterations["W Gauss-Seidel"] > iterations["V Gauss-Seidel"] {
+		t.Errorf("W-cycle needs more iterations than V-cycle: %v", iterations)
+	}
+}
+
+func TestAMGPreconditionedCG(t *testing.T) {
+	// The iteration count of AMG-CG barely grows with the grid size
+	var counts []int
+	for _, n := range []int{40, 80} {
+		A := laplacian2D(n)
+		b := make([]float64, A.Rows)
+		for i := range b {
+			b[i] = 1
+		}
+		slv, err := New("cg", Options{Tolerance: 1e-8, Preconditioner: "amg"})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if err := slv.Setup(A); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		res, err := slv.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("SolveFrom failed: %v", err)
+		}
+		checkSolution(t, "AMG-CG", A, b, res, 1e-8)
+		counts = append(counts, res.Iterations)
+	}
+	if counts[0] > 25 || counts[1] > counts[0]+8 {
+		t.Errorf("AMG-CG iterations %v grow with the grid", counts)
+	}
+
+	// Nonsymmetric systems through BiCGSTAB
+	A := convectionDiffusion(40, 100)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = 1
+	}
+	slv, _ := New("bicgstab", Options{Preconditioner: "amg"})
+	if err := slv.Setup(A); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	res, err := slv.SolveFrom(b, nil)
+	if err != nil {
+		t.Fatalf("SolveFrom failed: %v", err)
+	}
+	checkSolution(t, "AMG-BiCGSTAB", A, b, res, 1e-8)
+}
+
+func TestSparseProduct(t *testing.T) {
+	A := convectionDiffusion(3, 5)
+	T := transpose(A)
+	C := multiply(T, A)
+	for i := 0; i < A.Rows; i++ {
+		for j := 0; j < A.Cols; j++ {
+			want := 0.0
+			for k := 0; k < A.Rows; k++ {
+				want += A.Get(k, i) * A.Get(k, j)
+			}
+			got := 0.0
+			for p := C.RowPtr[i]; p < C.RowPtr[i+1]; p++ {
+				if C.ColIndices[p] == j {
+					got += C.Values[p]
+				}
+			}
+			if math.Abs(got-want) > 1e-12 {
+				t.Fatalf("(A^T A)[%d][%d] = %g, expected %g", i, j, got, want)
+			}
+		}
+	}
+}
@@ -1,66 +1,157 @@
-package amgcl
-
-/*
-#cgo CXXFLAGS: -I./include -std=c++11
-#cgo LDFLAGS: -L${SRCDIR} -lamgcl_wrapper -lstdc++
-#include "amgcl_wrapper.h"
-*/
-import "C"
-import (
-	"fmt"
-	"unsafe"
-
-	"test.com/mat/matrix"
-)
-
-// Solver wraps the AMGCL solver
-type Solver struct {
-	solver C.AMGCLSolver // Changed from *C.AMGCLSolver to C.AMGCLSolver
-}
-
-func NewSolver(A *matrix.CSRMatrix) (*Solver, error) {
-	if A == nil {
-		return nil, fmt.Errorf("matrix cannot be nil")
-	}
-
-	rowPtr := make([]C.int, len(A.RowPtr))         // Changed size_t to int
-	colIndices := make([]C.int, len(A.ColIndices)) // Changed size_t to int
-
-	for i := range A.RowPtr {
-		rowPtr[i] = C.int(A.RowPtr[i])
-	}
-	for i := range A.ColIndices {
-		colIndices[i] = C.int(A.ColIndices[i])
-	}
-
-	s := &Solver{
-		solver: C.create_solver(
-			C.int(A.Rows),
-			(*C.int)(unsafe.Pointer(&rowPtr[0])),
-			(*C.int)(unsafe.Pointer(&colIndices[0])),
-			(*C.double)(unsafe.Pointer(&A.Values[0])),
-		),
-	}
-	return s, nil
-}
-
-func (s *Solver) Solve(rhs []float64) ([]float64, error) {
-	if s.solver == nil {
-		return nil, fmt.Errorf("solver has been freed")
-	}
-	x := make([]float64, len(rhs))
-	C.solve_system(
-		s.solver, // Pass the C.AMGCLSolver directly
-		(*C.double)(unsafe.Pointer(&rhs[0])),
-		(*C.double)(unsafe.Pointer(&x[0])),
-	)
-	return x, nil
-}
-
-// Optional: Add a Free method to clean up
-func (s *Solver) Free() {
-	if s.solver != nil {
-		C.destroy_solver(s.solver)
-		s.solver = nil
-	}
-}
+//go:build amgcl
+
+package amgcl
+
+/*
+#cgo CXXFLAGS: -I./include -std=c++11
+#cgo LDFLAGS: -lstdc++
+#include <stdlib.h>
+#include "amgcl_wrapper.h"
+*/
+import "C"
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"test.com/mat/matrix"
+	"test.com/solvers"
+)
+
+// Solver wraps the AMGCL solver
+type Solver struct {
+	Params Params // Components and tolerances, applied by Setup
+
+	solver C.AMGCLSolver // Changed from *C.AMGCLSolver to C.AMGCLSolver
+	a      *matrix.CSRMatrix
+}
+
+func init() {
+	solvers.Register("amgcl", func(opts solvers.Options) (solvers.LinearSolver, error) {
+		return &Solver{Params: Params{
+			Tolerance: opts.Tolerance,
+			MaxIter:   opts.MaxIter,
+			Extra:     opts.Params,
+		}}, nil
+	})
+}
+
+// NewSolver sets up the default AMGCL solver for A
+func NewSolver(A *matrix.CSRMatrix) (*Solver, error) {
+	return NewSolverWithParams(A, Params{})
+}
+
+// NewSolverWithParams sets up an AMGCL solver for A with the given parameters
+func NewSolverWithParams(A *matrix.CSRMatrix, prm Params) (*Solver, error) {
+	s := &Solver{Params: prm}
+	if err := s.Setup(A); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Setup builds the AMG hierarchy for A, releasing the previous one
+func (s *Solver) Setup(A *matrix.CSRMatrix) error {
+	if A == nil {
+		return fmt.Errorf("matrix cannot be nil")
+	}
+	if A.Rows == 0 {
+		return fmt.Errorf("matrix is empty")
+	}
+	if err := s.Params.validate(); err != nil {
+		return err
+	}
+	s.Free()
+
+	rowPtr := make([]C.int, len(A.RowPtr))         // Changed size_t to int
+	colIndices := make([]C.int, len(A.ColIndices)) // Changed size_t to int
+
+	for i := range A.RowPtr {
+		rowPtr[i] = C.int(A.RowPtr[i])
+	}
+	for i := range A.ColIndices {
+		colIndices[i] = C.int(A.ColIndices[i])
+	}
+
+	params := C.CString(s.Params.tree())
+	defer C.free(unsafe.Pointer(params))
+	var msg [256]C.char
+	s.solver = C.create_solver(
+		C.int(A.Rows),
+		(*C.int)(unsafe.Pointer(&rowPtr[0])),
+		(*C.int)(unsafe.Pointer(&colIndices[0])),
+		(*C.double)(unsafe.Pointer(&A.Values[0])),
+		params,
+		&msg[0], C.int(len(msg)),
+	)
+	if s.solver == nil {
+		return fmt.Errorf("AMGCL setup failed: %s", C.GoString(&msg[0]))
+	}
+	s.a = A
+	return nil
+}
+
+// SolveFrom solves the system starting from x0. AMGCL reports only the
+// final residual, so Residuals holds the initial and the final norm.
+func (s *Solver) SolveFrom(rhs, x0 []float64) (solvers.Result, error) {
+	if s.solver == nil {
+		return solvers.Result{}, fmt.Errorf("solver has been freed")
+	}
+	if len(rhs) != s.a.Rows || x0 != nil && len(x0) != s.a.Rows {
+		return solvers.Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+	x := make([]float64, len(rhs))
+	copy(x, x0)
+	r0 := norm(rhs)
+	if x0 != nil {
+		Ax, err := s.a.MatVec(x0)
+		if err != nil {
+			return solvers.Result{}, err
+		}
+		r := make([]float64, len(rhs))
+		for i := range r {
+			r[i] = rhs[i] - Ax[i]
+		}
+		r0 = norm(r)
+	}
+	var iters C.int
+	var rel C.double
+	if C.solve_system(
+		s.solver, // Pass the C.AMGCLSolver directly
+		(*C.double)(unsafe.Pointer(&rhs[0])),
+		(*C.double)(unsafe.Pointer(&x[0])),
+		&iters, &rel,
+	) != 0 {
+		return solvers.Result{}, fmt.Errorf("AMGCL solve failed")
+	}
+	tol := s.Params.tolerance()
+	return solvers.Result{
+		X:          x,
+		Iterations: int(iters),
+		Residuals:  []float64{r0, float64(rel) * norm(rhs)},
+		Converged:  float64(rel) <= tol,
+	}, nil
+}
+
+func (s *Solver) Solve(rhs []float64) ([]float64, error) {
+	res, err := s.SolveFrom(rhs, nil)
+	return res.X, err
+}
+
+// norm returns the Euclidean norm of v
+func norm(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	return math.Sqrt(sum)
+}
+
+// Optional: Add a Free method to clean up
+func (s *Solver) Free() {
+	if s.solver != nil {
+		C.destroy_solver(s.solver)
+		s.solver = nil
+	}
+	s.a = nil
+}
@@ -1,49 +1,98 @@
-#include <vector>
-#include <amgcl/amg.hpp>
-#include <amgcl/make_solver.hpp>
-#include <amgcl/solver/cg.hpp>
-#include <amgcl/adapter/crs_tuple.hpp>
-#include <amgcl/coarsening/smoothed_aggregation.hpp>
-#include <amgcl/relaxation/damped_jacobi.hpp>
-#include "amgcl_wrapper.h"
-
-#ifdef _WIN32
-#define EXPORT __declspec(dllexport)
-#else
-#define EXPORT
-#endif
-
-typedef amgcl::make_solver<
-    amgcl::amg<amgcl::backend::builtin<double>, 
-                amgcl::coarsening::smoothed_aggregation, 
-                amgcl::relaxation::damped_jacobi>,
-    amgcl::solver::cg<amgcl::backend::builtin<double>>
-> Solver;
-
-struct AMGCLSolverImpl {
-    std::shared_ptr<Solver> solver;
-};
-
-AMGCLSolver create_solver(int n, int* rows, int* cols, double* values) {
-    std::vector<int> ptr(rows, rows + n + 1);
-    std::vector<int> col(cols, cols + ptr[n]);
-    std::vector<double> val(values, values + ptr[n]);
-    AMGCLSolverImpl* impl = new AMGCLSolverImpl();
-    impl->solver = std::make_shared<Solver>(std::make_tuple(n, ptr, col, val));
-    return (AMGCLSolver)impl;
-}
-
-void solve_system(AMGCLSolver solver, double* rhs, double* x) {
-    AMGCLSolverImpl* impl = (AMGCLSolverImpl*)solver;
-    std::vector<double> RHS(rhs, rhs + impl->solver->size());
-    std::vector<double> X(x, x + impl->solver->size());
-    size_t iters;
-    double error;
-    std::tie(iters, error) = (*impl->solver)(RHS, X);
-    std::copy(X.begin(), X.end(), x);
-}
-
-void destroy_solver(AMGCLSolver solver) {
-    AMGCLSolverImpl* impl = (AMGCLSolverImpl*)solver;
-    delete impl;
-}
\ No newline at end of file
+//go:build amgcl
+
+#include <vector>
+#include <string>
+#include <sstream>
+#include <cstring>
+#include <stdexcept>
+
+// Unknown keys are reported as errors instead of warnings on stderr
+#define AMGCL_PARAM_UNKNOWN(name) \
+    throw std::invalid_argument(std::string("unknown AMGCL parameter ") + (name))
+
+#include <boost/property_tree/ptree.hpp>
+#include <amgcl/amg.hpp>
+#include <amgcl/make_solver.hpp>
+#include <amgcl/backend/builtin.hpp>
+#include <amgcl/adapter/crs_tuple.hpp>
+#include <amgcl/coarsening/runtime.hpp>
+#include <amgcl/relaxation/runtime.hpp>
+#include <amgcl/solver/runtime.hpp>
+#include "amgcl_wrapper.h"
+
+#ifdef _WIN32
+#define EXPORT __declspec(dllexport)
+#else
+#define EXPORT
+#endif
+
+typedef amgcl::backend::builtin<double> Backend;
+
+// Coarsening, relaxation and Krylov method are chosen at runtime
+// from the property tree
+typedef amgcl::make_solver<
+    amgcl::amg<Backend,
+               amgcl::runtime::coarsening::wrapper,
+               amgcl::runtime::relaxation::wrapper>,
+    amgcl::runtime::solver::wrapper<Backend>
+> Solver;
+
+struct AMGCLSolverImpl {
+    std::shared_ptr<Solver> solver;
+};
+
+// copy_error stores the message of a failed call for the Go side
+static void copy_error(char* err, int errlen, const char* msg) {
+    if (err && errlen > 0) {
+        std::strncpy(err, msg, errlen - 1);
+        err[errlen - 1] = '\0';
+    }
+}
+
+AMGCLSolver create_solver(int n, int* rows, int* cols, double* values,
+                          const char* params, char* err, int errlen) {
+    try {
+        boost::property_tree::ptree prm;
+        std::istringstream lines(params ? params : "");
+        std::string line;
+        while (std::getline(lines, line)) {
+            size_t eq = line.find('=');
+            if (eq == std::string::npos) continue;
+            prm.put(line.substr(0, eq), line.substr(eq + 1));
+        }
+
+        std::vector<int> ptr(rows, rows + n + 1);
+        std::vector<int> col(cols, cols + ptr[n]);
+        std::vector<double> val(values, values + ptr[n]);
+        AMGCLSolverImpl* impl = new AMGCLSolverImpl();
+        impl->solver = std::make_shared<Solver>(std::make_tuple(n, ptr, col, val), prm);
+        return (AMGCLSolver)impl;
+    } catch (const std::exception& e) {
+        copy_error(err, errlen, e.what());
+    } catch (...) {
+        copy_error(err, errlen, "unknown error");
+    }
+    return NULL;
+}
+
+int solve_system(AMGCLSolver solver, double* rhs, double* x, int* iters, double* error) {
+    try {
+        AMGCLSolverImpl* impl = (AMGCLSolverImpl*)solver;
+        std::vector<double> RHS(rhs, rhs + impl->solver->size());
+        std::vector<double> X(x, x + impl->solver->size());
+        size_t it;
+        double err;
+        std::tie(it, err) = (*impl->solver)(RHS, X);
+        std::copy(X.begin(), X.end(), x);
+        *iters = (int)it;
+        *error = err;
+        return 0;
+    } catch (...) {
+        return 1;
+    }
+}
+
+void destroy_solver(AMGCLSolver solver) {
+    AMGCLSolverImpl* impl = (AMGCLSolverImpl*)solver;
+    delete impl;
+}
@@ -1,18 +1,22 @@
-#ifndef AMGCL_WRAPPER_H
-#define AMGCL_WRAPPER_H
-
-#ifdef __cplusplus
-extern "C" {
-#endif
-
-typedef struct amgcl_solver_t amgcl_solver_t;
-typedef void* AMGCLSolver;
-AMGCLSolver create_solver(int n, int* rows, int* cols, double* values);
-void solve_system(AMGCLSolver solver, double* rhs, double* x);
-void destroy_solver(AMGCLSolver solver);
-
-#ifdef __cplusplus
-}
-#endif
-
+#ifndef AMGCL_WRAPPER_H
+#define AMGCL_WRAPPER_H
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef struct amgcl_solver_t amgcl_solver_t;
+typedef void* AMGCLSolver;
+/* params holds "key=value" lines of the AMGCL property tree, e.g.
+   "solver.type=gmres"; on failure returns NULL and writes the message to err */
+AMGCLSolver create_solver(int n, int* rows, int* cols, double* values,
+                          const char* params, char* err, int errlen);
+/* x holds the initial guess on entry; returns 0 on success */
+int solve_system(AMGCLSolver solver, double* rhs, double* x, int* iters, double* error);
+void destroy_solver(AMGCLSolver solver);
+
+#ifdef __cplusplus
+}
+#endif
+
 #endif
\ No newline at end of file
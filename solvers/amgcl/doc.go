@@ -0,0 +1,8 @@
+// Package amgcl wraps the AMGCL C++ library as the "amgcl" linear solver.
+//
+// The wrapper is compiled only with the amgcl build tag
+// (go build -tags amgcl) and needs cgo, a C++ compiler and Boost; cgo
+// builds amgcl_wrapper.cpp together with the package.
+// Without the tag the package holds only Params and the pure-Go solvers
+// are used.
+package amgcl
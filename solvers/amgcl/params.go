@@ -0,0 +1,129 @@
+package amgcl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coarsening, relaxation and solver types of the AMGCL runtime interface
+const (
+	SmoothedAggregation = "smoothed_aggregation"
+	Aggregation         = "aggregation"
+	RugeStuben          = "ruge_stuben"
+
+	DampedJacobi = "damped_jacobi"
+	GaussSeidel  = "gauss_seidel"
+	SPAI0        = "spai0"
+	ILU0         = "ilu0"
+	Chebyshev    = "chebyshev"
+
+	CG       = "cg"
+	BiCGSTAB = "bicgstab"
+	GMRES    = "gmres"
+)
+
+// Params selects the AMGCL components at runtime. Empty fields keep the
+// defaults: smoothed aggregation, damped Jacobi and CG.
+type Params struct {
+	Coarsening string
+	Relaxation string
+	Solver     string
+	Tolerance  float64 // Relative tolerance, 0 keeps the AMGCL default 1e-8
+	MaxIter    int     // Maximum number of iterations, 0 keeps the AMGCL default
+
+	// Extra holds further property tree keys such as "solver.M" or
+	// "precond.relax.damping"; they override the fields above
+	Extra map[string]string
+}
+
+// DefaultTolerance is the relative tolerance of AMGCL when none is set
+const DefaultTolerance = 1e-8
+
+// tolerance returns the relative tolerance the solver stops at
+func (p Params) tolerance() float64 {
+	if v, ok := p.Extra["solver.tol"]; ok {
+		if tol, err := strconv.ParseFloat(v, 64); err == nil {
+			return tol
+		}
+	}
+	if p.Tolerance > 0 {
+		return p.Tolerance
+	}
+	return DefaultTolerance
+}
+
+// validate checks the component names and the numeric parameters
+func (p Params) validate() error {
+	check := func(kind, name string, allowed ...string) error {
+		if name == "" {
+			return nil
+		}
+		for _, a := range allowed {
+			if name == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown AMGCL %s %q", kind, name)
+	}
+	if err := check("coarsening", p.Coarsening, SmoothedAggregation, Aggregation, RugeStuben); err != nil {
+		return err
+	}
+	if err := check("relaxation", p.Relaxation, DampedJacobi, GaussSeidel, SPAI0, ILU0, Chebyshev); err != nil {
+		return err
+	}
+	if err := check("solver", p.Solver, CG, BiCGSTAB, GMRES); err != nil {
+		return err
+	}
+	if p.Tolerance < 0 {
+		return fmt.Errorf("tolerance must not be negative, got %g", p.Tolerance)
+	}
+	if p.MaxIter < 0 {
+		return fmt.Errorf("maximum number of iterations must not be negative, got %d", p.MaxIter)
+	}
+	for k, v := range p.Extra {
+		if k == "" || strings.ContainsAny(k, "=\n") || strings.Contains(v, "\n") {
+			return fmt.Errorf("invalid AMGCL parameter %q=%q", k, v)
+		}
+	}
+	return nil
+}
+
+// tree returns the parameters as "key=value" lines of the AMGCL property
+// tree, the format create_solver expects
+func (p Params) tree() string {
+	def := func(v, d string) string {
+		if v == "" {
+			return d
+		}
+		return v
+	}
+	prm := map[string]string{
+		"precond.coarsening.type": def(p.Coarsening, SmoothedAggregation),
+		"precond.relax.type":      def(p.Relaxation, DampedJacobi),
+		"solver.type":             def(p.Solver, CG),
+	}
+	if p.Tolerance > 0 {
+		prm["solver.tol"] = strconv.FormatFloat(p.Tolerance, 'g', -1, 64)
+	}
+	if p.MaxIter > 0 {
+		prm["solver.maxiter"] = strconv.Itoa(p.MaxIter)
+	}
+	for k, v := range p.Extra {
+		prm[k] = v
+	}
+	keys := make([]string, 0, len(prm))
+	for k := range prm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(prm[k])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package amgcl
+
+import "testing"
+
+func TestParamsTree(t *testing.T) {
+	want := "precond.coarsening.type=smoothed_aggregation\n" +
+		"precond.relax.type=damped_jacobi\n" +
+		"solver.type=cg\n"
+	if got := (Params{}).tree(); got != want {
+		t.Errorf("Default tree:\n%s\nexpected:\n%s", got, want)
+	}
+
+	prm := Params{
+		Coarsening: RugeStuben,
+		Relaxation: GaussSeidel,
+		Solver:     GMRES,
+		Tolerance:  1e-6,
+		MaxIter:    200,
+		Extra:      map[string]string{"solver.M": "50", "solver.maxiter": "300"},
+	}
+	want = "precond.coarsening.type=ruge_stuben\n" +
+		"precond.relax.type=gauss_seidel\n" +
+		"solver.M=50\n" +
+		"solver.maxiter=300\n" +
+		"solver.tol=1e-06\n" +
+		"solver.type=gmres\n"
+	if got := prm.tree(); got != want {
+		t.Errorf("Tree:\n%s\nexpected:\n%s", got, want)
+	}
+	if err := prm.validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if tol := prm.tolerance(); tol != 1e-6 {
+		t.Errorf("Tolerance %g, expected 1e-6", tol)
+	}
+	if tol := (Params{}).tolerance(); tol != DefaultTolerance {
+		t.Errorf("Default tolerance %g, expected %g", tol, DefaultTolerance)
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	for _, prm := range []Params{
+		{Coarsening: "multigrid"},
+		{Relaxation: "sor"},
+		{Solver: "lgmres"},
+		{Tolerance: -1},
+		{MaxIter: -1},
+		{Extra: map[string]string{"solver.type=cg\nprecond.relax.type": "spai0"}},
+		{Extra: map[string]string{"solver.type": "cg\nsolver.tol=1"}},
+	} {
+		if err := prm.validate(); err == nil {
+			t.Errorf("Expected an error for %+v", prm)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// BiCGSTABSolver represents a right-preconditioned BiCGSTAB solver
+// for nonsymmetric systems
+type BiCGSTABSolver struct {
+	MaxIter   int
+	Tolerance float64
+	Relative  bool // Compare ||r|| / ||b|| instead of ||r|| with Tolerance
+
+	// Preconditioner is applied from the right if set; it is set up
+	// by Setup and by every call of Solve
+	Preconditioner Preconditioner
+
+	a *matrix.CSRMatrix // Matrix set by Setup
+}
+
+func init() {
+	Register("bicgstab", func(opts Options) (LinearSolver, error) {
+		maxIter, tol, pc, err := opts.krylov()
+		if err != nil {
+			return nil, err
+		}
+		s := NewBiCGSTABSolver(maxIter, tol)
+		s.Relative = true
+		s.Preconditioner = pc
+		return s, nil
+	})
+}
+
+// NewBiCGSTABSolver creates a new BiCGSTAB solver
+func NewBiCGSTABSolver(maxIter int, tolerance float64) *BiCGSTABSolver {
+	return &BiCGSTABSolver{
+		MaxIter:   maxIter,
+		Tolerance: tolerance,
+	}
+}
+
+// Setup sets the matrix used by SolveFrom
+func (s *BiCGSTABSolver) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	if s.Preconditioner != nil {
+		if err := s.Preconditioner.Setup(A); err != nil {
+			return err
+		}
+	}
+	s.a = A
+	return nil
+}
+
+// SolveFrom solves the system Ax = b for the matrix set by Setup,
+// starting from x0
+func (s *BiCGSTABSolver) SolveFrom(b, x0 []float64) (Result, error) {
+	if s.a == nil {
+		return Result{}, fmt.Errorf("solver has no matrix, call Setup first")
+	}
+	return s.solve(s.a, b, x0)
+}
+
+// Free releases the matrix
+func (s *BiCGSTABSolver) Free() {
+	s.a = nil
+}
+
+// Solve solves the system Ax = b using the BiCGSTAB method
+func (s *BiCGSTABSolver) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
+	if s.Preconditioner != nil {
+		if err := s.Preconditioner.Setup(A); err != nil {
+			return nil, err
+		}
+	}
+	res, err := s.solve(A, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("maximum iterations reached without convergence")
+	}
+	return res.X, nil
+}
+
+func (s *BiCGSTABSolver) solve(A *matrix.CSRMatrix, b, x0 []float64) (Result, error) {
+	if A.Rows != len(b) {
+		return Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+	n := len(b)
+	if s.Relative && norm(b) == 0 {
+		return zeroSolution(n), nil
+	}
+	x, err := initialGuess(x0, n)
+	if err != nil {
+		return Result{}, err
+	}
+	r, err := residual(A, b, x)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tol := s.Tolerance
+	if s.Relative {
+		tol *= norm(b)
+	}
+	rnorm := norm(r)
+	res := Result{X: x, Residuals: []float64{rnorm}}
+	if rnorm < tol {
+		res.Converged = true
+		return res, nil
+	}
+
+	// precondition returns M^-1 v, or v itself without a preconditioner
+	precondition := func(v []float64) []float64 {
+		if s.Preconditioner == nil {
+			return v
+		}
+		z := make([]float64, n)
+		s.Preconditioner.Apply(z, v)
+		return z
+	}
+
+	rhat := make([]float64, n) // Shadow residual
+	copy(rhat, r)
+	p := make([]float64, n)
+	v := make([]float64, n)
+	sv := make([]float64, n)
+	rho, alpha, omega := 1.0, 1.0, 1.0
+
+	for res.Iterations < s.MaxIter {
+		rhoNew := dot(rhat, r)
+		if rhoNew == 0 {
+			break // Breakdown: r is orthogonal to the shadow residual
+		}
+		beta := rhoNew / rho * alpha / omega
+		rho = rhoNew
+
+		// p = r + beta*(p - omega*v)
+		for i := range p {
+			p[i] = r[i] + beta*(p[i]-omega*v[i])
+		}
+		phat := precondition(p)
+		if v, err = A.MatVec(phat); err != nil {
+			return Result{}, err
+		}
+		alpha = rho / dot(rhat, v)
+
+		// s = r - alpha*v
+		for i := range sv {
+			sv[i] = r[i] - alpha*v[i]
+		}
+		res.Iterations++
+		if snorm := norm(sv); snorm < tol {
+			for i := range x {
+				x[i] += alpha * phat[i]
+			}
+			res.Residuals = append(res.Residuals, snorm)
+			res.Converged = true
+			return res, nil
+		}
+
+		shat := precondition(sv)
+		t, err := A.MatVec(shat)
+		if err != nil {
+			return Result{}, err
+		}
+		omega = dot(t, sv) / dot(t, t)
+
+		// x = x + alpha*phat + omega*shat, r = s - omega*t
+		for i := range x {
+			x[i] += alpha*phat[i] + omega*shat[i]
+			r[i] = sv[i] - omega*t[i]
+		}
+		rnorm = norm(r)
+		res.Residuals = append(res.Residuals, rnorm)
+		if rnorm < tol {
+			res.Converged = true
+			return res, nil
+		}
+		if omega == 0 || math.IsNaN(omega) {
+			break // Breakdown: the stabilizing step made no progress
+		}
+	}
+	return res, nil
+}
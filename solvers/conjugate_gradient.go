@@ -1,96 +1,225 @@
-package solvers
-
-import (
-	"fmt"
-	"math"
-
-	"test.com/mat/matrix"
-)
-
-// CGSolver represents a Conjugate Gradient solver
-type CGSolver struct {
-	MaxIter   int
-	Tolerance float64
-}
-
-// NewCGSolver creates a new Conjugate Gradient solver
-func NewCGSolver(maxIter int, tolerance float64) *CGSolver {
-	return &CGSolver{
-		MaxIter:   maxIter,
-		Tolerance: tolerance,
-	}
-}
-
-// Solve solves the system Ax = b using the Conjugate Gradient method
-func (cg *CGSolver) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
-	if A.Rows != len(b) {
-		return nil, fmt.Errorf("matrix and vector dimensions mismatch")
-	}
-
-	n := len(b)
-	x := make([]float64, n) // Initial guess x = 0
-
-	// r = b - Ax
-	Ax, err := A.MatVec(x)
-	if err != nil {
-		return nil, err
-	}
-
-	r := make([]float64, n)
-	for i := range b {
-		r[i] = b[i] - Ax[i]
-	}
-
-	p := make([]float64, n)
-	copy(p, r)
-
-	rsold := dot(r, r)
-
-	if math.Sqrt(rsold) < cg.Tolerance {
-		return x, nil
-	}
-
-	for iter := 0; iter < cg.MaxIter; iter++ {
-		Ap, err := A.MatVec(p)
-		if err != nil {
-			return nil, err
-		}
-
-		alpha := rsold / dot(p, Ap)
-
-		// x = x + alpha*p
-		for i := range x {
-			x[i] += alpha * p[i]
-		}
-
-		// r = r - alpha*Ap
-		for i := range r {
-			r[i] -= alpha * Ap[i]
-		}
-
-		rsnew := dot(r, r)
-		if math.Sqrt(rsnew) < cg.Tolerance {
-			return x, nil
-		}
-
-		beta := rsnew / rsold
-
-		// p = r + beta*p
-		for i := range p {
-			p[i] = r[i] + beta*p[i]
-		}
-
-		rsold = rsnew
-	}
-
-	return nil, fmt.Errorf("maximum iterations reached without convergence")
-}
-
-// dot computes the dot product of two vectors
-func dot(a, b []float64) float64 {
-	sum := 0.0
-	for i := range a {
-		sum += a[i] * b[i]
-	}
-	return sum
-}
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// CGSolver represents a Conjugate Gradient solver
+type CGSolver struct {
+	MaxIter   int
+	Tolerance float64
+	Relative  bool // Compare ||r|| / ||b|| instead of ||r|| with Tolerance
+
+	// Preconditioner is applied to the residual if set; it is set up
+	// by Setup and by every call of Solve
+	Preconditioner Preconditioner
+
+	a *matrix.CSRMatrix // Matrix set by Setup
+}
+
+func init() {
+	Register("cg", func(opts Options) (LinearSolver, error) {
+		maxIter, tol, pc, err := opts.krylov()
+		if err != nil {
+			return nil, err
+		}
+		cg := NewCGSolver(maxIter, tol)
+		cg.Relative = true
+		cg.Preconditioner = pc
+		return cg, nil
+	})
+}
+
+// NewCGSolver creates a new Conjugate Gradient solver
+func NewCGSolver(maxIter int, tolerance float64) *CGSolver {
+	return &CGSolver{
+		MaxIter:   maxIter,
+		Tolerance: tolerance,
+	}
+}
+
+// Setup sets the matrix used by SolveFrom
+func (cg *CGSolver) Setup(A *matrix.CSRMatrix) error {
+	if A == nil {
+		return fmt.Errorf("matrix cannot be nil")
+	}
+	if A.Rows != A.Cols {
+		return fmt.Errorf("matrix must be square")
+	}
+	if cg.Preconditioner != nil {
+		if err := cg.Preconditioner.Setup(A); err != nil {
+			return err
+		}
+	}
+	cg.a = A
+	return nil
+}
+
+// SolveFrom solves the system Ax = b for the matrix set by Setup,
+// starting from x0
+func (cg *CGSolver) SolveFrom(b, x0 []float64) (Result, error) {
+	if cg.a == nil {
+		return Result{}, fmt.Errorf("solver has no matrix, call Setup first")
+	}
+	return cg.solve(cg.a, b, x0)
+}
+
+// Free releases the matrix
+func (cg *CGSolver) Free() {
+	cg.a = nil
+}
+
+// Solve solves the system Ax = b using the Conjugate Gradient method
+func (cg *CGSolver) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
+	if cg.Preconditioner != nil {
+		if err := cg.Preconditioner.Setup(A); err != nil {
+			return nil, err
+		}
+	}
+	res, err := cg.solve(A, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("maximum iterations reached without convergence")
+	}
+	return res.X, nil
+}
+
+func (cg *CGSolver) solve(A *matrix.CSRMatrix, b, x0 []float64) (Result, error) {
+	if A.Rows != len(b) {
+		return Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+
+	n := len(b)
+	if cg.Relative && norm(b) == 0 {
+		return zeroSolution(n), nil
+	}
+	x, err := initialGuess(x0, n)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// r = b - Ax
+	Ax, err := A.MatVec(x)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := make([]float64, n)
+	for i := range b {
+		r[i] = b[i] - Ax[i]
+	}
+
+	// z = M^-1 r; without a preconditioner z is r itself
+	z := r
+	if cg.Preconditioner != nil {
+		z = make([]float64, n)
+		cg.Preconditioner.Apply(z, r)
+	}
+
+	p := make([]float64, n)
+	copy(p, z)
+
+	rsold := dot(r, z)
+	rnorm := math.Sqrt(dot(r, r))
+
+	tol := cg.Tolerance
+	if cg.Relative {
+		tol *= math.Sqrt(dot(b, b))
+	}
+	res := Result{X: x, Residuals: []float64{rnorm}}
+	if rnorm < tol {
+		res.Converged = true
+		return res, nil
+	}
+
+	for iter := 0; iter < cg.MaxIter; iter++ {
+		Ap, err := A.MatVec(p)
+		if err != nil {
+			return Result{}, err
+		}
+
+		alpha := rsold / dot(p, Ap)
+
+		// x = x + alpha*p
+		for i := range x {
+			x[i] += alpha * p[i]
+		}
+
+		// r = r - alpha*Ap
+		for i := range r {
+			r[i] -= alpha * Ap[i]
+		}
+
+		rnorm = math.Sqrt(dot(r, r))
+		res.Iterations++
+		res.Residuals = append(res.Residuals, rnorm)
+		if rnorm < tol {
+			res.Converged = true
+			return res, nil
+		}
+
+		if cg.Preconditioner != nil {
+			cg.Preconditioner.Apply(z, r)
+		}
+		rsnew := dot(r, z)
+		beta := rsnew / rsold
+
+		// p = z + beta*p
+		for i := range p {
+			p[i] = z[i] + beta*p[i]
+		}
+
+		rsold = rsnew
+	}
+
+	return res, nil
+}
+
+// dot computes the dot product of two vectors
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// norm computes the Euclidean norm of a vector
+func norm(a []float64) float64 {
+	return math.Sqrt(dot(a, a))
+}
+
+// residual computes r = b - Ax
+func residual(A *matrix.CSRMatrix, b, x []float64) ([]float64, error) {
+	Ax, err := A.MatVec(x)
+	if err != nil {
+		return nil, err
+	}
+	for i := range Ax {
+		Ax[i] = b[i] - Ax[i]
+	}
+	return Ax, nil
+}
+
+// initialGuess returns a copy of x0, or zeros if x0 is nil
+func initialGuess(x0 []float64, n int) ([]float64, error) {
+	x := make([]float64, n)
+	if x0 != nil {
+		if len(x0) != n {
+			return nil, fmt.Errorf("initial guess has length %d, expected %d", len(x0), n)
+		}
+		copy(x, x0)
+	}
+	return x, nil
+}
+
+// zeroSolution returns x = 0, the exact solution of Ax = 0. A relative
+// tolerance scales to zero for b = 0 and could never be met by iterating.
+func zeroSolution(n int) Result {
+	return Result{X: make([]float64, n), Residuals: []float64{0}, Converged: true}
+}
@@ -0,0 +1,287 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// Side selects how GMRES applies the preconditioner
+type Side int
+
+const (
+	Right Side = iota // Solve A M^-1 y = b, x = M^-1 y; the residual is the true one
+	Left              // Solve M^-1 A x = M^-1 b
+)
+
+// GMRESSolver represents a restarted GMRES(m) solver for nonsymmetric systems
+type GMRESSolver struct {
+	MaxIter   int
+	Tolerance float64
+	Relative  bool // Compare ||r|| / ||b|| instead of ||r|| with Tolerance
+	Restart   int  // Krylov subspace size m, 30 if not positive
+
+	// Preconditioner is applied on the given Side if set; it is set up
+	// by Setup and by every call of Solve
+	Preconditioner Preconditioner
+	Side           Side
+	// Flexible stores the preconditioned vectors (FGMRES), so the
+	// preconditioner may change from one iteration to the next, e.g. an
+	// inner iterative solver. It implies right preconditioning.
+	Flexible bool
+
+	a *matrix.CSRMatrix // Matrix set by Setup
+}
+
+func init() {
+	factory := func(flexible bool) Factory {
+		return func(opts Options) (LinearSolver, error) {
+			maxIter, tol, pc, err := opts.krylov()
+			if err != nil {
+				return nil, err
+			}
+			g := NewGMRESSolver(maxIter, tol, opts.Restart)
+			g.Relative = true
+			g.Preconditioner = pc
+			g.Flexible = flexible
+			return g, nil
+		}
+	}
+	Register("gmres", factory(false))
+	Register("fgmres", factory(true))
+}
+
+// NewGMRESSolver creates a new GMRES solver restarted every restart iterations
+func NewGMRESSolver(maxIter int, tolerance float64, restart int) *GMRESSolver {
+	return &GMRESSolver{
+		MaxIter:   maxIter,
+		Tolerance: tolerance,
+		Restart:   restart,
+	}
+}
+
+// Setup sets the matrix used by SolveFrom
+func (g *GMRESSolver) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	if g.Preconditioner != nil {
+		if err := g.Preconditioner.Setup(A); err != nil {
+			return err
+		}
+	}
+	g.a = A
+	return nil
+}
+
+// SolveFrom solves the system Ax = b for the matrix set by Setup,
+// starting from x0
+func (g *GMRESSolver) SolveFrom(b, x0 []float64) (Result, error) {
+	if g.a == nil {
+		return Result{}, fmt.Errorf("solver has no matrix, call Setup first")
+	}
+	return g.solve(g.a, b, x0)
+}
+
+// Free releases the matrix
+func (g *GMRESSolver) Free() {
+	g.a = nil
+}
+
+// Solve solves the system Ax = b using the GMRES method
+func (g *GMRESSolver) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
+	if g.Preconditioner != nil {
+		if err := g.Preconditioner.Setup(A); err != nil {
+			return nil, err
+		}
+	}
+	res, err := g.solve(A, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("maximum iterations reached without convergence")
+	}
+	return res.X, nil
+}
+
+// solve runs GMRES cycles. Inside a cycle Residuals holds the estimates
+// from the least-squares problem, scaled to ||b - Ax|| for left
+// preconditioning; the last entry of every cycle is the true residual.
+func (g *GMRESSolver) solve(A *matrix.CSRMatrix, b, x0 []float64) (Result, error) {
+	if A.Rows != len(b) {
+		return Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+	n := len(b)
+	if g.Relative && norm(b) == 0 {
+		return zeroSolution(n), nil
+	}
+	x, err := initialGuess(x0, n)
+	if err != nil {
+		return Result{}, err
+	}
+	r, err := residual(A, b, x)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tol := g.Tolerance
+	if g.Relative {
+		tol *= norm(b)
+	}
+	rnorm := norm(r)
+	res := Result{X: x, Residuals: []float64{rnorm}}
+	if rnorm < tol {
+		res.Converged = true
+		return res, nil
+	}
+
+	m := g.Restart
+	if m <= 0 {
+		m = 30
+	}
+	if m > n {
+		m = n
+	}
+	pc := g.Preconditioner
+	left := pc != nil && g.Side == Left && !g.Flexible
+	right := pc != nil && !left
+
+	V := make([][]float64, m+1) // Orthonormal basis of the Krylov subspace
+	var Z [][]float64           // Preconditioned basis vectors of FGMRES
+	if g.Flexible && pc != nil {
+		Z = make([][]float64, m)
+	}
+	H := make([][]float64, m+1) // Hessenberg matrix, reduced to triangular by Givens rotations
+	for i := range H {
+		H[i] = make([]float64, m)
+	}
+	cs, sn := make([]float64, m), make([]float64, m)
+	s := make([]float64, m+1) // Right-hand side of the least-squares problem
+
+	for res.Iterations < g.MaxIter {
+		w := r
+		if left {
+			w = make([]float64, n)
+			pc.Apply(w, r)
+		}
+		beta := norm(w)
+		if beta == 0 || math.IsNaN(beta) {
+			break
+		}
+		scale := 1.0 // Converts preconditioned residual norms to ||b - Ax||
+		if left {
+			scale = rnorm / beta
+		}
+		V[0] = make([]float64, n)
+		for i := range w {
+			V[0][i] = w[i] / beta
+		}
+		for i := range s {
+			s[i] = 0
+		}
+		s[0] = beta
+
+		k := 0
+		for k < m && res.Iterations < g.MaxIter {
+			z := V[k]
+			if right {
+				z = make([]float64, n)
+				pc.Apply(z, V[k])
+				if Z != nil {
+					Z[k] = z
+				}
+			}
+			w, err := A.MatVec(z)
+			if err != nil {
+				return Result{}, err
+			}
+			if left {
+				pw := make([]float64, n)
+				pc.Apply(pw, w)
+				w = pw
+			}
+
+			// Modified Gram-Schmidt
+			for j := 0; j <= k; j++ {
+				h := dot(w, V[j])
+				H[j][k] = h
+				for i := range w {
+					w[i] -= h * V[j][i]
+				}
+			}
+			hnext := norm(w)
+			if hnext != 0 {
+				V[k+1] = w
+				for i := range w {
+					w[i] /= hnext
+				}
+			}
+
+			// Apply the previous rotations and eliminate H[k+1][k]
+			for j := 0; j < k; j++ {
+				H[j][k], H[j+1][k] = cs[j]*H[j][k]+sn[j]*H[j+1][k], -sn[j]*H[j][k]+cs[j]*H[j+1][k]
+			}
+			d := math.Hypot(H[k][k], hnext)
+			cs[k], sn[k] = H[k][k]/d, hnext/d
+			H[k][k] = d
+			s[k], s[k+1] = cs[k]*s[k], -sn[k]*s[k]
+
+			k++
+			res.Iterations++
+			est := math.Abs(s[k]) * scale
+			res.Residuals = append(res.Residuals, est)
+			if est < tol || hnext == 0 {
+				break
+			}
+		}
+
+		// Solve the triangular system H y = s and update x
+		y := make([]float64, k)
+		for i := k - 1; i >= 0; i-- {
+			sum := s[i]
+			for j := i + 1; j < k; j++ {
+				sum -= H[i][j] * y[j]
+			}
+			y[i] = sum / H[i][i]
+		}
+		switch {
+		case Z != nil:
+			for j := 0; j < k; j++ {
+				for i := range x {
+					x[i] += y[j] * Z[j][i]
+				}
+			}
+		case right:
+			u := make([]float64, n)
+			for j := 0; j < k; j++ {
+				for i := range u {
+					u[i] += y[j] * V[j][i]
+				}
+			}
+			z := make([]float64, n)
+			pc.Apply(z, u)
+			for i := range x {
+				x[i] += z[i]
+			}
+		default:
+			for j := 0; j < k; j++ {
+				for i := range x {
+					x[i] += y[j] * V[j][i]
+				}
+			}
+		}
+
+		if r, err = residual(A, b, x); err != nil {
+			return Result{}, err
+		}
+		rnorm = norm(r)
+		res.Residuals[len(res.Residuals)-1] = rnorm
+		if rnorm < tol {
+			res.Converged = true
+			return res, nil
+		}
+	}
+	return res, nil
+}
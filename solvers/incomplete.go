@@ -0,0 +1,180 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// ILU0 is the incomplete LU factorization with the sparsity pattern of A.
+// L has a unit diagonal and is stored below the diagonal of lu, U on and
+// above it.
+type ILU0 struct {
+	lu   *matrix.CSRMatrix
+	diag []int // Position of the diagonal entry in each row of lu
+}
+
+// NewILU0 creates an ILU(0) preconditioner
+func NewILU0() *ILU0 {
+	return &ILU0{}
+}
+
+// Setup factorizes A
+func (f *ILU0) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	lu := sorted(A)
+	diag, err := diagonalPositions(lu)
+	if err != nil {
+		return err
+	}
+	pos := make([]int, lu.Cols) // Position of column j in the current row, -1 if absent
+	for j := range pos {
+		pos[j] = -1
+	}
+	for i := 0; i < lu.Rows; i++ {
+		for k := lu.RowPtr[i]; k < lu.RowPtr[i+1]; k++ {
+			pos[lu.ColIndices[k]] = k
+		}
+		// Columns are sorted, so entries left of the diagonal come in order
+		for k := lu.RowPtr[i]; k < diag[i]; k++ {
+			c := lu.ColIndices[k]
+			lu.Values[k] /= lu.Values[diag[c]]
+			for q := diag[c] + 1; q < lu.RowPtr[c+1]; q++ {
+				if p := pos[lu.ColIndices[q]]; p >= 0 {
+					lu.Values[p] -= lu.Values[k] * lu.Values[q]
+				}
+			}
+		}
+		if lu.Values[diag[i]] == 0 {
+			return fmt.Errorf("zero pivot in row %d", i)
+		}
+		for k := lu.RowPtr[i]; k < lu.RowPtr[i+1]; k++ {
+			pos[lu.ColIndices[k]] = -1
+		}
+	}
+	f.lu, f.diag = lu, diag
+	return nil
+}
+
+// Apply solves L U z = r
+func (f *ILU0) Apply(z, r []float64) {
+	lu := f.lu
+	for i := 0; i < lu.Rows; i++ {
+		sum := r[i]
+		for k := lu.RowPtr[i]; k < f.diag[i]; k++ {
+			sum -= lu.Values[k] * z[lu.ColIndices[k]]
+		}
+		z[i] = sum
+	}
+	for i := lu.Rows - 1; i >= 0; i-- {
+		sum := z[i]
+		for k := f.diag[i] + 1; k < lu.RowPtr[i+1]; k++ {
+			sum -= lu.Values[k] * z[lu.ColIndices[k]]
+		}
+		z[i] = sum / lu.Values[f.diag[i]]
+	}
+}
+
+// IC0 is the incomplete Cholesky factorization L L^T with the sparsity
+// pattern of the lower triangle of a symmetric positive definite A
+type IC0 struct {
+	l *matrix.CSRMatrix // Lower triangle with the diagonal last in each row
+}
+
+// NewIC0 creates an IC(0) preconditioner
+func NewIC0() *IC0 {
+	return &IC0{}
+}
+
+// Setup factorizes the lower triangle of A
+func (f *IC0) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	full := sorted(A)
+	l := &matrix.CSRMatrix{Rows: A.Rows, Cols: A.Cols, RowPtr: make([]int, 1, A.Rows+1)}
+	for i := 0; i < full.Rows; i++ {
+		for k := full.RowPtr[i]; k < full.RowPtr[i+1] && full.ColIndices[k] <= i; k++ {
+			l.ColIndices = append(l.ColIndices, full.ColIndices[k])
+			l.Values = append(l.Values, full.Values[k])
+		}
+		if n := len(l.ColIndices); n == l.RowPtr[i] || l.ColIndices[n-1] != i {
+			return fmt.Errorf("zero diagonal entry in row %d", i)
+		}
+		l.RowPtr = append(l.RowPtr, len(l.Values))
+	}
+
+	for i := 0; i < l.Rows; i++ {
+		start, end := l.RowPtr[i], l.RowPtr[i+1]-1 // end is the diagonal
+		for k := start; k < end; k++ {
+			c := l.ColIndices[k]
+			// l_ic = (a_ic - sum_{j<c} l_ij l_cj) / l_cc over the common pattern
+			sum := l.Values[k]
+			p, q := start, l.RowPtr[c]
+			for p < k && q < l.RowPtr[c+1]-1 {
+				switch {
+				case l.ColIndices[p] < l.ColIndices[q]:
+					p++
+				case l.ColIndices[p] > l.ColIndices[q]:
+					q++
+				default:
+					sum -= l.Values[p] * l.Values[q]
+					p++
+					q++
+				}
+			}
+			l.Values[k] = sum / l.Values[l.RowPtr[c+1]-1]
+		}
+		d := l.Values[end]
+		for k := start; k < end; k++ {
+			d -= l.Values[k] * l.Values[k]
+		}
+		if d <= 0 {
+			return fmt.Errorf("matrix is not positive definite: pivot %g in row %d", d, i)
+		}
+		l.Values[end] = math.Sqrt(d)
+	}
+	f.l = l
+	return nil
+}
+
+// Apply solves L L^T z = r
+func (f *IC0) Apply(z, r []float64) {
+	l := f.l
+	for i := 0; i < l.Rows; i++ {
+		sum := r[i]
+		end := l.RowPtr[i+1] - 1
+		for k := l.RowPtr[i]; k < end; k++ {
+			sum -= l.Values[k] * z[l.ColIndices[k]]
+		}
+		z[i] = sum / l.Values[end]
+	}
+	for i := l.Rows - 1; i >= 0; i-- {
+		end := l.RowPtr[i+1] - 1
+		z[i] /= l.Values[end]
+		for k := l.RowPtr[i]; k < end; k++ {
+			z[l.ColIndices[k]] -= l.Values[k] * z[i]
+		}
+	}
+}
+
+// diagonalPositions returns the position of the diagonal entry in each
+// row of A
+func diagonalPositions(A *matrix.CSRMatrix) ([]int, error) {
+	diag := make([]int, A.Rows)
+	for i := 0; i < A.Rows; i++ {
+		diag[i] = -1
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if A.ColIndices[k] == i {
+				diag[i] = k
+			}
+		}
+		if diag[i] < 0 {
+			return nil, fmt.Errorf("zero diagonal entry in row %d", i)
+		}
+	}
+	return diag, nil
+}
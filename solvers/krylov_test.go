@@ -0,0 +1,238 @@
+package solvers
+
+import (
+	"math"
+	"testing"
+
+	"test.com/mat/matrix"
+)
+
+// convectionDiffusion returns the upwind discretization of
+// -u_xx + c u_x - u_yy on an n x n grid, which is nonsymmetric
+func convectionDiffusion(n int, c float64) *matrix.CSRMatrix {
+	var values []float64
+	var cols []int
+	rowPtr := []int{0}
+	h := 1 / float64(n+1)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			add := func(ii, jj int, v float64) {
+				if ii >= 0 && jj >= 0 && ii < n && jj < n {
+					values, cols = append(values, v), append(cols, jj*n+ii)
+				}
+			}
+			add(i, j-1, -1)
+			add(i-1, j, -1-c*h)
+			add(i, j, 4+c*h)
+			add(i+1, j, -1)
+			add(i, j+1, -1)
+			rowPtr = append(rowPtr, len(values))
+		}
+	}
+	A, _ := matrix.NewCSRMatrix(values, rowPtr, cols, n*n, n*n)
+	return A
+}
+
+// checkSolution verifies ||b - Ax|| <= tol ||b||
+func checkSolution(t *testing.T, name string, A *matrix.CSRMatrix, b []float64, res Result, tol float64) {
+	t.Helper()
+	if !res.Converged {
+		t.Errorf("%s did not converge in %d iterations", name, res.Iterations)
+		return
+	}
+	r, _ := residual(A, b, res.X)
+	if norm(r) > tol*norm(b) {
+		t.Errorf("%s: true residual %e above tolerance", name, norm(r)/norm(b))
+	}
+	if len(res.Residuals) != res.Iterations+1 {
+		t.Errorf("%s: %d residuals for %d iterations", name, len(res.Residuals), res.Iterations)
+	}
+}
+
+// innerGMRES is a preconditioner that changes with the residual:
+// a few unrestarted GMRES iterations on the matrix itself
+type innerGMRES struct{ a *matrix.CSRMatrix }
+
+func (p *innerGMRES) Setup(A *matrix.CSRMatrix) error {
+	p.a = A
+	return nil
+}
+
+func (p *innerGMRES) Apply(z, r []float64) {
+	res, _ := NewGMRESSolver(4, 0, 4).solve(p.a, r, nil)
+	copy(z, res.X)
+}
+
+func TestNonsymmetricSolvers(t *testing.T) {
+	A := convectionDiffusion(20, 200)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = 1 + math.Cos(float64(i))
+	}
+	const tol = 1e-8
+	gmres := func(pc Preconditioner, side Side, flexible bool) LinearSolver {
+		g := NewGMRESSolver(2000, tol, 20)
+		g.Relative, g.Preconditioner, g.Side, g.Flexible = true, pc, side, flexible
+		return g
+	}
+	bicgstab := func(pc Preconditioner) LinearSolver {
+		s := NewBiCGSTABSolver(2000, tol)
+		s.Relative, s.Preconditioner = true, pc
+		return s
+	}
+	cases := []struct {
+		name string
+		slv  LinearSolver
+	}{
+		{"BiCGSTAB", bicgstab(nil)},
+		{"BiCGSTAB+ILU0", bicgstab(NewILU0())},
+		{"GMRES", gmres(nil, Right, false)},
+		{"GMRES+ILU0 left", gmres(NewILU0(), Left, false)},
+		{"GMRES+ILU0 right", gmres(NewILU0(), Right, false)},
+		{"FGMRES+ILU0", gmres(NewILU0(), Right, true)},
+		{"FGMRES+inner GMRES", gmres(&innerGMRES{}, Right, true)},
+	}
+	iterations := make(map[string]int)
+	for _, c := range cases {
+		if err := c.slv.Setup(A); err != nil {
+			t.Fatalf("%s: Setup failed: %v", c.name, err)
+		}
+		res, err := c.slv.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("%s: SolveFrom failed: %v", c.name, err)
+		}
+		checkSolution(t, c.name, A, b, res, tol)
+		iterations[c.name] = res.Iterations
+	}
+	if iterations["GMRES+ILU0 right"] >= iterations["GMRES"] || iterations["BiCGSTAB+ILU0"] >= iterations["BiCGSTAB"] {
+		t.Errorf("ILU(0) does not reduce the iteration count: %v", iterations)
+	}
+}
+
+func TestGMRESFullSubspaceIsExact(t *testing.T) {
+	A := convectionDiffusion(4, 50)
+	b := make([]float64, A.Rows)
+	b[3] = 1
+	g := NewGMRESSolver(A.Rows, 1e-10, A.Rows)
+	g.Relative = true
+	if err := g.Setup(A); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	res, err := g.SolveFrom(b, nil)
+	if err != nil {
+		t.Fatalf("SolveFrom failed: %v", err)
+	}
+	checkSolution(t, "full GMRES", A, b, res, 1e-10)
+}
+
+func TestMINRESIndefinite(t *testing.T) {
+	// The shifted 1D Laplacian has eigenvalues in (-1, 3): CG cannot be
+	// used, but the matrix is symmetric
+	n := 60
+	A := laplacian1D(n)
+	for i := 0; i < n; i++ {
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if A.ColIndices[k] == i {
+				A.Values[k] -= 1
+			}
+		}
+	}
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = float64(i%5) - 2
+	}
+	for _, pc := range []Preconditioner{nil, NewJacobi()} {
+		s := NewMINRESSolver(1000, 1e-10)
+		s.Relative, s.Preconditioner = true, pc
+		if err := s.Setup(A); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		res, err := s.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("MINRES with %T failed: %v", pc, err)
+		}
+		checkSolution(t, "MINRES", A, b, res, 1e-10)
+		for k := 1; k < len(res.Residuals)-1; k++ {
+			if res.Residuals[k] > res.Residuals[k-1]*(1+1e-12) {
+				t.Errorf("MINRES residual grew at iteration %d", k)
+				break
+			}
+		}
+	}
+
+	// An indefinite preconditioner is rejected
+	neg := NewJacobi()
+	s := NewMINRESSolver(100, 1e-10)
+	s.Preconditioner = neg
+	if err := s.Setup(A); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	for i := range neg.inv {
+		neg.inv[i] = -1
+	}
+	if _, err := s.SolveFrom(b, nil); err == nil {
+		t.Error("Expected an error for an indefinite preconditioner")
+	}
+}
+
+func TestKrylovRegistry(t *testing.T) {
+	A := convectionDiffusion(8, 10)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = 1
+	}
+	for _, name := range []string{"bicgstab", "gmres", "fgmres"} {
+		slv, err := New(name, Options{Preconditioner: "ilu0", Restart: 10})
+		if err != nil {
+			t.Fatalf("New(%s) failed: %v", name, err)
+		}
+		if err := slv.Setup(A); err != nil {
+			t.Fatalf("%s: Setup failed: %v", name, err)
+		}
+		res, err := slv.SolveFrom(b, nil)
+		if err != nil {
+			t.Fatalf("%s: SolveFrom failed: %v", name, err)
+		}
+		checkSolution(t, name, A, b, res, 1e-8)
+		slv.Free()
+	}
+	if _, err := New("minres", Options{Preconditioner: "none"}); err == nil {
+		t.Error("Expected an error for an unknown preconditioner")
+	}
+}
+
+func TestKrylovZeroRHS(t *testing.T) {
+	A := laplacian1D(20)
+	b := make([]float64, A.Rows)
+	x0 := make([]float64, A.Rows)
+	for i := range x0 {
+		x0[i] = float64(i)
+	}
+	for _, name := range []string{"bicgstab", "gmres", "fgmres", "minres", "amg"} {
+		for _, pc := range []string{"", "jacobi"} {
+			slv, err := New(name, Options{Preconditioner: pc})
+			if err != nil {
+				t.Fatalf("New(%s) failed: %v", name, err)
+			}
+			if err := slv.Setup(A); err != nil {
+				t.Fatalf("%s: Setup failed: %v", name, err)
+			}
+			for _, guess := range [][]float64{nil, x0} {
+				res, err := slv.SolveFrom(b, guess)
+				if err != nil {
+					t.Fatalf("%s/%q: SolveFrom failed: %v", name, pc, err)
+				}
+				if !res.Converged {
+					t.Errorf("%s/%q: not converged for b = 0", name, pc)
+				}
+				for i, v := range res.X {
+					if v != 0 {
+						t.Errorf("%s/%q: x[%d] = %g, expected 0", name, pc, i, v)
+						break
+					}
+				}
+			}
+			slv.Free()
+		}
+	}
+}
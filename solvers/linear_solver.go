@@ -0,0 +1,103 @@
+package solvers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"test.com/mat/matrix"
+)
+
+// Result holds the outcome of a linear solve
+type Result struct {
+	X          []float64 // Solution
+	Iterations int       // Number of iterations performed
+	Residuals  []float64 // Residual norms ||b - Ax||, starting with the initial one
+	Converged  bool      // Whether the tolerance was reached
+}
+
+// LinearSolver is implemented by every linear solver backend
+type LinearSolver interface {
+	// Setup prepares the solver for the matrix A. It may be called again
+	// to switch to another matrix.
+	Setup(A *matrix.CSRMatrix) error
+	// SolveFrom solves Ax = b starting from x0 (zero if x0 is nil).
+	// Failure to converge is reported by Result.Converged, not by an error.
+	SolveFrom(b, x0 []float64) (Result, error)
+	// Free releases resources held by the solver
+	Free()
+}
+
+// Options configure a solver created by New. Zero values select
+// the defaults of the backend.
+type Options struct {
+	MaxIter   int     // Maximum number of iterations
+	Tolerance float64 // Relative tolerance ||b - Ax|| / ||b||
+	Restart   int     // Krylov subspace size of GMRES
+	// Preconditioner names a preconditioner of the Krylov solvers,
+	// see NewPreconditioner; other backends ignore it
+	Preconditioner string
+	// Params holds backend-specific settings, e.g. AMGCL property tree
+	// keys such as "solver.type"; the pure-Go solvers ignore it
+	Params map[string]string
+}
+
+// krylov returns the iteration limit, the tolerance and the preconditioner
+// of the pure-Go Krylov solvers, filling in their defaults
+func (opts Options) krylov() (int, float64, Preconditioner, error) {
+	maxIter, tol := opts.MaxIter, opts.Tolerance
+	if maxIter <= 0 {
+		maxIter = 1000
+	}
+	if tol <= 0 {
+		tol = 1e-8
+	}
+	pc, err := NewPreconditioner(opts.Preconditioner)
+	return maxIter, tol, pc, err
+}
+
+// Factory creates an unconfigured solver with the given options
+type Factory func(opts Options) (LinearSolver, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a backend available under name. It panics if the name
+// is already taken or factory is nil.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("solvers: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("solvers: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New creates a solver of the backend registered under name
+func New(name string, opts Options) (LinearSolver, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown linear solver %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return factory(opts)
+}
+
+// Names returns the sorted names of the registered backends
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
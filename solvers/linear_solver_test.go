@@ -0,0 +1,111 @@
+package solvers
+
+import (
+	"math"
+	"testing"
+
+	"test.com/mat/matrix"
+)
+
+// laplacian1D returns the tridiagonal 1D Laplacian matrix of size n
+func laplacian1D(n int) *matrix.CSRMatrix {
+	var values []float64
+	var cols []int
+	rowPtr := []int{0}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			values, cols = append(values, -1), append(cols, i-1)
+		}
+		values, cols = append(values, 2), append(cols, i)
+		if i < n-1 {
+			values, cols = append(values, -1), append(cols, i+1)
+		}
+		rowPtr = append(rowPtr, len(values))
+	}
+	A, _ := matrix.NewCSRMatrix(values, rowPtr, cols, n, n)
+	return A
+}
+
+func TestRegistry(t *testing.T) {
+	found := false
+	for _, name := range Names() {
+		found = found || name == "cg"
+	}
+	if !found {
+		t.Fatalf("cg is not registered: %v", Names())
+	}
+	if _, err := New("no-such-solver", Options{}); err == nil {
+		t.Error("Expected an error for an unknown solver")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic when registering cg twice")
+		}
+	}()
+	Register("cg", func(Options) (LinearSolver, error) { return nil, nil })
+}
+
+func TestLinearSolverCG(t *testing.T) {
+	n := 50
+	A := laplacian1D(n)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+	slv, err := New("cg", Options{Tolerance: 1e-10})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer slv.Free()
+	if _, err := slv.SolveFrom(b, nil); err == nil {
+		t.Error("Expected an error before Setup")
+	}
+	if err := slv.Setup(A); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	res, err := slv.SolveFrom(b, nil)
+	if err != nil {
+		t.Fatalf("SolveFrom failed: %v", err)
+	}
+	if !res.Converged || res.Iterations == 0 || len(res.Residuals) != res.Iterations+1 {
+		t.Fatalf("Unexpected result: converged %v after %d iterations, %d residuals",
+			res.Converged, res.Iterations, len(res.Residuals))
+	}
+	if last := res.Residuals[len(res.Residuals)-1]; last > 1e-10*math.Sqrt(float64(n)) {
+		t.Errorf("Final residual %e is above the tolerance", last)
+	}
+
+	// Starting from the solution takes no iterations
+	again, err := slv.SolveFrom(b, res.X)
+	if err != nil {
+		t.Fatalf("SolveFrom failed: %v", err)
+	}
+	if !again.Converged || again.Iterations != 0 {
+		t.Errorf("Restart from the solution took %d iterations", again.Iterations)
+	}
+
+	// A zero right-hand side gives x = 0 at once, even from a nonzero guess
+	zero, err := slv.SolveFrom(make([]float64, n), b)
+	if err != nil {
+		t.Fatalf("SolveFrom failed: %v", err)
+	}
+	if !zero.Converged || zero.Iterations != 0 {
+		t.Errorf("Zero right-hand side: converged %v after %d iterations", zero.Converged, zero.Iterations)
+	}
+	for i, v := range zero.X {
+		if v != 0 {
+			t.Fatalf("Zero right-hand side: x[%d] = %g, expected 0", i, v)
+		}
+	}
+
+	// Too few iterations are reported, not treated as an error
+	short, err := New("cg", Options{MaxIter: 3, Tolerance: 1e-10})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	short.Setup(A)
+	res, err = short.SolveFrom(b, nil)
+	if err != nil || res.Converged || res.Iterations != 3 {
+		t.Errorf("Expected an unconverged result after 3 iterations, got %v, %d, %v", res.Converged, res.Iterations, err)
+	}
+}
@@ -0,0 +1,223 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// MINRESSolver represents a MINRES solver for symmetric, possibly
+// indefinite systems such as saddle-point problems
+type MINRESSolver struct {
+	MaxIter   int
+	Tolerance float64
+	Relative  bool // Compare ||r|| / ||b|| instead of ||r|| with Tolerance
+
+	// Preconditioner must be symmetric positive definite (Jacobi, SSOR,
+	// IC(0)); it is set up by Setup and by every call of Solve
+	Preconditioner Preconditioner
+
+	a *matrix.CSRMatrix // Matrix set by Setup
+}
+
+func init() {
+	Register("minres", func(opts Options) (LinearSolver, error) {
+		maxIter, tol, pc, err := opts.krylov()
+		if err != nil {
+			return nil, err
+		}
+		s := NewMINRESSolver(maxIter, tol)
+		s.Relative = true
+		s.Preconditioner = pc
+		return s, nil
+	})
+}
+
+// NewMINRESSolver creates a new MINRES solver
+func NewMINRESSolver(maxIter int, tolerance float64) *MINRESSolver {
+	return &MINRESSolver{
+		MaxIter:   maxIter,
+		Tolerance: tolerance,
+	}
+}
+
+// Setup sets the matrix used by SolveFrom
+func (s *MINRESSolver) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	if s.Preconditioner != nil {
+		if err := s.Preconditioner.Setup(A); err != nil {
+			return err
+		}
+	}
+	s.a = A
+	return nil
+}
+
+// SolveFrom solves the system Ax = b for the matrix set by Setup,
+// starting from x0
+func (s *MINRESSolver) SolveFrom(b, x0 []float64) (Result, error) {
+	if s.a == nil {
+		return Result{}, fmt.Errorf("solver has no matrix, call Setup first")
+	}
+	return s.solve(s.a, b, x0)
+}
+
+// Free releases the matrix
+func (s *MINRESSolver) Free() {
+	s.a = nil
+}
+
+// Solve solves the system Ax = b using the MINRES method
+func (s *MINRESSolver) Solve(A *matrix.CSRMatrix, b []float64) ([]float64, error) {
+	if s.Preconditioner != nil {
+		if err := s.Preconditioner.Setup(A); err != nil {
+			return nil, err
+		}
+	}
+	res, err := s.solve(A, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("maximum iterations reached without convergence")
+	}
+	return res.X, nil
+}
+
+// solve follows the preconditioned MINRES of Paige and Saunders. The
+// recurrence gives the residual in the M^-1 norm; Residuals holds it scaled
+// to ||b - Ax||, and the true residual is checked before convergence is
+// reported.
+func (s *MINRESSolver) solve(A *matrix.CSRMatrix, b, x0 []float64) (Result, error) {
+	if A.Rows != len(b) {
+		return Result{}, fmt.Errorf("matrix and vector dimensions mismatch")
+	}
+	n := len(b)
+	if s.Relative && norm(b) == 0 {
+		return zeroSolution(n), nil
+	}
+	x, err := initialGuess(x0, n)
+	if err != nil {
+		return Result{}, err
+	}
+	r1, err := residual(A, b, x)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tol := s.Tolerance
+	if s.Relative {
+		tol *= norm(b)
+	}
+	rnorm := norm(r1)
+	res := Result{X: x, Residuals: []float64{rnorm}}
+	if rnorm < tol {
+		res.Converged = true
+		return res, nil
+	}
+
+	// precondition returns M^-1 v as a new vector
+	precondition := func(v []float64) []float64 {
+		z := make([]float64, n)
+		if s.Preconditioner == nil {
+			copy(z, v)
+		} else {
+			s.Preconditioner.Apply(z, v)
+		}
+		return z
+	}
+	// mnorm returns sqrt(r^T M^-1 r) given y = M^-1 r
+	mnorm := func(r, y []float64) (float64, error) {
+		d := dot(r, y)
+		if d < 0 {
+			return 0, fmt.Errorf("preconditioner is not positive definite")
+		}
+		return math.Sqrt(d), nil
+	}
+
+	y := precondition(r1)
+	beta1, err := mnorm(r1, y)
+	if err != nil {
+		return Result{}, err
+	}
+	scale := rnorm / beta1 // Converts M^-1 norms to ||b - Ax||
+
+	r2 := make([]float64, n)
+	copy(r2, r1)
+	w := make([]float64, n)
+	w1 := make([]float64, n)
+	w2 := make([]float64, n)
+	oldb, beta := 0.0, beta1
+	dbar, epsln, phibar := 0.0, 0.0, beta1
+	cs, sn := -1.0, 0.0
+
+	for res.Iterations < s.MaxIter {
+		// Lanczos step
+		v := make([]float64, n)
+		for i := range v {
+			v[i] = y[i] / beta
+		}
+		if y, err = A.MatVec(v); err != nil {
+			return Result{}, err
+		}
+		if res.Iterations > 0 {
+			for i := range y {
+				y[i] -= beta / oldb * r1[i]
+			}
+		}
+		alpha := dot(v, y)
+		for i := range y {
+			y[i] -= alpha / beta * r2[i]
+		}
+		r1, r2 = r2, y
+		y = precondition(r2)
+		oldb = beta
+		if beta, err = mnorm(r2, y); err != nil {
+			return Result{}, err
+		}
+
+		// Apply the previous rotation and compute the next one
+		oldeps := epsln
+		delta := cs*dbar + sn*alpha
+		gbar := sn*dbar - cs*alpha
+		epsln = sn * beta
+		dbar = -cs * beta
+		gamma := math.Hypot(gbar, beta)
+		if gamma == 0 {
+			gamma = math.SmallestNonzeroFloat64
+		}
+		cs, sn = gbar/gamma, beta/gamma
+		phi := cs * phibar
+		phibar *= sn
+
+		// Update the search direction and the solution
+		w1, w2, w = w2, w, w1
+		for i := range w {
+			w[i] = (v[i] - oldeps*w1[i] - delta*w2[i]) / gamma
+			x[i] += phi * w[i]
+		}
+
+		res.Iterations++
+		est := phibar * scale
+		res.Residuals = append(res.Residuals, est)
+		if est < tol || beta == 0 {
+			r, err := residual(A, b, x)
+			if err != nil {
+				return Result{}, err
+			}
+			rnorm = norm(r)
+			res.Residuals[len(res.Residuals)-1] = rnorm
+			if rnorm < tol {
+				res.Converged = true
+				return res, nil
+			}
+			if beta == 0 {
+				break // The Krylov subspace is exhausted
+			}
+		}
+	}
+	return res, nil
+}
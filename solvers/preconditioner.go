@@ -0,0 +1,284 @@
+package solvers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"test.com/mat/matrix"
+)
+
+// Preconditioner approximates the inverse of a matrix
+type Preconditioner interface {
+	// Setup builds the preconditioner for the matrix A
+	Setup(A *matrix.CSRMatrix) error
+	// Apply computes z = M^-1 r; z and r do not overlap
+	Apply(z, r []float64)
+}
+
+// NewPreconditioner creates a preconditioner by name: "jacobi",
+// "block-jacobi", "ssor", "ic0", "ilu0" or "amg". An empty name returns
+// nil, i.e. no preconditioning.
+func NewPreconditioner(name string) (Preconditioner, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "jacobi":
+		return NewJacobi(), nil
+	case "block-jacobi":
+		return NewBlockJacobi(4), nil
+	case "ssor":
+		return NewSSOR(1.0), nil
+	case "ic0":
+		return NewIC0(), nil
+	case "ilu0":
+		return NewILU0(), nil
+	case "amg":
+		return NewAMG(), nil
+	}
+	return nil, fmt.Errorf("unknown preconditioner %q", name)
+}
+
+// Jacobi divides by the diagonal of the matrix
+type Jacobi struct {
+	inv []float64 // Inverse diagonal
+}
+
+// NewJacobi creates a Jacobi (diagonal) preconditioner
+func NewJacobi() *Jacobi {
+	return &Jacobi{}
+}
+
+// Setup stores the inverse diagonal of A
+func (j *Jacobi) Setup(A *matrix.CSRMatrix) error {
+	diag, err := diagonal(A)
+	if err != nil {
+		return err
+	}
+	for i := range diag {
+		diag[i] = 1 / diag[i]
+	}
+	j.inv = diag
+	return nil
+}
+
+// Apply computes z = D^-1 r
+func (j *Jacobi) Apply(z, r []float64) {
+	for i := range z {
+		z[i] = j.inv[i] * r[i]
+	}
+}
+
+// BlockJacobi inverts the diagonal blocks of consecutive rows
+type BlockJacobi struct {
+	BlockSize int
+
+	size int         // Block size used by Setup
+	inv  [][]float64 // Dense inverses of the blocks, row-major
+}
+
+// NewBlockJacobi creates a block Jacobi preconditioner with blocks
+// of blockSize rows; the last block may be smaller
+func NewBlockJacobi(blockSize int) *BlockJacobi {
+	return &BlockJacobi{BlockSize: blockSize}
+}
+
+// Setup inverts the diagonal blocks of A
+func (bj *BlockJacobi) Setup(A *matrix.CSRMatrix) error {
+	if err := square(A); err != nil {
+		return err
+	}
+	if bj.BlockSize < 1 {
+		return fmt.Errorf("block size must be positive, got %d", bj.BlockSize)
+	}
+	n := A.Rows
+	bj.size, bj.inv = bj.BlockSize, bj.inv[:0]
+	for start := 0; start < n; start += bj.BlockSize {
+		m := bj.BlockSize
+		if start+m > n {
+			m = n - start
+		}
+		block := make([]float64, m*m)
+		for i := 0; i < m; i++ {
+			for k := A.RowPtr[start+i]; k < A.RowPtr[start+i+1]; k++ {
+				if j := A.ColIndices[k] - start; j >= 0 && j < m {
+					block[i*m+j] += A.Values[k]
+				}
+			}
+		}
+		inv, err := invert(block, m)
+		if err != nil {
+			return fmt.Errorf("block at row %d: %v", start, err)
+		}
+		bj.inv = append(bj.inv, inv)
+	}
+	return nil
+}
+
+// Apply multiplies r by the inverted blocks
+func (bj *BlockJacobi) Apply(z, r []float64) {
+	for b, inv := range bj.inv {
+		start, m := b*bj.size, bj.size
+		if start+m > len(r) {
+			m = len(r) - start
+		}
+		for i := 0; i < m; i++ {
+			sum := 0.0
+			for j := 0; j < m; j++ {
+				sum += inv[i*m+j] * r[start+j]
+			}
+			z[start+i] = sum
+		}
+	}
+}
+
+// invert returns the inverse of the dense m x m matrix a using
+// Gauss-Jordan elimination with partial pivoting
+func invert(a []float64, m int) ([]float64, error) {
+	inv := make([]float64, m*m)
+	for i := 0; i < m; i++ {
+		inv[i*m+i] = 1
+	}
+	for c := 0; c < m; c++ {
+		p := c
+		for i := c + 1; i < m; i++ {
+			if math.Abs(a[i*m+c]) > math.Abs(a[p*m+c]) {
+				p = i
+			}
+		}
+		if a[p*m+c] == 0 {
+			return nil, fmt.Errorf("singular diagonal block")
+		}
+		for j := 0; j < m; j++ {
+			a[c*m+j], a[p*m+j] = a[p*m+j], a[c*m+j]
+			inv[c*m+j], inv[p*m+j] = inv[p*m+j], inv[c*m+j]
+		}
+		d := a[c*m+c]
+		for j := 0; j < m; j++ {
+			a[c*m+j] /= d
+			inv[c*m+j] /= d
+		}
+		for i := 0; i < m; i++ {
+			if f := a[i*m+c]; i != c && f != 0 {
+				for j := 0; j < m; j++ {
+					a[i*m+j] -= f * a[c*m+j]
+					inv[i*m+j] -= f * inv[c*m+j]
+				}
+			}
+		}
+	}
+	return inv, nil
+}
+
+// SSOR is the symmetric successive over-relaxation preconditioner
+// M = (D + wL) D^-1 (D + wU) / (w (2 - w))
+type SSOR struct {
+	Omega float64 // Relaxation factor in (0, 2)
+
+	a    *matrix.CSRMatrix
+	diag []float64
+}
+
+// NewSSOR creates an SSOR preconditioner with relaxation factor omega
+func NewSSOR(omega float64) *SSOR {
+	return &SSOR{Omega: omega}
+}
+
+// Setup stores A and its diagonal
+func (s *SSOR) Setup(A *matrix.CSRMatrix) error {
+	if s.Omega <= 0 || s.Omega >= 2 {
+		return fmt.Errorf("SSOR relaxation factor must be in (0, 2), got %g", s.Omega)
+	}
+	diag, err := diagonal(A)
+	if err != nil {
+		return err
+	}
+	s.a, s.diag = A, diag
+	return nil
+}
+
+// Apply performs a forward and a backward relaxation sweep
+func (s *SSOR) Apply(z, r []float64) {
+	A, w := s.a, s.Omega
+	// (D + wL) y = r
+	for i := 0; i < A.Rows; i++ {
+		sum := r[i]
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if j := A.ColIndices[k]; j < i {
+				sum -= w * A.Values[k] * z[j]
+			}
+		}
+		z[i] = sum / s.diag[i]
+	}
+	// (D + wU) z = D y
+	for i := A.Rows - 1; i >= 0; i-- {
+		sum := s.diag[i] * z[i]
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if j := A.ColIndices[k]; j > i {
+				sum -= w * A.Values[k] * z[j]
+			}
+		}
+		z[i] = sum / s.diag[i]
+	}
+	for i := range z {
+		z[i] *= w * (2 - w)
+	}
+}
+
+// square checks that A is a square matrix
+func square(A *matrix.CSRMatrix) error {
+	if A == nil {
+		return fmt.Errorf("matrix cannot be nil")
+	}
+	if A.Rows != A.Cols {
+		return fmt.Errorf("matrix must be square")
+	}
+	return nil
+}
+
+// diagonal returns the diagonal of A, which must have no zero entries
+func diagonal(A *matrix.CSRMatrix) ([]float64, error) {
+	if err := square(A); err != nil {
+		return nil, err
+	}
+	diag := make([]float64, A.Rows)
+	for i := 0; i < A.Rows; i++ {
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if A.ColIndices[k] == i {
+				diag[i] += A.Values[k]
+			}
+		}
+		if diag[i] == 0 {
+			return nil, fmt.Errorf("zero diagonal entry in row %d", i)
+		}
+	}
+	return diag, nil
+}
+
+// sorted returns a copy of A with column indices sorted within each row
+// and duplicate entries summed
+func sorted(A *matrix.CSRMatrix) *matrix.CSRMatrix {
+	type entry struct {
+		col int
+		val float64
+	}
+	out := &matrix.CSRMatrix{Rows: A.Rows, Cols: A.Cols, RowPtr: make([]int, 1, A.Rows+1)}
+	var row []entry
+	for i := 0; i < A.Rows; i++ {
+		row = row[:0]
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			row = append(row, entry{A.ColIndices[k], A.Values[k]})
+		}
+		sort.Slice(row, func(a, b int) bool { return row[a].col < row[b].col })
+		for k, e := range row {
+			if k > 0 && e.col == row[k-1].col {
+				out.Values[len(out.Values)-1] += e.val
+				continue
+			}
+			out.ColIndices = append(out.ColIndices, e.col)
+			out.Values = append(out.Values, e.val)
+		}
+		out.RowPtr = append(out.RowPtr, len(out.Values))
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package solvers
+
+import (
+	"math"
+	"testing"
+
+	"test.com/mat/matrix"
+)
+
+// laplacian2D returns the 5-point Laplacian on an n x n grid with
+// a diffusion coefficient that jumps by a factor of 100
+func laplacian2D(n int) *matrix.CSRMatrix {
+	k := func(i, j int) float64 {
+		if i < n/2 && j < n/2 {
+			return 100
+		}
+		return 1
+	}
+	var values []float64
+	var cols []int
+	rowPtr := []int{0}
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			diag := 0.0
+			for _, d := range [][2]int{{0, -1}, {-1, 0}, {1, 0}, {0, 1}} {
+				ii, jj := i+d[0], j+d[1]
+				if ii < 0 || jj < 0 || ii >= n || jj >= n {
+					diag += 2 * k(i, j)
+					continue
+				}
+				c := 2 / (1/k(i, j) + 1/k(ii, jj))
+				diag += c
+				values, cols = append(values, -c), append(cols, jj*n+ii)
+			}
+			// Unsorted rows: the diagonal goes last
+			values, cols = append(values, diag), append(cols, j*n+i)
+			rowPtr = append(rowPtr, len(values))
+		}
+	}
+	A, _ := matrix.NewCSRMatrix(values, rowPtr, cols, n*n, n*n)
+	return A
+}
+
+func TestPreconditionedCG(t *testing.T) {
+	A := laplacian2D(30)
+	b := make([]float64, A.Rows)
+	for i := range b {
+		b[i] = math.Sin(float64(i))
+	}
+	iterations := func(pc Preconditioner) int {
+		t.Helper()
+		cg := NewCGSolver(5000, 1e-8)
+		cg.Relative = true
+		cg.Preconditioner = pc
+		if err := cg.Setup(A); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+		res, err := cg.SolveFrom(b, nil)
+		if err != nil || !res.Converged {
+			t.Fatalf("CG with %T did not converge: %v", pc, err)
+		}
+		Ax, _ := A.MatVec(res.X)
+		r := 0.0
+		for i := range b {
+			r += (b[i] - Ax[i]) * (b[i] - Ax[i])
+		}
+		if math.Sqrt(r) > 1e-7*math.Sqrt(dot(b, b)) {
+			t.Errorf("CG with %T: true residual %e", pc, math.Sqrt(r))
+		}
+		return res.Iterations
+	}
+	plain := iterations(nil)
+	for _, pc := range []Preconditioner{NewJacobi(), NewBlockJacobi(30), NewSSOR(1.2), NewIC0(), NewILU0()} {
+		if it := iterations(pc); it >= plain {
+			t.Errorf("%T: %d iterations, plain CG needs %d", pc, it, plain)
+		}
+	}
+}
+
+func TestIncompleteFactorizationsExact(t *testing.T) {
+	// Tridiagonal matrices have no fill-in, so IC(0) and ILU(0) are exact
+	A := laplacian1D(20)
+	r := make([]float64, A.Rows)
+	for i := range r {
+		r[i] = float64(i%3) - 1
+	}
+	for _, pc := range []Preconditioner{NewIC0(), NewILU0()} {
+		if err := pc.Setup(A); err != nil {
+			t.Fatalf("%T: Setup failed: %v", pc, err)
+		}
+		z := make([]float64, A.Rows)
+		pc.Apply(z, r)
+		Az, _ := A.MatVec(z)
+		for i := range r {
+			if math.Abs(Az[i]-r[i]) > 1e-12 {
+				t.Fatalf("%T: A M^-1 r differs from r in row %d: %g vs %g", pc, i, Az[i], r[i])
+			}
+		}
+	}
+}
+
+func TestPreconditionerErrors(t *testing.T) {
+	indefinite, _ := matrix.NewCSRMatrix([]float64{1, 2, 2, 1}, []int{0, 2, 4}, []int{0, 1, 0, 1}, 2, 2)
+	if err := NewIC0().Setup(indefinite); err == nil {
+		t.Error("IC0: expected an error for an indefinite matrix")
+	}
+	noDiag, _ := matrix.NewCSRMatrix([]float64{1, 1}, []int{0, 1, 2}, []int{1, 0}, 2, 2)
+	for _, pc := range []Preconditioner{NewJacobi(), NewSSOR(1), NewIC0(), NewILU0(), NewBlockJacobi(1)} {
+		if err := pc.Setup(noDiag); err == nil {
+			t.Errorf("%T: expected an error for a zero diagonal", pc)
+		}
+	}
+	if _, err := NewPreconditioner("amg?"); err == nil {
+		t.Error("Expected an error for an unknown preconditioner")
+	}
+	slv, err := New("cg", Options{Preconditioner: "ilu0"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if cg := slv.(*CGSolver); cg.Preconditioner == nil {
+		t.Error("Preconditioner option was ignored")
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import "fmt"
+
+// ScalarFunc — скалярная функция координат (граничные значения, коэффициенты и т.п.)
+type ScalarFunc func(p Point) float64
+
+// TimeFunc — скалярная функция координат и времени
+type TimeFunc func(p Point, t float64) float64
+
+// Const возвращает функцию, тождественно равную v
+func Const(v float64) ScalarFunc {
+	return func(p Point) float64 {
+		return v
+	}
+}
+
+// Типы граничных условий (совпадают со значениями, принимаемыми Set_bnd_type)
+const (
+	Dirichlet = 1 // u = g
+	Neumann   = 2 // du/dn = g
+	Robin     = 3 // du/dn + a*u = g
+)
+
+// BoundaryCondition описывает граничное условие на участке границы.
+// Нормаль n везде внешняя, для задачи с коэффициентом k условия Неймана
+// и Робена задают поток k*du/dn.
+type BoundaryCondition struct {
+	Type    int
+	Value   ScalarFunc // g
+	Value_t TimeFunc   // g(x, t) для нестационарных задач, имеет приоритет над Value
+	Alpha   ScalarFunc // a, используется только для условия Робена
+}
+
+// DirichletBC задаёт условие u = g
+func DirichletBC(g ScalarFunc) BoundaryCondition {
+	return BoundaryCondition{Type: Dirichlet, Value: g}
+}
+
+// NeumannBC задаёт условие du/dn = g
+func NeumannBC(g ScalarFunc) BoundaryCondition {
+	return BoundaryCondition{Type: Neumann, Value: g}
+}
+
+// RobinBC задаёт условие du/dn + a*u = g
+func RobinBC(a ScalarFunc, g ScalarFunc) BoundaryCondition {
+	return BoundaryCondition{Type: Robin, Value: g, Alpha: a}
+}
+
+// With_time возвращает копию условия с зависящим от времени значением g(x, t)
+func (bc BoundaryCondition) With_time(g TimeFunc) BoundaryCondition {
+	bc.Value_t = g
+	return bc
+}
+
+// value возвращает граничное значение в точке p в момент t
+func (bc BoundaryCondition) value(p Point, t float64) float64 {
+	if bc.Value_t != nil {
+		return bc.Value_t(p, t)
+	}
+	return bc.Value(p)
+}
+
+func (bc BoundaryCondition) check() error {
+	if bc.Type < Dirichlet || bc.Type > Robin {
+		return fmt.Errorf("this type is not exist")
+	}
+	if bc.Value == nil && bc.Value_t == nil {
+		return fmt.Errorf("граничное значение не задано")
+	}
+	if bc.Type == Robin && bc.Alpha == nil {
+		return fmt.Errorf("коэффициент условия Робена не задан")
+	}
+	return nil
+}
+
+// default_bc возвращает условие для граней, не покрытых ни одним участком,
+// по типу из Set_bnd_type. Данные берутся из точного решения тестовой задачи.
+func default_bc(bnd int) BoundaryCondition {
+	switch bnd {
+	case Dirichlet:
+		return DirichletBC(exact_solution)
+	case Robin:
+		return RobinBC(Const(1.0), func(p Point) float64 {
+			return exact_dudn(p) + exact_solution(p)
+		})
+	default:
+		return NeumannBC(exact_dudn)
+	}
+}
+
+// BndPatch — именованный участок границы
+type BndPatch struct {
+	Name  string
+	Faces []int // индексы граней в Faces_bnd_cel
+}
+
+// Bnd_face_center возвращает центр граничной грани с номером i
+func (grid *VTKGrid) Bnd_face_center(i int) Point {
+	if nodes := grid.Face_nodes[grid.Faces_bnd_id[i]]; len(nodes) > 2 {
+		_, center := grid.polygon_area(nodes)
+		return center
+	}
+	face := grid.Faces_bnd_cel[i]
+	pi := grid.Points[face[0].Left]
+	pj := grid.Points[face[0].Right]
+	return Point{X: pi.X/2.0 + pj.X/2.0, Y: pi.Y/2.0 + pj.Y/2.0, Z: pi.Z/2.0 + pj.Z/2.0}
+}
+
+// Add_bnd_patch создаёт участок границы с именем name из граничных граней,
+// середины которых удовлетворяют sel. Грани, уже принадлежащие другому участку,
+// не переназначаются, поэтому участки следует добавлять от частных к общим.
+func (grid *VTKGrid) Add_bnd_patch(name string, sel func(p Point) bool) error {
+	if grid.Patch(name) != nil {
+		return fmt.Errorf("участок границы %q уже существует", name)
+	}
+	taken := make([]bool, len(grid.Faces_bnd_cel))
+	for _, patch := range grid.Bnd_patches {
+		for _, f := range patch.Faces {
+			taken[f] = true
+		}
+	}
+	patch := BndPatch{Name: name}
+	for i := range grid.Faces_bnd_cel {
+		if !taken[i] && sel(grid.Bnd_face_center(i)) {
+			patch.Faces = append(patch.Faces, i)
+		}
+	}
+	if len(patch.Faces) == 0 {
+		return fmt.Errorf("участок границы %q не содержит ни одной грани", name)
+	}
+	grid.Bnd_patches = append(grid.Bnd_patches, patch)
+	return nil
+}
+
+// Patch возвращает участок границы по имени или nil, если такого нет
+func (grid *VTKGrid) Patch(name string) *BndPatch {
+	for i := range grid.Bnd_patches {
+		if grid.Bnd_patches[i].Name == name {
+			return &grid.Bnd_patches[i]
+		}
+	}
+	return nil
+}
+
+// Set_bc задаёт граничное условие на участке границы patch
+func (solver *Solver) Set_bc(patch string, bc BoundaryCondition) error {
+	if err := bc.check(); err != nil {
+		return err
+	}
+	if solver.bcs == nil {
+		solver.bcs = make(map[string]BoundaryCondition)
+	}
+	solver.bcs[patch] = bc
+	return nil
+}
+
+// face_bcs сопоставляет каждой граничной грани её граничное условие
+func (solver *Solver) face_bcs() ([]BoundaryCondition, error) {
+	bcs := make([]BoundaryCondition, len(solver.grid.Faces_bnd_cel))
+	def := default_bc(solver.bnd)
+	if solver.problem != nil {
+		def = BoundaryCondition{Type: Neumann, Value: Const(0.0), Alpha: Const(0.0)}
+		if solver.bnd != 0 {
+			def.Type = solver.bnd
+		}
+	}
+	for i := range bcs {
+		bcs[i] = def
+	}
+	for name, bc := range solver.bcs {
+		patch := solver.grid.Patch(name)
+		if patch == nil {
+			return nil, fmt.Errorf("участок границы %q не найден", name)
+		}
+		for _, f := range patch.Faces {
+			bcs[f] = bc
+		}
+	}
+	return bcs, nil
+}
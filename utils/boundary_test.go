@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// Manufactured solution u = sin(pi x) sin(pi y) + x + y^2 of -lap u = f
+// on the unit square
+func bc_exact(p Point) float64 {
+	return math.Sin(math.Pi*p.X)*math.Sin(math.Pi*p.Y) + p.X + p.Y*p.Y
+}
+
+func bc_source(p Point) float64 {
+	return 2*math.Pi*math.Pi*math.Sin(math.Pi*p.X)*math.Sin(math.Pi*p.Y) - 2
+}
+
+// bc_dudn returns du/dn with the outward normal of the side containing p
+func bc_dudn(p Point) float64 {
+	dx := math.Pi*math.Cos(math.Pi*p.X)*math.Sin(math.Pi*p.Y) + 1
+	dy := math.Pi*math.Sin(math.Pi*p.X)*math.Cos(math.Pi*p.Y) + 2*p.Y
+	switch {
+	case p.X < 1e-9:
+		return -dx
+	case p.X > 1-1e-9:
+		return dx
+	case p.Y < 1e-9:
+		return -dy
+	}
+	return dy
+}
+
+func TestBoundaryConditionsManufactured(t *testing.T) {
+	dirichlet := DirichletBC(bc_exact)
+	neumann := NeumannBC(bc_dudn)
+	robin := RobinBC(Const(2.0), func(p Point) float64 { return bc_dudn(p) + 2*bc_exact(p) })
+	cases := []struct {
+		name     string
+		bcs      [4]BoundaryCondition // left, right, bottom, top
+		floating bool                 // the solution is defined up to a constant
+	}{
+		{"Dirichlet", [4]BoundaryCondition{dirichlet, dirichlet, dirichlet, dirichlet}, false},
+		{"Neumann", [4]BoundaryCondition{neumann, neumann, neumann, neumann}, true},
+		{"Robin", [4]BoundaryCondition{robin, robin, robin, robin}, false},
+		{"mixed", [4]BoundaryCondition{dirichlet, neumann, robin, neumann}, false},
+	}
+	sides := []struct {
+		name string
+		sel  func(p Point) bool
+	}{
+		{"left", func(p Point) bool { return p.X < 1e-9 }},
+		{"right", func(p Point) bool { return p.X > 1-1e-9 }},
+		{"bottom", func(p Point) bool { return p.Y < 1e-9 }},
+		{"top", func(p Point) bool { return true }},
+	}
+	for _, c := range cases {
+		var errs [2]float64
+		for level, n := range []int{16, 32} {
+			grid, err := Rect_grid(0, 0, 1, 1, n, n, nil)
+			if err != nil {
+				t.Fatalf("Rect_grid failed: %v", err)
+			}
+			for _, side := range sides {
+				if err := grid.Add_bnd_patch(side.name, side.sel); err != nil {
+					t.Fatalf("Add_bnd_patch failed: %v", err)
+				}
+			}
+			var solver Solver
+			solver.Set_grid(grid)
+			if err := solver.Set_problem(Problem{Source: bc_source, Exact: bc_exact}); err != nil {
+				t.Fatalf("Set_problem failed: %v", err)
+			}
+			for i, side := range sides {
+				if err := solver.Set_bc(side.name, c.bcs[i]); err != nil {
+					t.Fatalf("Set_bc failed: %v", err)
+				}
+			}
+			if err := solver.Approximate_parts(); err != nil {
+				t.Fatalf("%s: Approximate_parts failed: %v", c.name, err)
+			}
+			x := solver.Solution()
+			if len(x) != len(grid.Cells) {
+				t.Fatalf("%s: no solution", c.name)
+			}
+			e := make([]float64, len(x))
+			mean, area := 0.0, 0.0
+			for i := range e {
+				e[i] = x[i] - bc_exact(grid.Cell_centers[i])
+				mean += e[i] * grid.Cell_volumes[i]
+				area += grid.Cell_volumes[i]
+			}
+			if !c.floating {
+				mean = 0
+			}
+			for i := range e {
+				d := e[i] - mean/area
+				errs[level] += d * d * grid.Cell_volumes[i]
+			}
+			errs[level] = math.Sqrt(errs[level])
+		}
+		order := math.Log2(errs[0] / errs[1])
+		if errs[1] > 5e-3 || order < 1.8 {
+			t.Errorf("%s: L2 error %.3e -> %.3e, order %.2f", c.name, errs[0], errs[1], order)
+		}
+	}
+}
+
+func TestBoundaryConditionErrors(t *testing.T) {
+	for _, bc := range []BoundaryCondition{
+		{Type: 7, Value: Const(0)},
+		{Type: Dirichlet},
+		{Type: Robin, Value: Const(0)},
+	} {
+		var solver Solver
+		if err := solver.Set_bc("all", bc); err == nil {
+			t.Errorf("Expected an error for %+v", bc)
+		}
+	}
+	grid, err := Rect_grid(0, 0, 1, 1, 4, 4, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("left", func(p Point) bool { return p.X < 1e-9 }); err != nil {
+		t.Fatalf("Add_bnd_patch failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("left", func(p Point) bool { return true }); err == nil {
+		t.Error("Expected an error for a duplicate patch")
+	}
+	if err := grid.Add_bnd_patch("none", func(p Point) bool { return p.X > 2 }); err == nil {
+		t.Error("Expected an error for an empty patch")
+	}
+	// An unknown patch is reported by Approximate_parts instead of being ignored
+	var solver Solver
+	solver.Set_grid(grid)
+	if err := solver.Set_bc("missing", DirichletBC(Const(0))); err != nil {
+		t.Fatalf("Set_bc failed: %v", err)
+	}
+	if err := solver.Approximate_parts(); err == nil {
+		t.Error("Expected an error for a boundary condition on a missing patch")
+	}
+}
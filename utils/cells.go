@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Типы ячеек VTK
+const (
+	VTKTriangle   = 5
+	VTKPolygon    = 7
+	VTKQuad       = 9
+	VTKTetra      = 10
+	VTKHexahedron = 12
+	VTKWedge      = 13
+	VTKPyramid    = 14
+)
+
+// cell_faces — грани объёмных ячеек в локальной нумерации узлов VTK
+// (узлы каждой грани перечислены по обходу, нормаль направлена наружу)
+var cell_faces = map[int][][]int{
+	VTKTetra: {{0, 2, 1}, {0, 1, 3}, {1, 2, 3}, {2, 0, 3}},
+	VTKHexahedron: {{0, 3, 2, 1}, {4, 5, 6, 7}, {0, 1, 5, 4},
+		{1, 2, 6, 5}, {2, 3, 7, 6}, {3, 0, 4, 7}},
+	VTKWedge:   {{0, 1, 2}, {3, 5, 4}, {0, 3, 4, 1}, {1, 4, 5, 2}, {2, 5, 3, 0}},
+	VTKPyramid: {{0, 3, 2, 1}, {0, 1, 4}, {1, 2, 4}, {2, 3, 4}, {3, 0, 4}},
+}
+
+// cell_nodes — число узлов объёмных ячеек
+var cell_nodes = map[int]int{VTKTetra: 4, VTKHexahedron: 8, VTKWedge: 6, VTKPyramid: 5}
+
+// cell_type возвращает тип ячейки i; без CELL_TYPES ячейка считается многоугольником
+func (grid *VTKGrid) cell_type(i int) int {
+	if len(grid.CellTypes) == 0 {
+		return VTKPolygon
+	}
+	return grid.CellTypes[i]
+}
+
+// check_cells определяет размерность сетки и проверяет число типов ячеек,
+// число узлов объёмных ячеек и номера узлов
+func (grid *VTKGrid) check_cells() error {
+	grid.Dim = 2
+	if len(grid.CellTypes) != 0 && len(grid.CellTypes) != len(grid.Cells) {
+		return fmt.Errorf("число типов ячеек %d не совпадает с числом ячеек %d",
+			len(grid.CellTypes), len(grid.Cells))
+	}
+	for i := range grid.Cells {
+		for _, n := range grid.Cells[i].Indices {
+			if n < 0 || n >= len(grid.Points) {
+				return fmt.Errorf("ячейка %d ссылается на несуществующий узел %d (узлов %d)",
+					i, n, len(grid.Points))
+			}
+		}
+		t := grid.cell_type(i)
+		n, ok := cell_nodes[t]
+		dim := 2
+		if ok {
+			dim = 3
+			if len(grid.Cells[i].Indices) != n {
+				return fmt.Errorf("ячейка %d типа %d должна иметь %d узлов, а не %d",
+					i, t, n, len(grid.Cells[i].Indices))
+			}
+		}
+		if i == 0 {
+			grid.Dim = dim
+		} else if dim != grid.Dim {
+			return fmt.Errorf("сетка содержит ячейки разной размерности (ячейка %d типа %d)", i, t)
+		}
+	}
+	return nil
+}
+
+// local_faces возвращает грани ячейки i как списки глобальных номеров узлов:
+// рёбра многоугольника для плоской сетки и грани по таблице VTK для объёмной
+func (grid *VTKGrid) local_faces(i int) [][]int {
+	cell := grid.Cells[i].Indices
+	table, ok := cell_faces[grid.cell_type(i)]
+	if !ok {
+		faces := make([][]int, len(cell))
+		for j := range cell {
+			faces[j] = []int{cell[j], cell[(j+1)%len(cell)]}
+		}
+		return faces
+	}
+	faces := make([][]int, len(table))
+	for j, local := range table {
+		faces[j] = make([]int, len(local))
+		for k, n := range local {
+			faces[j][k] = cell[n]
+		}
+	}
+	return faces
+}
+
+// face_key возвращает ключ грани по отсортированному набору её узлов
+func face_key(nodes []int) [4]int {
+	key := [4]int{-1, -1, -1, -1}
+	copy(key[:], nodes)
+	n := len(nodes)
+	for i := 1; i < n; i++ {
+		for j := i; j > 0 && key[j] < key[j-1]; j-- {
+			key[j], key[j-1] = key[j-1], key[j]
+		}
+	}
+	return key
+}
+
+func cross(a Point, b Point) Point {
+	return Point{a.Y*b.Z - a.Z*b.Y, a.Z*b.X - a.X*b.Z, a.X*b.Y - a.Y*b.X}
+}
+
+func (grid *VTKGrid) average(nodes []int) Point {
+	c := Point{}
+	for _, n := range nodes {
+		p := grid.Points[n]
+		c = Point{c.X + p.X, c.Y + p.Y, c.Z + p.Z}
+	}
+	k := float64(len(nodes))
+	return Point{c.X / k, c.Y / k, c.Z / k}
+}
+
+// polygon_area возвращает вектор площади и центр многоугольной грани,
+// разбитой на треугольники со средней точкой узлов
+func (grid *VTKGrid) polygon_area(nodes []int) (Point, Point) {
+	fc := grid.average(nodes)
+	S := Point{}
+	center := Point{}
+	sum := 0.0
+	for k := range nodes {
+		a := grid.Points[nodes[k]]
+		b := grid.Points[nodes[(k+1)%len(nodes)]]
+		s := cross(sub(a, fc), sub(b, fc))
+		S = Point{S.X + 0.5*s.X, S.Y + 0.5*s.Y, S.Z + 0.5*s.Z}
+		area := 0.5 * math.Sqrt(dot(s, s))
+		center = Point{
+			center.X + area*(fc.X+a.X+b.X)/3.0,
+			center.Y + area*(fc.Y+a.Y+b.Y)/3.0,
+			center.Z + area*(fc.Z+a.Z+b.Z)/3.0,
+		}
+		sum += area
+	}
+	if sum == 0 {
+		return S, fc
+	}
+	return S, Point{center.X / sum, center.Y / sum, center.Z / sum}
+}
+
+// polyhedron_volume возвращает объём и центр объёмной ячейки i, разбитой
+// на тетраэдры с вершинами в средней точке ячейки, центре грани и двух
+// соседних узлах грани
+func (grid *VTKGrid) polyhedron_volume(i int) (float64, Point) {
+	xc := grid.average(grid.Cells[i].Indices)
+	volume := 0.0
+	center := Point{}
+	for _, nodes := range grid.local_faces(i) {
+		fc := grid.average(nodes)
+		for k := range nodes {
+			a := grid.Points[nodes[k]]
+			b := grid.Points[nodes[(k+1)%len(nodes)]]
+			v := math.Abs(dot(sub(fc, xc), cross(sub(a, xc), sub(b, xc)))) / 6.0
+			volume += v
+			center = Point{
+				center.X + v*(xc.X+fc.X+a.X+b.X)/4.0,
+				center.Y + v*(xc.Y+fc.Y+a.Y+b.Y)/4.0,
+				center.Z + v*(xc.Z+fc.Z+a.Z+b.Z)/4.0,
+			}
+		}
+	}
+	return volume, Point{center.X / volume, center.Y / volume, center.Z / volume}
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func singleCell(t *testing.T, cellType int, points []Point) VTKGrid {
+	indices := make([]int, len(points))
+	for i := range indices {
+		indices[i] = i
+	}
+	grid := VTKGrid{Points: points, Cells: []Cell{{Indices: indices}}, CellTypes: []int{cellType}}
+	if err := grid.build_faces(); err != nil {
+		t.Fatalf("build_faces failed: %v", err)
+	}
+	grid.Need_face_geometry()
+	return grid
+}
+
+func TestPolyhedralCells(t *testing.T) {
+	cases := []struct {
+		name   string
+		kind   int
+		points []Point
+		volume float64
+		center Point
+		faces  int
+	}{
+		{"tetra", VTKTetra, []Point{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+			1.0 / 6.0, Point{0.25, 0.25, 0.25}, 4},
+		{"hexahedron", VTKHexahedron, []Point{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+			{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}},
+			1.0, Point{0.5, 0.5, 0.5}, 6},
+		{"wedge", VTKWedge, []Point{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 1}, {0, 1, 1}},
+			0.5, Point{1.0 / 3.0, 1.0 / 3.0, 0.5}, 5},
+		{"pyramid", VTKPyramid, []Point{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0.5, 0.5, 1}},
+			1.0 / 3.0, Point{0.5, 0.5, 0.25}, 5},
+	}
+	for _, c := range cases {
+		grid := singleCell(t, c.kind, c.points)
+		if grid.Dim != 3 {
+			t.Errorf("%s: Dim = %d, expected 3", c.name, grid.Dim)
+		}
+		if math.Abs(grid.Cell_volumes[0]-c.volume) > 1e-12 {
+			t.Errorf("%s: volume %g, expected %g", c.name, grid.Cell_volumes[0], c.volume)
+		}
+		d := sub(grid.Cell_centers[0], c.center)
+		if math.Sqrt(dot(d, d)) > 1e-12 {
+			t.Errorf("%s: centroid %v, expected %v", c.name, grid.Cell_centers[0], c.center)
+		}
+		if len(grid.Faces_bnd_cel) != c.faces {
+			t.Errorf("%s: %d boundary faces, expected %d", c.name, len(grid.Faces_bnd_cel), c.faces)
+		}
+		// A closed cell has a zero sum of outward face area vectors
+		sum := Point{}
+		for i := range grid.Faces_bnd_cel {
+			g := grid.Bnd_geometry(i)
+			sum = Point{sum.X + g.Area*g.Normal.X, sum.Y + g.Area*g.Normal.Y, sum.Z + g.Area*g.Normal.Z}
+			if dot(g.D_own, g.Normal) <= 0 {
+				t.Errorf("%s: face %d normal points inward", c.name, i)
+			}
+		}
+		if math.Sqrt(dot(sum, sum)) > 1e-12 {
+			t.Errorf("%s: face area vectors sum to %v", c.name, sum)
+		}
+	}
+}
+
+func TestMixedCellMatching(t *testing.T) {
+	// A pyramid and a tetrahedron sharing the triangle (1, 2, 4)
+	grid := VTKGrid{
+		Points:    []Point{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0.5, 0.5, 1}, {1.5, 0.5, 0.5}},
+		Cells:     []Cell{{Indices: []int{0, 1, 2, 3, 4}}, {Indices: []int{2, 1, 4, 5}}},
+		CellTypes: []int{VTKPyramid, VTKTetra},
+	}
+	if err := grid.build_faces(); err != nil {
+		t.Fatalf("build_faces failed: %v", err)
+	}
+	if len(grid.Faces_in_cel) != 1 || len(grid.Faces_bnd_cel) != 7 {
+		t.Fatalf("Got %d interior and %d boundary faces, expected 1 and 7",
+			len(grid.Faces_in_cel), len(grid.Faces_bnd_cel))
+	}
+	if nodes := grid.Face_nodes[grid.Faces_in_id[0]]; face_key(nodes) != [4]int{1, 2, 4, -1} {
+		t.Errorf("Interior face has nodes %v", nodes)
+	}
+}
+
+func TestMixedDimensionRejected(t *testing.T) {
+	grid := VTKGrid{
+		Points:    []Point{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+		Cells:     []Cell{{Indices: []int{0, 1, 2, 3}}, {Indices: []int{0, 1, 2}}},
+		CellTypes: []int{VTKTetra, VTKTriangle},
+	}
+	if err := grid.build_faces(); err == nil {
+		t.Error("Expected an error for a grid mixing 3D and 2D cells")
+	}
+}
+
+func TestCellTypesCount(t *testing.T) {
+	points := []Point{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	tetras := []Cell{{Indices: []int{0, 1, 2, 3}}, {Indices: []int{0, 1, 3, 2}}}
+	for _, c := range []struct {
+		cells []Cell
+		types []int
+	}{
+		{tetras[:1], []int{VTKTetra, VTKTetra}},
+		{tetras, []int{VTKTetra}},
+	} {
+		grid := VTKGrid{Points: points, Cells: c.cells, CellTypes: c.types}
+		if err := grid.build_faces(); err == nil {
+			t.Errorf("Expected an error for %d cells with %d types", len(c.cells), len(c.types))
+		}
+	}
+	// Without any types the cells are polygons
+	grid := VTKGrid{Points: points[:3], Cells: []Cell{{Indices: []int{0, 1, 2}}}}
+	if err := grid.build_faces(); err != nil || grid.Dim != 2 {
+		t.Errorf("Polygon without a type: Dim = %d, %v", grid.Dim, err)
+	}
+}
+
+func TestPoisson3D(t *testing.T) {
+	grid, err := Box_grid(Point{0, 0, 0}, Point{1, 1, 1}, 4, 4, 4)
+	if err != nil {
+		t.Fatalf("Box_grid failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("all", func(p Point) bool { return true }); err != nil {
+		t.Fatalf("Add_bnd_patch failed: %v", err)
+	}
+	// A linear solution is reproduced exactly by the two-point flux on an orthogonal grid
+	exact := func(p Point) float64 { return 1 + p.X + 2*p.Y - 3*p.Z }
+	var solver Solver
+	solver.Set_grid(grid)
+	if err := solver.Set_problem(Problem{Source: Const(0.0), Exact: exact}); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	if err := solver.Set_bc("all", DirichletBC(exact)); err != nil {
+		t.Fatalf("Set_bc failed: %v", err)
+	}
+	if err := solver.Approximate_parts(); err != nil {
+		t.Fatalf("Approximate_parts failed: %v", err)
+	}
+	norms, err := solver.Errors(exact)
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if norms.Linf > 1e-8 {
+		t.Errorf("3D Poisson: max error %e", norms.Linf)
+	}
+	if h := grid.Mesh_size(); math.Abs(h-0.25) > 1e-12 {
+		t.Errorf("Mesh_size = %g, expected 0.25", h)
+	}
+	if cell := grid.Locate(Point{0.6, 0.1, 0.9}); cell != 3*16+2 {
+		t.Errorf("Locate returned cell %d, expected %d", cell, 3*16+2)
+	}
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+)
+
+// Формат контрольной точки: заголовок checkpoint_header, решение на текущем
+// и предыдущем слоях и контрольная сумма CRC32 всех предыдущих байтов.
+// Все числа записываются в порядке little-endian.
+const (
+	checkpoint_magic   = "FVMCHKPT"
+	checkpoint_version = 1
+)
+
+type checkpoint_header struct {
+	Magic   [8]byte
+	Version uint32
+	Grid    [32]byte // хеш сетки, см. VTKGrid.hash
+	Cells   uint64
+
+	// Настройки решателя
+	Bnd              int64
+	Scheme           int64
+	Correction_iters int64
+	Nonorth          int64
+	Grad_method      int64
+	Nonorth_iters    int64
+
+	// Состояние нестационарного расчёта
+	Time_scheme int64
+	T           float64
+	Dt          float64
+	Step        int64
+	Written     int64
+	Next_write  float64
+	Old         uint64 // длина решения на предыдущем слое (0 — нет)
+}
+
+// hash возвращает SHA-256 от координат узлов, ячеек и их типов
+func (grid *VTKGrid) hash() [32]byte {
+	h := sha256.New()
+	w := bufio.NewWriter(h)
+	le := binary.LittleEndian
+	binary.Write(w, le, uint64(len(grid.Points)))
+	for _, p := range grid.Points {
+		binary.Write(w, le, [3]float64{p.X, p.Y, p.Z})
+	}
+	binary.Write(w, le, uint64(len(grid.Cells)))
+	for i, c := range grid.Cells {
+		binary.Write(w, le, [2]uint64{uint64(grid.cell_type(i)), uint64(len(c.Indices))})
+		for _, n := range c.Indices {
+			binary.Write(w, le, uint64(n))
+		}
+	}
+	w.Flush()
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// Save_checkpoint сохраняет состояние решателя в файл path: хеш сетки,
+// настройки, время, число шагов и решения на текущем и предыдущем слоях.
+// Файл записывается через временный, поэтому прерванная запись не портит
+// предыдущую контрольную точку. Задача и граничные условия не сохраняются
+// и при продолжении задаются заново.
+func (solver *Solver) Save_checkpoint(path string) error {
+	if len(solver.x) != len(solver.grid.Cells) {
+		return fmt.Errorf("решение не вычислено")
+	}
+	st := solver.state
+	header := checkpoint_header{
+		Version:          checkpoint_version,
+		Grid:             solver.grid.hash(),
+		Cells:            uint64(len(solver.x)),
+		Bnd:              int64(solver.bnd),
+		Scheme:           int64(solver.scheme),
+		Correction_iters: int64(solver.correction_iters),
+		Nonorth:          int64(solver.nonorth),
+		Grad_method:      int64(solver.gradient_method()),
+		Nonorth_iters:    int64(solver.nonorth_iters),
+		Time_scheme:      int64(st.scheme),
+		T:                solver.t,
+		Dt:               st.dt,
+		Step:             int64(st.step),
+		Written:          int64(st.written),
+		Next_write:       st.next_write,
+		Old:              uint64(len(st.x_old)),
+	}
+	copy(header.Magic[:], checkpoint_magic)
+
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&b, le, &header)
+	binary.Write(&b, le, solver.x)
+	binary.Write(&b, le, st.x_old)
+	binary.Write(&b, le, crc32.ChecksumIEEE(b.Bytes()))
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("ошибка при записи контрольной точки: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ошибка при записи контрольной точки: %v", err)
+	}
+	return nil
+}
+
+// Load_checkpoint восстанавливает состояние решателя из файла path. Сетка
+// должна быть уже задана через Set_grid и совпадать с сохранённой; настройки
+// решателя восстанавливаются из файла. Следующий вызов Solve_transient
+// продолжает расчёт с сохранённого шага.
+func (solver *Solver) Load_checkpoint(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении контрольной точки: %v", err)
+	}
+	if len(data) < 4 {
+		return fmt.Errorf("файл %s не является контрольной точкой", path)
+	}
+	le := binary.LittleEndian
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != le.Uint32(data[len(data)-4:]) {
+		return fmt.Errorf("контрольная точка %s повреждена: неверная контрольная сумма", path)
+	}
+	r := bytes.NewReader(body)
+	var header checkpoint_header
+	if err := binary.Read(r, le, &header); err != nil || string(header.Magic[:]) != checkpoint_magic {
+		return fmt.Errorf("файл %s не является контрольной точкой", path)
+	}
+	if header.Version != checkpoint_version {
+		return fmt.Errorf("неподдерживаемая версия контрольной точки %d", header.Version)
+	}
+	if header.Grid != solver.grid.hash() || header.Cells != uint64(len(solver.grid.Cells)) {
+		return fmt.Errorf("контрольная точка записана для другой сетки")
+	}
+	if header.Old != 0 && header.Old != header.Cells {
+		return fmt.Errorf("неверная длина решения на предыдущем слое: %d", header.Old)
+	}
+	x := make([]float64, header.Cells)
+	var x_old []float64
+	if header.Old != 0 {
+		x_old = make([]float64, header.Old)
+	}
+	if err := binary.Read(r, le, x); err != nil {
+		return fmt.Errorf("ошибка при чтении решения: %v", err)
+	}
+	if err := binary.Read(r, le, x_old); err != nil {
+		return fmt.Errorf("ошибка при чтении решения: %v", err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return fmt.Errorf("лишние данные в конце контрольной точки")
+	}
+	if header.Step < 0 || header.Written < 0 || math.IsNaN(header.Dt) {
+		return fmt.Errorf("неверное состояние расчёта в контрольной точке")
+	}
+
+	solver.bnd = int(header.Bnd)
+	solver.scheme = int(header.Scheme)
+	solver.correction_iters = int(header.Correction_iters)
+	solver.nonorth = int(header.Nonorth)
+	solver.grad_method, solver.grad_set = int(header.Grad_method), true
+	solver.nonorth_iters = int(header.Nonorth_iters)
+	solver.t = header.T
+	solver.x = x
+	solver.state = transient_state{
+		scheme:     int(header.Time_scheme),
+		dt:         header.Dt,
+		step:       int(header.Step),
+		x_old:      x_old,
+		written:    int(header.Written),
+		next_write: header.Next_write,
+		resume:     true,
+	}
+	return nil
+}
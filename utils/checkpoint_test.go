@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func heatSolver(t *testing.T, nx int) *Solver {
+	t.Helper()
+	grid, err := Rect_grid(0, 0, 1, 1, nx, nx, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	solver := &Solver{}
+	solver.Set_grid(grid)
+	problem := Problem{Source_t: func(p Point, tm float64) float64 { return math.Sin(3*tm) * (1 + p.X*p.Y) }}
+	if err := solver.Set_problem(problem); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	if err := solver.Set_bnd_type(Dirichlet); err != nil {
+		t.Fatalf("Set_bnd_type failed: %v", err)
+	}
+	return solver
+}
+
+// Deferred corrections are evaluated from the restored solution, so a
+// restart on a non-orthogonal grid or with a TVD scheme is also exact
+func TestCheckpointRestartBitIdentical(t *testing.T) {
+	setups := []struct {
+		name  string
+		build func(t *testing.T) *Solver
+	}{
+		{"orthogonal", func(t *testing.T) *Solver { return heatSolver(t, 6) }},
+		{"non-orthogonal", func(t *testing.T) *Solver {
+			grid, err := Grid("../test_data/tetragrid_2000.vtk")
+			if err != nil {
+				t.Fatalf("Grid failed: %v", err)
+			}
+			grid.Need_cell_centers()
+			solver := heatSolver(t, 1)
+			solver.Set_grid(grid)
+			if err := solver.Set_nonorthogonal(OverRelaxed, 1); err != nil {
+				t.Fatalf("Set_nonorthogonal failed: %v", err)
+			}
+			return solver
+		}},
+		{"TVD", func(t *testing.T) *Solver {
+			solver := heatSolver(t, 6)
+			problem := *solver.problem
+			problem.Velocity = func(p Point) Point { return Point{X: 2, Y: 1} }
+			if err := solver.Set_problem(problem); err != nil {
+				t.Fatalf("Set_problem failed: %v", err)
+			}
+			if err := solver.Set_scheme(VanLeer, 0); err != nil {
+				t.Fatalf("Set_scheme failed: %v", err)
+			}
+			return solver
+		}},
+	}
+	for _, setup := range setups {
+		for _, scheme := range []int{BDF2, CrankNicolson} {
+			tr := Transient{Scheme: scheme, Dt: 0.1, T_end: 1.0, Snapshot_every: 0.1,
+				Initial: func(p Point) float64 { return p.X }}
+
+			full := setup.build(t)
+			if err := full.Solve_transient(tr); err != nil {
+				t.Fatalf("Solve_transient failed: %v", err)
+			}
+
+			// A run that crashes while writing the snapshot at t = 0.6,
+			// after the checkpoint of step 3
+			path := filepath.Join(t.TempDir(), "run.chk")
+			crashed := tr
+			crashed.Checkpoint = path
+			crashed.Checkpoint_every = 3
+			crashed.Snapshot = func(step int, tm float64, u []float64) error {
+				if tm > 0.55 {
+					return fmt.Errorf("crash")
+				}
+				return nil
+			}
+			if err := setup.build(t).Solve_transient(crashed); err == nil {
+				t.Fatal("Expected the simulated crash")
+			}
+
+			resumed := setup.build(t)
+			if err := resumed.Load_checkpoint(path); err != nil {
+				t.Fatalf("Load_checkpoint failed: %v", err)
+			}
+			if resumed.Step() != 3 || math.Abs(resumed.Time()-0.3) > 1e-12 {
+				t.Errorf("Restored step %d at t = %g, expected 3 at 0.3", resumed.Step(), resumed.Time())
+			}
+			var steps []int
+			tr.Snapshot = func(step int, tm float64, u []float64) error {
+				steps = append(steps, step)
+				return nil
+			}
+			if err := resumed.Solve_transient(tr); err != nil {
+				t.Fatalf("Resumed Solve_transient failed: %v", err)
+			}
+			if len(steps) != 7 || steps[0] != 4 {
+				t.Errorf("Resumed run wrote snapshots %v, expected 4..10", steps)
+			}
+			if resumed.Step() != 10 || resumed.Time() != full.Time() {
+				t.Errorf("Resumed run ended at step %d, t = %g", resumed.Step(), resumed.Time())
+			}
+			for i := range full.x {
+				if math.Float64bits(full.x[i]) != math.Float64bits(resumed.x[i]) {
+					t.Fatalf("%s, scheme %d: cell %d differs after restart: %v vs %v", setup.name, scheme, i, resumed.x[i], full.x[i])
+				}
+			}
+		}
+	}
+}
+
+func TestCheckpointRejected(t *testing.T) {
+	solver := heatSolver(t, 4)
+	tr := Transient{Scheme: BackwardEuler, Dt: 0.1, T_end: 0.2}
+	if err := solver.Solve_transient(tr); err != nil {
+		t.Fatalf("Solve_transient failed: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.chk")
+	if err := solver.Save_checkpoint(path); err != nil {
+		t.Fatalf("Save_checkpoint failed: %v", err)
+	}
+
+	if err := heatSolver(t, 5).Load_checkpoint(path); err == nil {
+		t.Error("Expected an error for a different grid")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 1
+	corrupted := filepath.Join(dir, "corrupted.chk")
+	if err := os.WriteFile(corrupted, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := heatSolver(t, 4).Load_checkpoint(corrupted); err == nil {
+		t.Error("Expected an error for a corrupted checkpoint")
+	}
+
+	other := heatSolver(t, 4)
+	if err := other.Load_checkpoint(path); err != nil {
+		t.Fatalf("Load_checkpoint failed: %v", err)
+	}
+	tr.Dt = 0.05
+	if err := other.Solve_transient(tr); err == nil {
+		t.Error("Expected an error when resuming with a different time step")
+	}
+}
+
+func TestCheckpointExtendsSeries(t *testing.T) {
+	dir := t.TempDir()
+	tr := Transient{Scheme: BackwardEuler, Dt: 0.1, T_end: 0.4, Snapshot_every: 0.1,
+		Output: filepath.Join(dir, "heat.pvd"), Checkpoint: filepath.Join(dir, "heat.chk")}
+	if err := heatSolver(t, 4).Solve_transient(tr); err != nil {
+		t.Fatalf("Solve_transient failed: %v", err)
+	}
+	// Continue the finished run to a later end time
+	solver := heatSolver(t, 4)
+	if err := solver.Load_checkpoint(tr.Checkpoint); err != nil {
+		t.Fatalf("Load_checkpoint failed: %v", err)
+	}
+	tr.T_end = 0.8
+	if err := solver.Solve_transient(tr); err != nil {
+		t.Fatalf("Resumed Solve_transient failed: %v", err)
+	}
+	index := readPvd(t, tr.Output)
+	if len(index.DataSets) != 9 {
+		t.Fatalf("Series holds %d data sets, expected 9", len(index.DataSets))
+	}
+	for i, ds := range index.DataSets {
+		if want := fmt.Sprintf("heat_%04d.vtu", i); ds.File != want || math.Abs(ds.Timestep-0.1*float64(i)) > 1e-12 {
+			t.Errorf("Data set %d: %s at t = %g", i, ds.File, ds.Timestep)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// Схемы аппроксимации конвективного потока
+const (
+	Upwind   = iota // противопоточная первого порядка
+	Central         // центральная разность
+	Minmod          // TVD с ограничителем minmod
+	VanLeer         // TVD с ограничителем ван Лира
+	Superbee        // TVD с ограничителем superbee
+)
+
+// VectorFunc — векторное поле, например скорость
+type VectorFunc func(p Point) Point
+
+// FaceFlux — объёмные потоки через грани (нормальная скорость, умноженная на длину грани)
+type FaceFlux struct {
+	In  []float64 // по граням Faces_in_cel, положительный поток направлен от Left к Right
+	Bnd []float64 // по граням Faces_bnd_cel, положительный поток направлен наружу
+}
+
+// Face_flux вычисляет потоки через грани для поля скорости vel,
+// заданного в серединах граней
+func (grid *VTKGrid) Face_flux(vel VectorFunc) FaceFlux {
+	if len(grid.Face_geometry) != len(grid.Faces) {
+		grid.Need_face_geometry()
+	}
+	flux := FaceFlux{
+		In:  make([]float64, len(grid.Faces_in_cel)),
+		Bnd: make([]float64, len(grid.Faces_bnd_cel)),
+	}
+	for i := range flux.In {
+		geom := grid.In_geometry(i)
+		flux.In[i] = dot(vel(geom.Center), geom.Normal) * geom.Area
+	}
+	for i := range flux.Bnd {
+		geom := grid.Bnd_geometry(i)
+		flux.Bnd[i] = dot(vel(geom.Center), geom.Normal) * geom.Area
+	}
+	return flux
+}
+
+// limiter возвращает значение ограничителя psi(r) для TVD-схемы
+func limiter(scheme int, r float64) float64 {
+	switch scheme {
+	case Minmod:
+		return math.Max(0.0, math.Min(1.0, r))
+	case VanLeer:
+		return (r + math.Abs(r)) / (1.0 + math.Abs(r))
+	case Superbee:
+		return math.Max(0.0, math.Max(math.Min(2.0*r, 1.0), math.Min(r, 2.0)))
+	}
+	return 0.0
+}
+
+// Set_scheme задаёт схему для конвективного члена. Для TVD-схем поправка
+// высокого порядка вносится итерациями отложенной коррекции, не более iters.
+func (solver *Solver) Set_scheme(scheme int, iters int) error {
+	if scheme < Upwind || scheme > Superbee {
+		return fmt.Errorf("неизвестная схема: %d", scheme)
+	}
+	solver.scheme = scheme
+	solver.correction_iters = iters
+	return nil
+}
+
+// face_flux возвращает потоки через грани для текущей задачи или nil,
+// если конвективного члена нет
+func (solver *Solver) face_flux() (*FaceFlux, error) {
+	problem := solver.problem_def()
+	if problem.Flux != nil {
+		if len(problem.Flux.In) != len(solver.grid.Faces_in_cel) ||
+			len(problem.Flux.Bnd) != len(solver.grid.Faces_bnd_cel) {
+			return nil, fmt.Errorf("число потоков не совпадает с числом граней")
+		}
+		return problem.Flux, nil
+	}
+	if problem.Velocity != nil {
+		flux := solver.grid.Face_flux(problem.Velocity)
+		return &flux, nil
+	}
+	return nil, nil
+}
+
+// add_convection добавляет в матрицу неявную часть конвективного потока:
+// центральную разность для Central и противопоточную для остальных схем
+func (solver *Solver) add_convection(lhs *matrix.DOKMatrix, flux *FaceFlux, bcs []BoundaryCondition) {
+	for i := 0; i < len(solver.grid.Faces_in_cel); i++ {
+		face := solver.grid.Faces_in_cel[i]
+		left := face[1].Left
+		right := face[1].Right
+		F := flux.In[i]
+		// Доля значения из ячейки left в значении на грани
+		w := 1.0
+		if solver.scheme == Central {
+			w = solver.grid.In_geometry(i).Weight
+		} else if F < 0 {
+			w = 0.0
+		}
+		lhs.Set(left, left, lhs.Get(left, left)+F*w)
+		lhs.Set(left, right, lhs.Get(left, right)+F*(1.0-w))
+		lhs.Set(right, left, lhs.Get(right, left)-F*w)
+		lhs.Set(right, right, lhs.Get(right, right)-F*(1.0-w))
+	}
+	for i := 0; i < len(solver.grid.Faces_bnd_cel); i++ {
+		left := solver.grid.Faces_bnd_cel[i][1].Left
+		F := flux.Bnd[i]
+		// На входе с условием Дирихле значение на грани известно (см. convection_rhs),
+		// в остальных случаях оно сносится из ячейки
+		if F >= 0 || bcs[i].Type != Dirichlet {
+			lhs.Set(left, left, lhs.Get(left, left)+F)
+		}
+	}
+}
+
+// convection_rhs добавляет в правую часть вклад входящего потока через
+// границы с условием Дирихле в момент t
+func (solver *Solver) convection_rhs(rhs []float64, flux *FaceFlux, bcs []BoundaryCondition, t float64) {
+	for i := 0; i < len(solver.grid.Faces_bnd_cel); i++ {
+		F := flux.Bnd[i]
+		if F < 0 && bcs[i].Type == Dirichlet {
+			left := solver.grid.Faces_bnd_cel[i][1].Left
+			rhs[left] -= F * bcs[i].value(solver.grid.Bnd_geometry(i).Center, t)
+		}
+	}
+}
+
+// bnd_values возвращает значения поля u на граничных гранях: заданные
+// для условий Дирихле и снесённые из ячейки для остальных
+func (solver *Solver) bnd_values(u []float64, bcs []BoundaryCondition, t float64) []float64 {
+	bnd := make([]float64, len(solver.grid.Faces_bnd_cel))
+	for i := range bnd {
+		if bcs[i].Type == Dirichlet {
+			bnd[i] = bcs[i].value(solver.grid.Bnd_geometry(i).Center, t)
+		} else {
+			bnd[i] = u[solver.grid.Faces_bnd_cel[i][1].Left]
+		}
+	}
+	return bnd
+}
+
+// deferred_correction добавляет в правую часть разность между потоком
+// TVD-схемы и противопоточным потоком, вычисленную по полю u в момент t
+func (solver *Solver) deferred_correction(rhs []float64, u []float64, flux *FaceFlux, t float64) error {
+	if solver.scheme < Minmod {
+		return nil
+	}
+	bcs, err := solver.face_bcs()
+	if err != nil {
+		return err
+	}
+	grad, err := solver.grid.Gradient(u, solver.bnd_values(u, bcs, t), solver.gradient_method())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(solver.grid.Faces_in_cel); i++ {
+		face := solver.grid.Faces_in_cel[i]
+		F := flux.In[i]
+		up, down := face[1].Left, face[1].Right
+		if F < 0 {
+			up, down = down, up
+		}
+		du := u[down] - u[up]
+		if math.Abs(du) < 1e-300 {
+			continue
+		}
+		// r оценивается по градиенту в противопоточной ячейке
+		cu := solver.grid.Cell_centers[up]
+		cd := solver.grid.Cell_centers[down]
+		g := grad[up]
+		r := 2.0*(g.X*(cd.X-cu.X)+g.Y*(cd.Y-cu.Y)+g.Z*(cd.Z-cu.Z))/du - 1.0
+		correction := F * 0.5 * limiter(solver.scheme, r) * du
+		rhs[face[1].Left] -= correction
+		rhs[face[1].Right] += correction
+	}
+	return nil
+}
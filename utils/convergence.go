@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// ErrorNorms — нормы погрешности численного решения относительно точного
+type ErrorNorms struct {
+	L2   float64 `json:"l2"`
+	Linf float64 `json:"linf"`
+	H1   float64 `json:"h1"` // дискретная H1-полунорма
+}
+
+// ConvergenceRow — результат расчёта на одной сетке
+type ConvergenceRow struct {
+	Mesh   string     `json:"mesh"`
+	Cells  int        `json:"cells"`
+	H      float64    `json:"h"`
+	Errors ErrorNorms `json:"errors"`
+	Order  ErrorNorms `json:"order"` // наблюдаемый порядок относительно предыдущей сетки
+}
+
+// Solution возвращает вычисленное решение в центрах ячеек
+func (solver *Solver) Solution() []float64 {
+	return solver.x
+}
+
+// Mesh_size возвращает характерный размер ячейки sqrt(S/N)
+// (для объёмной сетки — cbrt(V/N))
+func (grid *VTKGrid) Mesh_size() float64 {
+	sum := 0.0
+	for _, v := range grid.Cell_volumes {
+		sum += math.Abs(v)
+	}
+	return grid.cell_size(sum / float64(len(grid.Cell_volumes)))
+}
+
+// cell_size возвращает линейный размер ячейки объёма v
+func (grid *VTKGrid) cell_size(v float64) float64 {
+	if grid.Dim == 3 {
+		return math.Cbrt(v)
+	}
+	return math.Sqrt(v)
+}
+
+// Errors вычисляет нормы погрешности e = u - exact в центрах ячеек.
+// L2 взвешивается объёмами ячеек, H1-полунорма вычисляется по внутренним граням:
+// |e|^2 = sum gij/hij * (e_j - e_i)^2.
+func (solver *Solver) Errors(exact ScalarFunc) (ErrorNorms, error) {
+	grid := &solver.grid
+	if len(solver.x) != len(grid.Cells) {
+		return ErrorNorms{}, fmt.Errorf("решение не вычислено")
+	}
+	e := make([]float64, len(grid.Cells))
+	norms := ErrorNorms{}
+	for i := range e {
+		e[i] = solver.x[i] - exact(grid.Cell_centers[i])
+		norms.L2 += e[i] * e[i] * math.Abs(grid.Cell_volumes[i])
+		norms.Linf = math.Max(norms.Linf, math.Abs(e[i]))
+	}
+	for i := 0; i < len(grid.Faces_in_cel); i++ {
+		face := grid.Faces_in_cel[i]
+		geom := grid.In_geometry(i)
+		de := e[face[1].Right] - e[face[1].Left]
+		norms.H1 += geom.Area / geom.Delta * de * de
+	}
+	norms.L2 = math.Sqrt(norms.L2)
+	norms.H1 = math.Sqrt(norms.H1)
+	return norms, nil
+}
+
+// observed_order возвращает порядок сходимости по двум парам (h, e)
+// или 0, если он не определён
+func observed_order(h0, e0, h1, e1 float64) float64 {
+	if e0 <= 0 || e1 <= 0 || h0 == h1 {
+		return 0.0
+	}
+	return math.Log(e0/e1) / math.Log(h0/h1)
+}
+
+// Convergence_study решает тестовую задачу на последовательности сеток files
+// с граничным условием типа bnd, способом учёта неортогональности nonorth
+// и способом вычисления градиента gradient и вычисляет наблюдаемый порядок точности
+func Convergence_study(files []string, bnd int, nonorth int, gradient int) ([]ConvergenceRow, error) {
+	problem := Manufactured_problem()
+	rows := make([]ConvergenceRow, 0, len(files))
+	for _, filename := range files {
+		grid, err := Grid(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		var solver Solver
+		if err := solver.Set_bnd_type(bnd); err != nil {
+			return nil, err
+		}
+		if err := solver.Set_nonorthogonal(nonorth, 0); err != nil {
+			return nil, err
+		}
+		if err := solver.Set_gradient(gradient); err != nil {
+			return nil, err
+		}
+		solver.Set_grid(grid)
+		if err := solver.Approximate_parts(); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		norms, err := solver.Errors(problem.Exact)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		row := ConvergenceRow{
+			Mesh:   filename,
+			Cells:  len(solver.grid.Cells),
+			H:      solver.grid.Mesh_size(),
+			Errors: norms,
+		}
+		if n := len(rows); n > 0 {
+			prev := rows[n-1]
+			row.Order = ErrorNorms{
+				L2:   observed_order(prev.H, prev.Errors.L2, row.H, norms.L2),
+				Linf: observed_order(prev.H, prev.Errors.Linf, row.H, norms.Linf),
+				H1:   observed_order(prev.H, prev.Errors.H1, row.H, norms.H1),
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
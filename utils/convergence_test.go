@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestConvergenceStudy(t *testing.T) {
+	files := []string{
+		"../test_data/tetragrid_2000.vtk",
+		"../test_data/tetragrid_10000.vtk",
+		"../test_data/tetragrid_40k.vtk",
+	}
+	rows, err := Convergence_study(files, Dirichlet, OverRelaxed, LeastSquares)
+	if err != nil {
+		t.Fatalf("Convergence_study failed: %v", err)
+	}
+	if len(rows) != len(files) {
+		t.Fatalf("%d rows for %d meshes", len(rows), len(files))
+	}
+	if rows[0].Order != (ErrorNorms{}) {
+		t.Errorf("Order on the first mesh: %+v, expected zero", rows[0].Order)
+	}
+	for i := 1; i < len(rows); i++ {
+		prev, row := rows[i-1], rows[i]
+		if row.Mesh != files[i] || row.Cells <= prev.Cells || row.H >= prev.H {
+			t.Errorf("Row %d: %s, %d cells, h = %g after %d cells, h = %g",
+				i, row.Mesh, row.Cells, row.H, prev.Cells, prev.H)
+		}
+		if want := observed_order(prev.H, prev.Errors.L2, row.H, row.Errors.L2); row.Order.L2 != want {
+			t.Errorf("Row %d: L2 order %g, expected %g", i, row.Order.L2, want)
+		}
+		if math.Abs(row.Order.L2-2) > 0.3 {
+			t.Errorf("Row %d: L2 order %.2f, expected 2", i, row.Order.L2)
+		}
+	}
+
+	// The keys of the JSON output of cmd/convergence
+	data, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	norms := []string{"h1", "l2", "linf"}
+	for i, row := range decoded {
+		if keys := json_keys(row); !reflect.DeepEqual(keys, []string{"cells", "errors", "h", "mesh", "order"}) {
+			t.Errorf("Row %d: keys %v", i, keys)
+		}
+		for _, key := range []string{"errors", "order"} {
+			obj, ok := row[key].(map[string]interface{})
+			if !ok || !reflect.DeepEqual(json_keys(obj), norms) {
+				t.Errorf("Row %d: %s = %v", i, key, row[key])
+			}
+		}
+	}
+}
+
+func TestObservedOrder(t *testing.T) {
+	cases := []struct {
+		h0, e0, h1, e1 float64
+		order          float64
+	}{
+		{0.1, 4e-2, 0.05, 1e-2, 2},
+		{0.2, 1e-1, 0.1, 5e-2, 1},
+		{0.1, 1e-2, 0.1, 1e-3, 0}, // the same mesh size
+		{0.1, 0, 0.05, 1e-3, 0},   // zero error
+	}
+	for _, c := range cases {
+		if order := observed_order(c.h0, c.e0, c.h1, c.e1); math.Abs(order-c.order) > 1e-12 {
+			t.Errorf("observed_order(%g, %g, %g, %g) = %g, expected %g", c.h0, c.e0, c.h1, c.e1, order, c.order)
+		}
+	}
+}
+
+// json_keys returns the sorted keys of a decoded JSON object
+func json_keys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
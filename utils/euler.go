@@ -0,0 +1,568 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Численные потоки для уравнений Эйлера
+const (
+	Rusanov = iota // локальный поток Лакса–Фридрихса
+	Roe            // поток Роу с энтропийной поправкой Хартена
+	HLLC           // поток HLLC
+)
+
+// Типы границ для газовой динамики
+const (
+	SupersonicInflow  = iota + 1 // заданы все переменные
+	SupersonicOutflow            // все переменные сносятся изнутри
+	SubsonicInflow               // заданы плотность и скорость, давление сносится изнутри
+	SubsonicOutflow              // задано давление, остальное сносится изнутри
+	SlipWall                     // непротекание
+)
+
+// GasState — примитивные переменные газа
+type GasState struct {
+	Rho, U, V, P float64
+}
+
+// GasBC — граничное условие для газовой динамики
+type GasBC struct {
+	Type  int
+	State GasState // состояние на входе; для SubsonicOutflow используется только P
+}
+
+// Gas — параметры расчёта
+type Gas struct {
+	Gamma float64 // показатель адиабаты; 0 означает 1.4
+	Flux  int     // Rusanov, Roe или HLLC
+	Order int     // 1 — первый порядок, 2 — MUSCL-реконструкция; 0 означает 2
+	RK    int     // число стадий SSP-метода Рунге–Кутты (1, 2 или 3); 0 означает 3
+	CFL   float64 // 0 означает 0.5
+	// Ограничитель градиента при Order = 2: BarthJespersen (также 0)
+	// или Venkatakrishnan с параметром K = 1
+	Limiter int
+}
+
+// EulerSolver решает нестационарные уравнения Эйлера для сжимаемого газа
+// явным методом конечных объёмов в центрах ячеек
+type EulerSolver struct {
+	grid VTKGrid
+	gas  Gas
+	bcs  map[string]GasBC
+
+	in     []flow_face
+	bnd    []flow_face
+	bnd_bc []GasBC
+
+	q [][4]float64 // консервативные переменные (rho, rho*u, rho*v, E)
+	t float64
+}
+
+// Set_grid задаёт сетку и вычисляет геометрию граней
+func (es *EulerSolver) Set_grid(grid VTKGrid) {
+	es.grid = grid
+	es.grid.Need_cell_centers()
+	es.grid.Need_face_geometry()
+	es.in, es.bnd = build_flow_faces(&es.grid)
+	es.q = make([][4]float64, len(es.grid.Cells))
+	es.bnd_bc = nil
+	es.t = 0.0
+}
+
+// Set_gas задаёт параметры газа и схемы
+func (es *EulerSolver) Set_gas(gas Gas) error {
+	if gas.Gamma == 0 {
+		gas.Gamma = 1.4
+	}
+	if gas.Order == 0 {
+		gas.Order = 2
+	}
+	if gas.RK == 0 {
+		gas.RK = 3
+	}
+	if gas.CFL == 0 {
+		gas.CFL = 0.5
+	}
+	if gas.Gamma <= 1 {
+		return fmt.Errorf("показатель адиабаты должен быть больше 1")
+	}
+	if gas.Flux < Rusanov || gas.Flux > HLLC {
+		return fmt.Errorf("неизвестный поток: %d", gas.Flux)
+	}
+	if gas.Order < 1 || gas.Order > 2 {
+		return fmt.Errorf("порядок схемы должен быть 1 или 2")
+	}
+	if gas.RK < 1 || gas.RK > 3 {
+		return fmt.Errorf("число стадий Рунге–Кутты должно быть от 1 до 3")
+	}
+	if gas.Limiter == NoLimiter {
+		gas.Limiter = BarthJespersen
+	}
+	if gas.Limiter != BarthJespersen && gas.Limiter != Venkatakrishnan {
+		return fmt.Errorf("неизвестный ограничитель: %d", gas.Limiter)
+	}
+	es.gas = gas
+	return nil
+}
+
+// Set_bc задаёт граничное условие на участке границы patch
+func (es *EulerSolver) Set_bc(patch string, bc GasBC) error {
+	if bc.Type < SupersonicInflow || bc.Type > SlipWall {
+		return fmt.Errorf("this type is not exist")
+	}
+	if es.bcs == nil {
+		es.bcs = make(map[string]GasBC)
+	}
+	es.bcs[patch] = bc
+	es.bnd_bc = nil
+	return nil
+}
+
+// Set_initial задаёт начальное состояние в центрах ячеек
+func (es *EulerSolver) Set_initial(f func(p Point) GasState) {
+	for i, c := range es.grid.Cell_centers {
+		es.q[i] = es.conservative(f(c))
+	}
+	es.t = 0.0
+}
+
+// Time возвращает текущее время
+func (es *EulerSolver) Time() float64 {
+	return es.t
+}
+
+// State возвращает примитивные переменные в ячейке i
+func (es *EulerSolver) State(i int) GasState {
+	return es.primitive(es.q[i])
+}
+
+// Density возвращает плотность в центрах ячеек
+func (es *EulerSolver) Density() []float64 {
+	rho := make([]float64, len(es.q))
+	for i := range es.q {
+		rho[i] = es.q[i][0]
+	}
+	return rho
+}
+
+func (es *EulerSolver) conservative(w GasState) [4]float64 {
+	return [4]float64{w.Rho, w.Rho * w.U, w.Rho * w.V, w.P/(es.gas.Gamma-1) + 0.5*w.Rho*(w.U*w.U+w.V*w.V)}
+}
+
+func (es *EulerSolver) primitive(q [4]float64) GasState {
+	u := q[1] / q[0]
+	v := q[2] / q[0]
+	return GasState{Rho: q[0], U: u, V: v, P: (es.gas.Gamma - 1) * (q[3] - 0.5*q[0]*(u*u+v*v))}
+}
+
+func (es *EulerSolver) sound(w GasState) float64 {
+	return math.Sqrt(es.gas.Gamma * w.P / w.Rho)
+}
+
+// resolve_bcs сопоставляет граничным граням условия; все грани должны быть покрыты
+func (es *EulerSolver) resolve_bcs() error {
+	es.bnd_bc = make([]GasBC, len(es.bnd))
+	for name, bc := range es.bcs {
+		patch := es.grid.Patch(name)
+		if patch == nil {
+			return fmt.Errorf("участок границы %q не найден", name)
+		}
+		for _, f := range patch.Faces {
+			es.bnd_bc[f] = bc
+		}
+	}
+	for i, bc := range es.bnd_bc {
+		if bc.Type == 0 {
+			return fmt.Errorf("для граничной грани %d не задано условие", i)
+		}
+	}
+	return nil
+}
+
+// ghost возвращает состояние за граничной гранью по состоянию w изнутри
+func (es *EulerSolver) ghost(bc GasBC, w GasState, n Point) GasState {
+	switch bc.Type {
+	case SupersonicInflow:
+		return bc.State
+	case SubsonicInflow:
+		return GasState{Rho: bc.State.Rho, U: bc.State.U, V: bc.State.V, P: w.P}
+	case SubsonicOutflow:
+		w.P = bc.State.P
+		return w
+	case SlipWall:
+		un := w.U*n.X + w.V*n.Y
+		w.U -= 2.0 * un * n.X
+		w.V -= 2.0 * un * n.Y
+		return w
+	}
+	return w
+}
+
+// physical_flux возвращает поток через грань с нормалью n
+func (es *EulerSolver) physical_flux(w GasState, n Point) [4]float64 {
+	un := w.U*n.X + w.V*n.Y
+	E := w.P/(es.gas.Gamma-1) + 0.5*w.Rho*(w.U*w.U+w.V*w.V)
+	return [4]float64{w.Rho * un, w.Rho*w.U*un + w.P*n.X, w.Rho*w.V*un + w.P*n.Y, (E + w.P) * un}
+}
+
+// numerical_flux возвращает численный поток через грань с нормалью n от состояния wl к wr
+func (es *EulerSolver) numerical_flux(wl, wr GasState, n Point) [4]float64 {
+	switch es.gas.Flux {
+	case Roe:
+		return es.roe_flux(wl, wr, n)
+	case HLLC:
+		return es.hllc_flux(wl, wr, n)
+	}
+	return es.rusanov_flux(wl, wr, n)
+}
+
+func (es *EulerSolver) rusanov_flux(wl, wr GasState, n Point) [4]float64 {
+	fl := es.physical_flux(wl, n)
+	fr := es.physical_flux(wr, n)
+	ql := es.conservative(wl)
+	qr := es.conservative(wr)
+	s := math.Max(math.Abs(wl.U*n.X+wl.V*n.Y)+es.sound(wl), math.Abs(wr.U*n.X+wr.V*n.Y)+es.sound(wr))
+	var f [4]float64
+	for k := 0; k < 4; k++ {
+		f[k] = 0.5*(fl[k]+fr[k]) - 0.5*s*(qr[k]-ql[k])
+	}
+	return f
+}
+
+// roe_average возвращает осреднённые по Роу скорость, энтальпию и скорость звука
+func (es *EulerSolver) roe_average(wl, wr GasState) (float64, float64, float64, float64) {
+	g := es.gas.Gamma
+	sl := math.Sqrt(wl.Rho)
+	sr := math.Sqrt(wr.Rho)
+	hl := (wl.P/(g-1) + 0.5*wl.Rho*(wl.U*wl.U+wl.V*wl.V) + wl.P) / wl.Rho
+	hr := (wr.P/(g-1) + 0.5*wr.Rho*(wr.U*wr.U+wr.V*wr.V) + wr.P) / wr.Rho
+	u := (sl*wl.U + sr*wr.U) / (sl + sr)
+	v := (sl*wl.V + sr*wr.V) / (sl + sr)
+	h := (sl*hl + sr*hr) / (sl + sr)
+	c := math.Sqrt(math.Max((g-1)*(h-0.5*(u*u+v*v)), 1e-14))
+	return u, v, h, c
+}
+
+func (es *EulerSolver) roe_flux(wl, wr GasState, n Point) [4]float64 {
+	fl := es.physical_flux(wl, n)
+	fr := es.physical_flux(wr, n)
+	rho := math.Sqrt(wl.Rho * wr.Rho)
+	u, v, h, c := es.roe_average(wl, wr)
+	tx, ty := -n.Y, n.X
+	qn := u*n.X + v*n.Y
+	qt := u*tx + v*ty
+
+	dp := wr.P - wl.P
+	drho := wr.Rho - wl.Rho
+	dqn := (wr.U-wl.U)*n.X + (wr.V-wl.V)*n.Y
+	dqt := (wr.U-wl.U)*tx + (wr.V-wl.V)*ty
+
+	// Собственные значения с энтропийной поправкой Хартена для акустических волн
+	fix := func(l float64) float64 {
+		delta := 0.1 * c
+		if math.Abs(l) < delta {
+			return (l*l + delta*delta) / (2.0 * delta)
+		}
+		return math.Abs(l)
+	}
+	l1 := fix(qn - c)
+	l2 := math.Abs(qn)
+	l4 := fix(qn + c)
+
+	a1 := (dp - rho*c*dqn) / (2.0 * c * c)
+	a2 := drho - dp/(c*c)
+	a3 := rho * dqt
+	a4 := (dp + rho*c*dqn) / (2.0 * c * c)
+
+	r1 := [4]float64{1, u - c*n.X, v - c*n.Y, h - qn*c}
+	r2 := [4]float64{1, u, v, 0.5 * (u*u + v*v)}
+	r3 := [4]float64{0, tx, ty, qt}
+	r4 := [4]float64{1, u + c*n.X, v + c*n.Y, h + qn*c}
+
+	// Линеаризация Роу не сохраняет положительность в сильных волнах
+	// разрежения: если промежуточные состояния нефизичны, берём поток Русанова
+	ql := es.conservative(wl)
+	var q1, q2 [4]float64
+	for k := 0; k < 4; k++ {
+		q1[k] = ql[k] + a1*r1[k]
+		q2[k] = q1[k] + a2*r2[k] + a3*r3[k]
+	}
+	for _, q := range [][4]float64{q1, q2} {
+		if !(q[0] > 0) || !(es.primitive(q).P > 0) {
+			return es.rusanov_flux(wl, wr, n)
+		}
+	}
+
+	var f [4]float64
+	for k := 0; k < 4; k++ {
+		f[k] = 0.5*(fl[k]+fr[k]) - 0.5*(l1*a1*r1[k]+l2*a2*r2[k]+l2*a3*r3[k]+l4*a4*r4[k])
+	}
+	return f
+}
+
+func (es *EulerSolver) hllc_flux(wl, wr GasState, n Point) [4]float64 {
+	qnl := wl.U*n.X + wl.V*n.Y
+	qnr := wr.U*n.X + wr.V*n.Y
+	u, v, _, c := es.roe_average(wl, wr)
+	qn := u*n.X + v*n.Y
+	sl := math.Min(qnl-es.sound(wl), qn-c)
+	sr := math.Max(qnr+es.sound(wr), qn+c)
+	if sl >= 0 {
+		return es.physical_flux(wl, n)
+	}
+	if sr <= 0 {
+		return es.physical_flux(wr, n)
+	}
+	sm := (wr.P - wl.P + wl.Rho*qnl*(sl-qnl) - wr.Rho*qnr*(sr-qnr)) / (wl.Rho*(sl-qnl) - wr.Rho*(sr-qnr))
+	star := func(w GasState, s, qnk float64) ([4]float64, [4]float64) {
+		q := es.conservative(w)
+		coef := w.Rho * (s - qnk) / (s - sm)
+		qs := [4]float64{
+			coef,
+			coef * (w.U + (sm-qnk)*n.X),
+			coef * (w.V + (sm-qnk)*n.Y),
+			coef * (q[3]/w.Rho + (sm-qnk)*(sm+w.P/(w.Rho*(s-qnk)))),
+		}
+		return q, qs
+	}
+	var f [4]float64
+	if sm >= 0 {
+		fl := es.physical_flux(wl, n)
+		q, qs := star(wl, sl, qnl)
+		for k := 0; k < 4; k++ {
+			f[k] = fl[k] + sl*(qs[k]-q[k])
+		}
+	} else {
+		fr := es.physical_flux(wr, n)
+		q, qs := star(wr, sr, qnr)
+		for k := 0; k < 4; k++ {
+			f[k] = fr[k] + sr*(qs[k]-q[k])
+		}
+	}
+	return f
+}
+
+// reconstruct возвращает ограниченные градиенты примитивных переменных
+func (es *EulerSolver) reconstruct(w [][4]float64) ([][4]Point, error) {
+	nn := len(w)
+	grads := make([][4]Point, nn)
+	field := make([]float64, nn)
+	for k := 0; k < 4; k++ {
+		for i := range field {
+			field[i] = w[i][k]
+		}
+		g := green_gauss(&es.grid, field, nil)
+		g, err := es.grid.Limit_gradient(field, nil, g, es.gas.Limiter, 1.0)
+		if err != nil {
+			return nil, err
+		}
+		for i := range grads {
+			grads[i][k] = g[i]
+		}
+	}
+	return grads, nil
+}
+
+// face_state возвращает реконструированное состояние ячейки i в точке x
+func (es *EulerSolver) face_state(w [][4]float64, grads [][4]Point, i int, x Point) GasState {
+	if grads == nil {
+		return GasState{w[i][0], w[i][1], w[i][2], w[i][3]}
+	}
+	c := es.grid.Cell_centers[i]
+	var s [4]float64
+	for k := 0; k < 4; k++ {
+		s[k] = w[i][k] + grads[i][k].X*(x.X-c.X) + grads[i][k].Y*(x.Y-c.Y)
+	}
+	if s[0] <= 0 || s[3] <= 0 {
+		// Реконструкция нарушила положительность — берём значение в ячейке
+		return GasState{w[i][0], w[i][1], w[i][2], w[i][3]}
+	}
+	return GasState{s[0], s[1], s[2], s[3]}
+}
+
+// residual вычисляет dq/dt = -1/V * sum(F*A)
+func (es *EulerSolver) residual(q [][4]float64) ([][4]float64, error) {
+	nn := len(q)
+	w := make([][4]float64, nn)
+	for i := range q {
+		s := es.primitive(q[i])
+		if !(s.Rho > 0) || !(s.P > 0) {
+			return nil, fmt.Errorf("отрицательные плотность или давление в ячейке %d (t = %g)", i, es.t)
+		}
+		w[i] = [4]float64{s.Rho, s.U, s.V, s.P}
+	}
+	var grads [][4]Point
+	if es.gas.Order == 2 {
+		var err error
+		if grads, err = es.reconstruct(w); err != nil {
+			return nil, err
+		}
+	}
+	res := make([][4]float64, nn)
+	for _, f := range es.in {
+		wl := es.face_state(w, grads, f.left, f.center)
+		wr := es.face_state(w, grads, f.right, f.center)
+		flux := es.numerical_flux(wl, wr, f.normal)
+		for k := 0; k < 4; k++ {
+			res[f.left][k] -= flux[k] * f.area
+			res[f.right][k] += flux[k] * f.area
+		}
+	}
+	for i, f := range es.bnd {
+		wl := es.face_state(w, grads, f.left, f.center)
+		wr := es.ghost(es.bnd_bc[i], wl, f.normal)
+		flux := es.numerical_flux(wl, wr, f.normal)
+		for k := 0; k < 4; k++ {
+			res[f.left][k] -= flux[k] * f.area
+		}
+	}
+	for i := range res {
+		vol := math.Abs(es.grid.Cell_volumes[i])
+		for k := 0; k < 4; k++ {
+			res[i][k] /= vol
+		}
+	}
+	return res, nil
+}
+
+// Time_step возвращает шаг по времени по условию Куранта
+func (es *EulerSolver) Time_step() float64 {
+	sum := make([]float64, len(es.q))
+	for _, f := range es.in {
+		for _, i := range []int{f.left, f.right} {
+			w := es.primitive(es.q[i])
+			sum[i] += (math.Abs(w.U*f.normal.X+w.V*f.normal.Y) + es.sound(w)) * f.area
+		}
+	}
+	for _, f := range es.bnd {
+		w := es.primitive(es.q[f.left])
+		sum[f.left] += (math.Abs(w.U*f.normal.X+w.V*f.normal.Y) + es.sound(w)) * f.area
+	}
+	dt := math.Inf(1)
+	for i := range sum {
+		dt = math.Min(dt, math.Abs(es.grid.Cell_volumes[i])/sum[i])
+	}
+	return es.gas.CFL * dt
+}
+
+// Step выполняет один шаг SSP-методом Рунге–Кутты с шагом dt
+func (es *EulerSolver) Step(dt float64) error {
+	if es.gas.Gamma == 0 {
+		return fmt.Errorf("параметры газа не заданы")
+	}
+	if es.bnd_bc == nil {
+		if err := es.resolve_bcs(); err != nil {
+			return err
+		}
+	}
+	// Стадии вида q = a*q0 + b*(q1 + dt*L(q1))
+	var coefs [][2]float64
+	switch es.gas.RK {
+	case 1:
+		coefs = [][2]float64{{0, 1}}
+	case 2:
+		coefs = [][2]float64{{0, 1}, {0.5, 0.5}}
+	default:
+		coefs = [][2]float64{{0, 1}, {0.75, 0.25}, {1.0 / 3.0, 2.0 / 3.0}}
+	}
+	q0 := es.q
+	q := q0
+	for _, c := range coefs {
+		res, err := es.residual(q)
+		if err != nil {
+			return err
+		}
+		next := make([][4]float64, len(q))
+		for i := range next {
+			for k := 0; k < 4; k++ {
+				next[i][k] = c[0]*q0[i][k] + c[1]*(q[i][k]+dt*res[i][k])
+			}
+		}
+		q = next
+	}
+	es.q = q
+	es.t += dt
+	return nil
+}
+
+// Advance интегрирует по времени до момента t_end с шагом по условию Куранта
+// и возвращает число шагов
+func (es *EulerSolver) Advance(t_end float64) (int, error) {
+	steps := 0
+	for es.t < t_end*(1-1e-12) {
+		dt := math.Min(es.Time_step(), t_end-es.t)
+		if err := es.Step(dt); err != nil {
+			return steps, err
+		}
+		steps++
+	}
+	return steps, nil
+}
+
+// Riemann_exact возвращает точное автомодельное решение задачи о распаде
+// разрыва между состояниями l и r (Toro, 2009, гл. 4) как функцию от s = x/t
+func Riemann_exact(l, r GasState, gamma float64) func(s float64) GasState {
+	cl := math.Sqrt(gamma * l.P / l.Rho)
+	cr := math.Sqrt(gamma * r.P / r.Rho)
+	// Функция f_K(p) и её производная для волны со стороны K
+	f := func(p float64, w GasState, c float64) (float64, float64) {
+		if p > w.P {
+			a := 2.0 / ((gamma + 1) * w.Rho)
+			b := (gamma - 1) / (gamma + 1) * w.P
+			q := math.Sqrt(a / (p + b))
+			return (p - w.P) * q, q * (1.0 - 0.5*(p-w.P)/(b+p))
+		}
+		pr := p / w.P
+		return 2.0 * c / (gamma - 1) * (math.Pow(pr, (gamma-1)/(2*gamma)) - 1.0),
+			1.0 / (w.Rho * c) * math.Pow(pr, -(gamma+1)/(2*gamma))
+	}
+	// Давление и скорость на контактном разрыве методом Ньютона
+	p := 0.5 * (l.P + r.P)
+	for k := 0; k < 100; k++ {
+		fl, dl := f(p, l, cl)
+		fr, dr := f(p, r, cr)
+		dp := (fl + fr + r.U - l.U) / (dl + dr)
+		p = math.Max(p-dp, 1e-8)
+		if math.Abs(dp) < 1e-12*p {
+			break
+		}
+	}
+	fl, _ := f(p, l, cl)
+	fr, _ := f(p, r, cr)
+	u := 0.5*(l.U+r.U) + 0.5*(fr-fl)
+
+	// side возвращает решение по одну сторону контакта; sign = -1 слева, +1 справа
+	side := func(s float64, w GasState, c float64, sign float64) GasState {
+		g1 := (gamma - 1) / (gamma + 1)
+		if p > w.P {
+			// Ударная волна
+			rho := w.Rho * (p/w.P + g1) / (g1*p/w.P + 1.0)
+			shock := w.U + sign*c*math.Sqrt((gamma+1)/(2*gamma)*p/w.P+(gamma-1)/(2*gamma))
+			if sign*(s-shock) > 0 {
+				return w
+			}
+			return GasState{Rho: rho, U: u, P: p}
+		}
+		// Волна разрежения
+		rho := w.Rho * math.Pow(p/w.P, 1.0/gamma)
+		cs := c * math.Pow(p/w.P, (gamma-1)/(2*gamma))
+		head := w.U + sign*c
+		tail := u + sign*cs
+		if sign*(s-head) > 0 {
+			return w
+		}
+		if sign*(s-tail) < 0 {
+			return GasState{Rho: rho, U: u, P: p}
+		}
+		un := 2.0 / (gamma + 1) * (-sign*c + 0.5*(gamma-1)*w.U + s)
+		cf := -sign * (un - s)
+		return GasState{Rho: w.Rho * math.Pow(cf/c, 2.0/(gamma-1)), U: un, P: w.P * math.Pow(cf/c, 2*gamma/(gamma-1))}
+	}
+	return func(s float64) GasState {
+		if s < u {
+			return side(s, l, cl, -1)
+		}
+		return side(s, r, cr, 1)
+	}
+}
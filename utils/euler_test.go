@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEulerFluxConsistency(t *testing.T) {
+	states := []GasState{
+		{Rho: 1, U: 0, V: 0, P: 1},
+		{Rho: 0.125, U: 0.3, V: -0.2, P: 0.1},
+		{Rho: 1.4, U: 3, V: 0.5, P: 1},     // supersonic along x
+		{Rho: 2, U: -0.4, V: 1.2, P: 0.75}, // flow against the normal
+	}
+	normals := []Point{{X: 1}, {Y: -1}, {X: 0.6, Y: 0.8}}
+	for _, flux := range []int{Rusanov, Roe, HLLC} {
+		var es EulerSolver
+		if err := es.Set_gas(Gas{Flux: flux}); err != nil {
+			t.Fatalf("Set_gas failed: %v", err)
+		}
+		for _, w := range states {
+			for _, n := range normals {
+				f := es.numerical_flux(w, w, n)
+				exact := es.physical_flux(w, n)
+				for k := range f {
+					if math.Abs(f[k]-exact[k]) > 1e-12*(1+math.Abs(exact[k])) {
+						t.Errorf("Flux %d: F(U, U)[%d] = %g, F(U) = %g for %+v, n = %v",
+							flux, k, f[k], exact[k], w, n)
+					}
+				}
+			}
+		}
+	}
+}
+
+// sod_l1 solves the Sod shock tube on nx cells up to t = 0.2 and returns
+// the L1 error of the density against the exact Riemann solution
+func sod_l1(t *testing.T, gas Gas, nx int) float64 {
+	t.Helper()
+	grid, err := Rect_grid(0, 0, 1, 0.1, nx, 2, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("ends", func(p Point) bool { return p.X < 1e-9 || p.X > 1-1e-9 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := grid.Add_bnd_patch("walls", func(p Point) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	left := GasState{Rho: 1.0, P: 1.0}
+	right := GasState{Rho: 0.125, P: 0.1}
+	var es EulerSolver
+	es.Set_grid(grid)
+	if err := es.Set_gas(gas); err != nil {
+		t.Fatalf("Set_gas failed: %v", err)
+	}
+	es.Set_bc("ends", GasBC{Type: SupersonicOutflow})
+	es.Set_bc("walls", GasBC{Type: SlipWall})
+	es.Set_initial(func(p Point) GasState {
+		if p.X < 0.5 {
+			return left
+		}
+		return right
+	})
+	if _, err := es.Advance(0.2); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+	exact := Riemann_exact(left, right, 1.4)
+	l1, volume := 0.0, 0.0
+	for i, c := range es.grid.Cell_centers {
+		v := math.Abs(es.grid.Cell_volumes[i])
+		l1 += math.Abs(es.State(i).Rho-exact((c.X-0.5)/0.2).Rho) * v
+		volume += v
+	}
+	return l1 / volume
+}
+
+func TestEulerSod(t *testing.T) {
+	for _, flux := range []int{Rusanov, Roe, HLLC} {
+		first := sod_l1(t, Gas{Flux: flux, Order: 1, RK: 1}, 100)
+		second := sod_l1(t, Gas{Flux: flux}, 100)
+		fine := sod_l1(t, Gas{Flux: flux}, 200)
+		t.Logf("Flux %d: L1 %.3e (first order), %.3e, %.3e (MUSCL, 100 and 200 cells)", flux, first, second, fine)
+		if second > 1e-2 || second > 0.5*first {
+			t.Errorf("Flux %d: L1 error %.3e with MUSCL, %.3e first order", flux, second, first)
+		}
+		if rk2 := sod_l1(t, Gas{Flux: flux, RK: 2}, 100); math.Abs(rk2-second) > 0.2*second {
+			t.Errorf("Flux %d: L1 error %.3e with SSP-RK2, %.3e with SSP-RK3", flux, rk2, second)
+		}
+		// Shocks and contacts limit the convergence to first order in L1
+		if fine > 0.75*second {
+			t.Errorf("Flux %d: L1 error %.3e on 200 cells, %.3e on 100", flux, fine, second)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import "math"
+
+// FaceGeometry — геометрия грани, общая для всех дискретизаций
+type FaceGeometry struct {
+	Area   float64 // длина грани (площадь для объёмной сетки)
+	Normal Point   // единичная нормаль от владельца к соседу, для граничной грани — наружу
+	Center Point   // центр грани
+	D_own  Point   // вектор от центра владельца к центру грани
+	D_nei  Point   // вектор от центра грани к центру соседа; для граничной грани нулевой
+	Delta  float64 // расстояние по нормали между центрами ячеек (до грани для граничной)
+	Weight float64 // доля владельца при линейной интерполяции на грань
+}
+
+func dot(a Point, b Point) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func sub(a Point, b Point) Point {
+	return Point{a.X - b.X, a.Y - b.Y, a.Z - b.Z}
+}
+
+// oriented_normal возвращает единичную нормаль к ребру (pi, pj),
+// направленную от точки from
+func oriented_normal(pi Point, pj Point, from Point) Point {
+	normal := find_normal(pi, pj)
+	center := Point{X: pi.X/2.0 + pj.X/2.0, Y: pi.Y/2.0 + pj.Y/2.0, Z: pi.Z/2.0 + pj.Z/2.0}
+	if dot(sub(center, from), normal) < 0 {
+		normal = Point{-normal.X, -normal.Y, -normal.Z}
+	}
+	return normal
+}
+
+// Need_face_geometry вычисляет геометрию всех граней (Face_geometry,
+// по глобальным номерам граней). Центры ячеек вычисляются, если их ещё нет.
+func (grid *VTKGrid) Need_face_geometry() {
+	if len(grid.Cell_centers) != len(grid.Cells) {
+		grid.Need_cell_centers()
+	}
+	grid.Face_geometry = make([]FaceGeometry, len(grid.Faces))
+	for id, face := range grid.Faces {
+		nodes := grid.Face_nodes[id]
+		own := grid.Cell_centers[face.Left]
+		g := FaceGeometry{Weight: 1.0}
+		if len(nodes) == 2 {
+			pi := grid.Points[nodes[0]]
+			pj := grid.Points[nodes[1]]
+			g.Area = math.Sqrt(dot(sub(pj, pi), sub(pj, pi)))
+			g.Normal = oriented_normal(pi, pj, own)
+			g.Center = Point{X: pi.X/2.0 + pj.X/2.0, Y: pi.Y/2.0 + pj.Y/2.0, Z: pi.Z/2.0 + pj.Z/2.0}
+		} else {
+			var S Point
+			S, g.Center = grid.polygon_area(nodes)
+			g.Area = math.Sqrt(dot(S, S))
+			g.Normal = Point{S.X / g.Area, S.Y / g.Area, S.Z / g.Area}
+			if dot(sub(g.Center, own), g.Normal) < 0 {
+				g.Normal = Point{-g.Normal.X, -g.Normal.Y, -g.Normal.Z}
+			}
+		}
+		g.D_own = sub(g.Center, own)
+		dl := math.Abs(dot(g.D_own, g.Normal))
+		g.Delta = dl
+		if face.Right != -1 {
+			g.D_nei = sub(grid.Cell_centers[face.Right], g.Center)
+			dr := math.Abs(dot(g.D_nei, g.Normal))
+			g.Delta = dl + dr
+			g.Weight = dr / (dl + dr)
+		}
+		grid.Face_geometry[id] = g
+	}
+}
+
+// In_geometry возвращает геометрию внутренней грани с номером i в Faces_in_cel
+func (grid *VTKGrid) In_geometry(i int) *FaceGeometry {
+	return &grid.Face_geometry[grid.Faces_in_id[i]]
+}
+
+// Bnd_geometry возвращает геометрию граничной грани с номером i в Faces_bnd_cel
+func (grid *VTKGrid) Bnd_geometry(i int) *FaceGeometry {
+	return &grid.Face_geometry[grid.Faces_bnd_id[i]]
+}
+
+// owner_distances возвращает расстояния по нормали от центров владельца
+// и соседа до грани
+func (g *FaceGeometry) owner_distances() (float64, float64) {
+	return math.Abs(dot(g.D_own, g.Normal)), math.Abs(dot(g.D_nei, g.Normal))
+}
@@ -0,0 +1,529 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gmsh_elements описывает типы элементов Gmsh: размерность, тип ячейки VTK,
+// число узлов и число угловых узлов (у элементов высокого порядка
+// угловые узлы идут первыми, остальные отбрасываются)
+var gmsh_elements = map[int]struct{ dim, vtk, nodes, corners int }{
+	15: {0, 0, 1, 1},
+	1:  {1, 0, 2, 2},
+	8:  {1, 0, 3, 2},
+	2:  {2, VTKTriangle, 3, 3},
+	9:  {2, VTKTriangle, 6, 3},
+	3:  {2, VTKQuad, 4, 4},
+	16: {2, VTKQuad, 8, 4},
+	10: {2, VTKQuad, 9, 4},
+	4:  {3, VTKTetra, 4, 4},
+	11: {3, VTKTetra, 10, 4},
+	5:  {3, VTKHexahedron, 8, 8},
+	17: {3, VTKHexahedron, 20, 8},
+	12: {3, VTKHexahedron, 27, 8},
+	6:  {3, VTKWedge, 6, 6},
+	18: {3, VTKWedge, 15, 6},
+	13: {3, VTKWedge, 18, 6},
+	7:  {3, VTKPyramid, 5, 5},
+	19: {3, VTKPyramid, 13, 5},
+	14: {3, VTKPyramid, 14, 5},
+}
+
+type gmsh_element struct {
+	kind     int   // тип элемента Gmsh
+	physical []int // физические группы элемента
+	nodes    []int // номера узлов Gmsh
+}
+
+// gmsh_reader читает файл .msh; после первой ошибки чтение прекращается,
+// а ошибка сохраняется в err
+type gmsh_reader struct {
+	r      *bufio.Reader
+	binary bool
+	order  binary.ByteOrder
+	size_t int // размер size_t в двоичном формате 4.1
+	err    error
+
+	version    float64
+	names      map[[2]int]string // имена физических групп по (размерность, номер)
+	entities   map[[2]int][]int  // физические группы сущностей формата 4.1
+	node_order []int             // номера узлов в порядке записи
+	nodes      map[int]Point
+	elements   []gmsh_element
+}
+
+func (gr *gmsh_reader) fail(format string, args ...interface{}) {
+	if gr.err == nil {
+		gr.err = fmt.Errorf(format, args...)
+	}
+}
+
+// line возвращает следующую строку без пробелов по краям
+func (gr *gmsh_reader) line() string {
+	if gr.err != nil {
+		return ""
+	}
+	s, err := gr.r.ReadString('\n')
+	if err != nil && (err != io.EOF || s == "") {
+		gr.fail("ошибка при чтении файла: %v", err)
+	}
+	return strings.TrimSpace(s)
+}
+
+// word возвращает следующее слово текста
+func (gr *gmsh_reader) word() string {
+	var sb strings.Builder
+	for gr.err == nil {
+		c, err := gr.r.ReadByte()
+		if err != nil {
+			if err != io.EOF || sb.Len() == 0 {
+				gr.fail("ошибка при чтении файла: %v", err)
+			}
+			break
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if sb.Len() > 0 {
+				break
+			}
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+func (gr *gmsh_reader) atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		gr.fail("ошибка при парсинге целого числа %q", s)
+	}
+	return n
+}
+
+func (gr *gmsh_reader) bytes(n int) []byte {
+	b := make([]byte, n)
+	if gr.err == nil {
+		if _, err := io.ReadFull(gr.r, b); err != nil {
+			gr.fail("ошибка при чтении двоичных данных: %v", err)
+		}
+	}
+	return b
+}
+
+// int читает int: слово текста или 4 байта
+func (gr *gmsh_reader) int() int {
+	if !gr.binary {
+		return gr.atoi(gr.word())
+	}
+	return int(int32(gr.order.Uint32(gr.bytes(4))))
+}
+
+// size читает size_t формата 4.1
+func (gr *gmsh_reader) size() int {
+	if !gr.binary {
+		return gr.atoi(gr.word())
+	}
+	if gr.size_t == 4 {
+		return int(gr.order.Uint32(gr.bytes(4)))
+	}
+	return int(gr.order.Uint64(gr.bytes(8)))
+}
+
+// double читает число с плавающей точкой: слово текста или 8 байт
+func (gr *gmsh_reader) double() float64 {
+	if !gr.binary {
+		s := gr.word()
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			gr.fail("ошибка при парсинге числа %q", s)
+		}
+		return x
+	}
+	return math.Float64frombits(gr.order.Uint64(gr.bytes(8)))
+}
+
+// count проверяет, что число элементов массива неотрицательно и не превышает max
+func (gr *gmsh_reader) count(n, max int, what string) int {
+	if n < 0 || n > max {
+		gr.fail("неверное число %s: %d", what, n)
+		return 0
+	}
+	return n
+}
+
+// skip пропускает строки до конца секции end
+func (gr *gmsh_reader) skip(end string) {
+	for gr.err == nil && gr.line() != end {
+	}
+}
+
+// Верхняя граница размеров массивов, защищающая от испорченных заголовков
+const gmsh_max_count = 1 << 30
+
+func (gr *gmsh_reader) mesh_format() {
+	parts := strings.Fields(gr.line())
+	if len(parts) < 3 {
+		gr.fail("неверная секция $MeshFormat")
+		return
+	}
+	gr.version, _ = strconv.ParseFloat(parts[0], 64)
+	if gr.version < 2 || gr.version >= 3 && gr.version < 4.1 || gr.version >= 5 {
+		gr.fail("неподдерживаемая версия формата Gmsh %s (поддерживаются 2.x и 4.1)", parts[0])
+		return
+	}
+	gr.binary = parts[1] == "1"
+	gr.size_t = gr.atoi(parts[2])
+	if gr.binary {
+		// Число 1 в двоичном виде определяет порядок байтов
+		b := gr.bytes(4)
+		gr.order = binary.LittleEndian
+		if binary.LittleEndian.Uint32(b) != 1 {
+			gr.order = binary.BigEndian
+		}
+		if gr.order.Uint32(b) != 1 {
+			gr.fail("неверная метка порядка байтов")
+		}
+		if gr.version >= 4 && gr.size_t != 4 && gr.size_t != 8 {
+			gr.fail("неподдерживаемый размер size_t: %d", gr.size_t)
+		}
+	}
+	gr.skip("$EndMeshFormat")
+}
+
+func (gr *gmsh_reader) physical_names() {
+	n := gr.count(gr.atoi(gr.line()), gmsh_max_count, "физических групп")
+	for i := 0; i < n && gr.err == nil; i++ {
+		s := gr.line()
+		parts := strings.Fields(s)
+		first, last := strings.IndexByte(s, '"'), strings.LastIndexByte(s, '"')
+		if len(parts) < 3 || first < 0 || last <= first {
+			gr.fail("неверное описание физической группы %q", s)
+			return
+		}
+		gr.names[[2]int{gr.atoi(parts[0]), gr.atoi(parts[1])}] = s[first+1 : last]
+	}
+	gr.skip("$EndPhysicalNames")
+}
+
+// entities читает физические группы точек, кривых, поверхностей и объёмов (формат 4.1)
+func (gr *gmsh_reader) read_entities() {
+	var counts [4]int
+	for d := range counts {
+		counts[d] = gr.count(gr.size(), gmsh_max_count, "сущностей")
+	}
+	for d := 0; d < 4; d++ {
+		for i := 0; i < counts[d] && gr.err == nil; i++ {
+			tag := gr.int()
+			box := 6
+			if d == 0 {
+				box = 3
+			}
+			for k := 0; k < box; k++ {
+				gr.double()
+			}
+			physical := make([]int, gr.count(gr.size(), gmsh_max_count, "физических групп"))
+			for k := range physical {
+				physical[k] = gr.int()
+			}
+			sort.Ints(physical)
+			gr.entities[[2]int{d, tag}] = physical
+			if d > 0 {
+				bounding := gr.count(gr.size(), gmsh_max_count, "граничных сущностей")
+				for k := 0; k < bounding; k++ {
+					gr.int()
+				}
+			}
+		}
+	}
+	gr.skip("$EndEntities")
+}
+
+func (gr *gmsh_reader) add_node(tag int, p Point) {
+	if _, ok := gr.nodes[tag]; ok {
+		gr.fail("узел %d задан повторно", tag)
+		return
+	}
+	gr.nodes[tag] = p
+	gr.node_order = append(gr.node_order, tag)
+}
+
+func (gr *gmsh_reader) read_nodes() {
+	if gr.version < 4 {
+		n := gr.count(gr.atoi(gr.line()), gmsh_max_count, "узлов")
+		for i := 0; i < n && gr.err == nil; i++ {
+			tag := gr.int()
+			gr.add_node(tag, Point{X: gr.double(), Y: gr.double(), Z: gr.double()})
+		}
+		gr.skip("$EndNodes")
+		return
+	}
+	blocks := gr.count(gr.size(), gmsh_max_count, "блоков узлов")
+	gr.size()
+	gr.size()
+	gr.size()
+	for b := 0; b < blocks && gr.err == nil; b++ {
+		dim := gr.int()
+		gr.int()
+		parametric := gr.int()
+		n := gr.count(gr.size(), gmsh_max_count, "узлов")
+		tags := make([]int, n)
+		for i := range tags {
+			tags[i] = gr.size()
+		}
+		for i := 0; i < n && gr.err == nil; i++ {
+			gr.add_node(tags[i], Point{X: gr.double(), Y: gr.double(), Z: gr.double()})
+			if parametric != 0 {
+				for k := 0; k < dim; k++ {
+					gr.double()
+				}
+			}
+		}
+	}
+	gr.skip("$EndNodes")
+}
+
+func (gr *gmsh_reader) element_nodes(kind int) int {
+	e, ok := gmsh_elements[kind]
+	if !ok {
+		gr.fail("неподдерживаемый тип элемента Gmsh %d", kind)
+		return 0
+	}
+	return e.nodes
+}
+
+func (gr *gmsh_reader) read_elements() {
+	if gr.version < 4 {
+		n := gr.count(gr.atoi(gr.line()), gmsh_max_count, "элементов")
+		for read := 0; read < n && gr.err == nil; {
+			// В двоичном формате 2 элементы идут блоками одного типа
+			// с заголовком (тип, число элементов, число меток)
+			kind, count, ntags := 0, 1, 0
+			if gr.binary {
+				kind, count, ntags = gr.int(), gr.int(), gr.int()
+				if count <= 0 || count > n-read {
+					gr.fail("неверное число элементов в блоке: %d", count)
+				}
+			}
+			for i := 0; i < count && gr.err == nil; i++ {
+				gr.int()
+				if !gr.binary {
+					kind, ntags = gr.int(), gr.int()
+				}
+				tags := make([]int, gr.count(ntags, gmsh_max_count, "меток"))
+				for k := range tags {
+					tags[k] = gr.int()
+				}
+				e := gmsh_element{kind: kind, nodes: make([]int, gr.element_nodes(kind))}
+				for k := range e.nodes {
+					e.nodes[k] = gr.int()
+				}
+				// Первая метка — физическая группа, 0 означает её отсутствие
+				if len(tags) > 0 && tags[0] != 0 {
+					e.physical = []int{tags[0]}
+				}
+				gr.elements = append(gr.elements, e)
+				read++
+			}
+		}
+		gr.skip("$EndElements")
+		return
+	}
+	blocks := gr.count(gr.size(), gmsh_max_count, "блоков элементов")
+	gr.size()
+	gr.size()
+	gr.size()
+	for b := 0; b < blocks && gr.err == nil; b++ {
+		dim, entity, kind := gr.int(), gr.int(), gr.int()
+		n := gr.count(gr.size(), gmsh_max_count, "элементов")
+		nn := gr.element_nodes(kind)
+		physical := gr.entities[[2]int{dim, entity}]
+		for i := 0; i < n && gr.err == nil; i++ {
+			gr.size()
+			e := gmsh_element{kind: kind, physical: physical, nodes: make([]int, nn)}
+			for k := range e.nodes {
+				e.nodes[k] = gr.size()
+			}
+			gr.elements = append(gr.elements, e)
+		}
+	}
+	gr.skip("$EndElements")
+}
+
+// group_name возвращает имя физической группы или её номер, если имени нет
+func (gr *gmsh_reader) group_name(dim, tag int) string {
+	if name, ok := gr.names[[2]int{dim, tag}]; ok {
+		return name
+	}
+	return strconv.Itoa(tag)
+}
+
+// grid строит сетку из элементов наибольшей размерности. Физические группы
+// элементов на единицу меньшей размерности становятся участками границы,
+// физические группы самих ячеек — зонами ячеек.
+func (gr *gmsh_reader) grid() (VTKGrid, error) {
+	dim := 0
+	for _, e := range gr.elements {
+		if d := gmsh_elements[e.kind].dim; d > dim {
+			dim = d
+		}
+	}
+	if dim < 2 {
+		return VTKGrid{}, fmt.Errorf("в файле нет двумерных или трёхмерных элементов")
+	}
+	format := "ASCII"
+	if gr.binary {
+		format = "BINARY"
+	}
+	grid := VTKGrid{
+		Title:       strconv.FormatFloat(gr.version, 'g', -1, 64),
+		Format:      format,
+		DatasetType: "UNSTRUCTURED_GRID",
+	}
+
+	// Нумеруем только узлы, входящие в ячейки, в порядке их записи в файле
+	used := make(map[int]bool)
+	for _, e := range gr.elements {
+		info := gmsh_elements[e.kind]
+		if info.dim != dim {
+			continue
+		}
+		for _, tag := range e.nodes[:info.corners] {
+			if _, ok := gr.nodes[tag]; !ok {
+				return VTKGrid{}, fmt.Errorf("элемент ссылается на несуществующий узел %d", tag)
+			}
+			used[tag] = true
+		}
+	}
+	index := make(map[int]int)
+	for _, tag := range gr.node_order {
+		if used[tag] {
+			index[tag] = len(grid.Points)
+			grid.Points = append(grid.Points, gr.nodes[tag])
+		}
+	}
+
+	zones := make(map[int][]int)
+	var faces []gmsh_element
+	for _, e := range gr.elements {
+		info := gmsh_elements[e.kind]
+		if info.dim == dim-1 && len(e.physical) > 0 {
+			faces = append(faces, e)
+		}
+		if info.dim != dim {
+			continue
+		}
+		indices := make([]int, info.corners)
+		for k, tag := range e.nodes[:info.corners] {
+			indices[k] = index[tag]
+		}
+		for _, p := range e.physical {
+			zones[p] = append(zones[p], len(grid.Cells))
+		}
+		grid.Cells = append(grid.Cells, Cell{Indices: indices})
+		grid.CellTypes = append(grid.CellTypes, info.vtk)
+	}
+	if err := grid.build_faces(); err != nil {
+		return VTKGrid{}, err
+	}
+
+	// Граничные элементы сопоставляются граничным граням по набору узлов;
+	// грань, входящая в несколько групп, относится к группе с меньшим номером
+	bnd := make(map[[4]int]int)
+	for i, id := range grid.Faces_bnd_id {
+		bnd[face_key(grid.Face_nodes[id])] = i
+	}
+	patches := make(map[int][]int)
+	taken := make([]bool, len(grid.Faces_bnd_cel))
+	sort.SliceStable(faces, func(a, b int) bool { return faces[a].physical[0] < faces[b].physical[0] })
+	for _, e := range faces {
+		nodes := make([]int, gmsh_elements[e.kind].corners)
+		for k, tag := range e.nodes[:len(nodes)] {
+			n, ok := index[tag]
+			if !ok {
+				nodes = nil
+				break
+			}
+			nodes[k] = n
+		}
+		i, ok := bnd[face_key(nodes)]
+		if nodes == nil || !ok || taken[i] {
+			continue
+		}
+		taken[i] = true
+		patches[e.physical[0]] = append(patches[e.physical[0]], i)
+	}
+
+	for _, tag := range sorted_keys(patches) {
+		grid.Bnd_patches = append(grid.Bnd_patches, BndPatch{Name: gr.group_name(dim-1, tag), Faces: patches[tag]})
+	}
+	for _, tag := range sorted_keys(zones) {
+		grid.Cell_zones = append(grid.Cell_zones, CellZone{Name: gr.group_name(dim, tag), Cells: zones[tag]})
+	}
+	return grid, nil
+}
+
+func sorted_keys(m map[int][]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// Read_gmsh читает сетку Gmsh (.msh) версий 2.x и 4.1 в текстовом или
+// двоичном виде. Ячейками становятся элементы наибольшей размерности
+// (у элементов высокого порядка берутся только угловые узлы), физические
+// группы граничных элементов — участками границы Bnd_patches, физические
+// группы ячеек — зонами Cell_zones. Безымянные группы называются по номеру.
+func Read_gmsh(r io.Reader) (VTKGrid, error) {
+	gr := gmsh_reader{
+		r:        bufio.NewReader(r),
+		names:    make(map[[2]int]string),
+		entities: make(map[[2]int][]int),
+		nodes:    make(map[int]Point),
+	}
+	format := false
+	for {
+		s, err := gr.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return VTKGrid{}, fmt.Errorf("ошибка при чтении файла: %v", err)
+		}
+		section := strings.TrimSpace(s)
+		switch {
+		case section == "":
+		case section == "$MeshFormat":
+			gr.mesh_format()
+			format = true
+		case !format:
+			return VTKGrid{}, fmt.Errorf("файл не начинается с секции $MeshFormat")
+		case section == "$PhysicalNames":
+			gr.physical_names()
+		case section == "$Entities" && gr.version >= 4:
+			gr.read_entities()
+		case section == "$Nodes":
+			gr.read_nodes()
+		case section == "$Elements":
+			gr.read_elements()
+		case strings.HasPrefix(section, "$"):
+			gr.skip("$End" + section[1:])
+		}
+		if gr.err != nil {
+			return VTKGrid{}, gr.err
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if !format {
+		return VTKGrid{}, fmt.Errorf("файл не является сеткой Gmsh")
+	}
+	return gr.grid()
+}
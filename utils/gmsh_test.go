@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testElement struct {
+	kind, phys, entity int
+	nodes              []int
+}
+
+// The unit square split into four triangles around its center, with an
+// unused node, a physical point, two boundary groups and two cell zones
+var squareNodes = []struct {
+	tag int
+	p   Point
+}{{1, Point{0, 0, 0}}, {2, Point{1, 0, 0}}, {3, Point{1, 1, 0}}, {4, Point{0, 1, 0}},
+	{5, Point{0.5, 0.5, 0}}, {10, Point{2, 2, 0}}}
+
+var squareElements = []testElement{
+	{15, 5, 1, []int{1}},
+	{1, 1, 1, []int{1, 2}}, {1, 2, 2, []int{2, 3}}, {1, 2, 3, []int{3, 4}}, {1, 2, 4, []int{4, 1}},
+	{2, 10, 1, []int{1, 2, 5}}, {2, 10, 1, []int{4, 1, 5}},
+	{2, 11, 2, []int{2, 3, 5}}, {2, 11, 2, []int{3, 4, 5}},
+}
+
+// mshWriter emits numbers as text separated by spaces or as little-endian binary
+type mshWriter struct {
+	bytes.Buffer
+	binary bool
+}
+
+func (w *mshWriter) int(v int) {
+	if w.binary {
+		binary.Write(w, binary.LittleEndian, int32(v))
+	} else {
+		fmt.Fprintf(w, "%d ", v)
+	}
+}
+
+func (w *mshWriter) size(v int) {
+	if w.binary {
+		binary.Write(w, binary.LittleEndian, uint64(v))
+	} else {
+		fmt.Fprintf(w, "%d ", v)
+	}
+}
+
+func (w *mshWriter) double(v float64) {
+	if w.binary {
+		binary.Write(w, binary.LittleEndian, v)
+	} else {
+		fmt.Fprintf(w, "%v ", v)
+	}
+}
+
+func (w *mshWriter) nl() {
+	if !w.binary {
+		w.WriteString("\n")
+	}
+}
+
+// squareMsh writes the square mesh in Gmsh format 2.2 or 4.1
+func squareMsh(version string, bin bool) []byte {
+	w := &mshWriter{binary: bin}
+	fileType := 0
+	if bin {
+		fileType = 1
+	}
+	fmt.Fprintf(w, "$MeshFormat\n%s %d 8\n", version, fileType)
+	if bin {
+		binary.Write(w, binary.LittleEndian, int32(1))
+		w.WriteString("\n")
+	}
+	w.WriteString("$EndMeshFormat\n$PhysicalNames\n3\n1 1 \"bottom\"\n1 2 \"walls\"\n2 10 \"a\"\n$EndPhysicalNames\n")
+	dim := map[int]int{15: 0, 1: 1, 2: 2}
+
+	if version == "2.2" {
+		fmt.Fprintf(w, "$Nodes\n%d\n", len(squareNodes))
+		for _, n := range squareNodes {
+			w.int(n.tag)
+			w.double(n.p.X)
+			w.double(n.p.Y)
+			w.double(n.p.Z)
+			w.nl()
+		}
+		fmt.Fprintf(w, "\n$EndNodes\n$Elements\n%d\n", len(squareElements))
+		for i, e := range squareElements {
+			if bin && (i == 0 || e.kind != squareElements[i-1].kind) {
+				count := 0
+				for _, f := range squareElements[i:] {
+					if f.kind != e.kind {
+						break
+					}
+					count++
+				}
+				w.int(e.kind)
+				w.int(count)
+				w.int(2)
+			}
+			w.int(i + 1)
+			if !bin {
+				w.int(e.kind)
+				w.int(2)
+			}
+			w.int(e.phys)
+			w.int(e.entity)
+			for _, n := range e.nodes {
+				w.int(n)
+			}
+			w.nl()
+		}
+		w.WriteString("\n$EndElements\n")
+		return w.Bytes()
+	}
+
+	// Entities: one point, four curves and two surfaces with their physical groups
+	w.WriteString("$Entities\n")
+	w.size(1)
+	w.size(4)
+	w.size(2)
+	w.size(0)
+	w.nl()
+	entities := map[[2]int]int{}
+	for _, e := range squareElements {
+		entities[[2]int{dim[e.kind], e.entity}] = e.phys
+	}
+	for _, d := range []int{0, 1, 2} {
+		for tag := 1; entities[[2]int{d, tag}] != 0; tag++ {
+			w.int(tag)
+			box := 6
+			if d == 0 {
+				box = 3
+			}
+			for k := 0; k < box; k++ {
+				w.double(0)
+			}
+			w.size(1)
+			w.int(entities[[2]int{d, tag}])
+			if d > 0 {
+				w.size(0)
+			}
+			w.nl()
+		}
+	}
+	w.WriteString("\n$EndEntities\n$Nodes\n")
+	w.size(1)
+	w.size(len(squareNodes))
+	w.size(1)
+	w.size(10)
+	w.nl()
+	w.int(2)
+	w.int(1)
+	w.int(0)
+	w.size(len(squareNodes))
+	w.nl()
+	for _, n := range squareNodes {
+		w.size(n.tag)
+		w.nl()
+	}
+	for _, n := range squareNodes {
+		w.double(n.p.X)
+		w.double(n.p.Y)
+		w.double(n.p.Z)
+		w.nl()
+	}
+	w.WriteString("\n$EndNodes\n$Elements\n")
+	type block struct{ dim, entity, kind int }
+	var blocks []block
+	members := map[block][]int{}
+	for i, e := range squareElements {
+		b := block{dim[e.kind], e.entity, e.kind}
+		if members[b] == nil {
+			blocks = append(blocks, b)
+		}
+		members[b] = append(members[b], i)
+	}
+	w.size(len(blocks))
+	w.size(len(squareElements))
+	w.size(1)
+	w.size(len(squareElements))
+	w.nl()
+	for _, b := range blocks {
+		w.int(b.dim)
+		w.int(b.entity)
+		w.int(b.kind)
+		w.size(len(members[b]))
+		w.nl()
+		for _, i := range members[b] {
+			w.size(i + 1)
+			for _, n := range squareElements[i].nodes {
+				w.size(n)
+			}
+			w.nl()
+		}
+	}
+	w.WriteString("\n$EndElements\n")
+	return w.Bytes()
+}
+
+func TestGmshFormats(t *testing.T) {
+	for _, version := range []string{"2.2", "4.1"} {
+		for _, bin := range []bool{false, true} {
+			name := fmt.Sprintf("v%s binary=%v", version, bin)
+			grid, err := Read_gmsh(bytes.NewReader(squareMsh(version, bin)))
+			if err != nil {
+				t.Fatalf("%s: Read_gmsh failed: %v", name, err)
+			}
+			if len(grid.Points) != 5 || len(grid.Cells) != 4 {
+				t.Fatalf("%s: got %d points and %d cells, expected 5 and 4", name, len(grid.Points), len(grid.Cells))
+			}
+			if grid.Dim != 2 || len(grid.Faces_bnd_cel) != 4 || len(grid.Faces_in_cel) != 4 {
+				t.Errorf("%s: unexpected topology", name)
+			}
+			if !reflect.DeepEqual(grid.CellTypes, []int{VTKTriangle, VTKTriangle, VTKTriangle, VTKTriangle}) {
+				t.Errorf("%s: cell types %v", name, grid.CellTypes)
+			}
+			if p := grid.Points[4]; p != (Point{0.5, 0.5, 0}) {
+				t.Errorf("%s: center node at %v", name, p)
+			}
+
+			if len(grid.Bnd_patches) != 2 {
+				t.Fatalf("%s: got %d boundary patches, expected 2", name, len(grid.Bnd_patches))
+			}
+			bottom, walls := grid.Patch("bottom"), grid.Patch("walls")
+			if bottom == nil || walls == nil || len(bottom.Faces) != 1 || len(walls.Faces) != 3 {
+				t.Fatalf("%s: wrong boundary patches %v", name, grid.Bnd_patches)
+			}
+			if c := grid.Bnd_face_center(bottom.Faces[0]); math.Abs(c.X-0.5) > 1e-15 || c.Y != 0 {
+				t.Errorf("%s: bottom face centered at %v", name, c)
+			}
+
+			grid.Need_cell_centers()
+			a, b := grid.Zone("a"), grid.Zone("11")
+			if a == nil || b == nil || len(grid.Cell_zones) != 2 {
+				t.Fatalf("%s: wrong cell zones %v", name, grid.Cell_zones)
+			}
+			for _, c := range a.Cells {
+				if grid.Cell_centers[c].Y > 0.5 && grid.Cell_centers[c].X > 0.5 {
+					t.Errorf("%s: cell %d at %v is not in zone a", name, c, grid.Cell_centers[c])
+				}
+			}
+			if len(a.Cells) != 2 || len(b.Cells) != 2 {
+				t.Errorf("%s: zones have %d and %d cells, expected 2 and 2", name, len(a.Cells), len(b.Cells))
+			}
+		}
+	}
+}
+
+func TestGmshErrors(t *testing.T) {
+	data := squareMsh("4.1", true)
+	if _, err := Read_gmsh(bytes.NewReader(data[:len(data)-40])); err == nil {
+		t.Error("Expected an error for a truncated binary file")
+	}
+	if _, err := Read_gmsh(bytes.NewReader([]byte("$MeshFormat\n4.0 0 8\n$EndMeshFormat\n"))); err == nil {
+		t.Error("Expected an error for the unsupported format 4.0")
+	}
+	missing := "$MeshFormat\n2.2 0 8\n$EndMeshFormat\n" +
+		"$Nodes\n3\n1 0 0 0\n2 1 0 0\n3 0 1 0\n$EndNodes\n" +
+		"$Elements\n1\n1 2 2 0 1 1 2 9\n$EndElements\n"
+	if _, err := Read_gmsh(bytes.NewReader([]byte(missing))); err == nil {
+		t.Error("Expected an error for an element with a missing node")
+	}
+}
+
+func TestGmshSolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "square.msh")
+	if err := os.WriteFile(path, squareMsh("4.1", false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	grid, err := Grid(path)
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	// A linear solution with Dirichlet data on both imported patches
+	exact := func(p Point) float64 { return 2 + p.X - p.Y }
+	var solver Solver
+	solver.Set_grid(grid)
+	if err := solver.Set_problem(Problem{Exact: exact}); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	for _, patch := range []string{"bottom", "walls"} {
+		if err := solver.Set_bc(patch, DirichletBC(exact)); err != nil {
+			t.Fatalf("Set_bc failed: %v", err)
+		}
+	}
+	if err := solver.Approximate_parts(); err != nil {
+		t.Fatalf("Approximate_parts failed: %v", err)
+	}
+	norms, err := solver.Errors(exact)
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if norms.Linf > 1e-8 {
+		t.Errorf("Max error %e on the imported mesh", norms.Linf)
+	}
+}
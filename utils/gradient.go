@@ -0,0 +1,322 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Способы вычисления градиента поля в центрах ячеек
+const (
+	GreenGauss     = iota // Гаусс–Остроградский, значение на грани — линейная интерполяция
+	GreenGaussNode        // Гаусс–Остроградский, значение на грани — среднее узловых значений
+	LeastSquares          // взвешенный метод наименьших квадратов с весами 1/|d|^2
+)
+
+// Ограничители градиента
+const (
+	NoLimiter       = iota
+	BarthJespersen  // ограничитель Барта–Джесперсена
+	Venkatakrishnan // гладкий ограничитель Венкатакришнана
+)
+
+// Gradient вычисляет градиент поля u в центрах ячеек способом method.
+// Значения на граничных гранях берутся из bnd (по граням Faces_bnd_cel)
+// или, если bnd == nil, из прилежащих ячеек.
+func (grid *VTKGrid) Gradient(u []float64, bnd []float64, method int) ([]Point, error) {
+	if len(u) != len(grid.Cells) {
+		return nil, fmt.Errorf("размер поля %d не совпадает с числом ячеек %d", len(u), len(grid.Cells))
+	}
+	if bnd != nil && len(bnd) != len(grid.Faces_bnd_cel) {
+		return nil, fmt.Errorf("размер граничных значений %d не совпадает с числом граничных граней %d",
+			len(bnd), len(grid.Faces_bnd_cel))
+	}
+	if len(grid.Face_geometry) != len(grid.Faces) {
+		grid.Need_face_geometry()
+	}
+	switch method {
+	case GreenGauss:
+		return green_gauss(grid, u, bnd), nil
+	case GreenGaussNode:
+		return green_gauss_node(grid, u, bnd), nil
+	case LeastSquares:
+		return least_squares(grid, u, bnd), nil
+	}
+	return nil, fmt.Errorf("неизвестный способ вычисления градиента: %d", method)
+}
+
+// Set_gradient задаёт способ вычисления градиента в поправках отложенной
+// коррекции (TVD-схемы и неортогональность сетки) и в Gradient. По умолчанию
+// используется МНК: Гаусс–Остроградский с линейной интерполяцией на грань
+// несогласован на скошенных сетках, и поправка не даёт второго порядка.
+func (solver *Solver) Set_gradient(method int) error {
+	if method < GreenGauss || method > LeastSquares {
+		return fmt.Errorf("неизвестный способ вычисления градиента: %d", method)
+	}
+	solver.grad_method, solver.grad_set = method, true
+	return nil
+}
+
+// gradient_method возвращает способ вычисления градиента для поправок
+func (solver *Solver) gradient_method() int {
+	if !solver.grad_set {
+		return LeastSquares
+	}
+	return solver.grad_method
+}
+
+// Gradient возвращает градиент решения в центрах ячеек с учётом
+// граничных условий Дирихле
+func (solver *Solver) Gradient() ([]Point, error) {
+	if len(solver.x) != len(solver.grid.Cells) {
+		return nil, fmt.Errorf("решение не вычислено")
+	}
+	bcs, err := solver.face_bcs()
+	if err != nil {
+		return nil, err
+	}
+	return solver.grid.Gradient(solver.x, solver.bnd_values(solver.x, bcs, solver.t), solver.gradient_method())
+}
+
+// Limit_gradient возвращает градиент grad поля u, ограниченный так, чтобы
+// линейная реконструкция на гранях не выходила за пределы значений
+// в соседних ячейках (и на граничных гранях из bnd, если bnd != nil).
+// k — параметр ограничителя Венкатакришнана (обычно от 0.3 до 5).
+func (grid *VTKGrid) Limit_gradient(u []float64, bnd []float64, grad []Point, limiter int, k float64) ([]Point, error) {
+	if len(u) != len(grid.Cells) || len(grad) != len(grid.Cells) {
+		return nil, fmt.Errorf("размер поля не совпадает с числом ячеек %d", len(grid.Cells))
+	}
+	if limiter < NoLimiter || limiter > Venkatakrishnan {
+		return nil, fmt.Errorf("неизвестный ограничитель: %d", limiter)
+	}
+	limited := make([]Point, len(grad))
+	copy(limited, grad)
+	if limiter == NoLimiter {
+		return limited, nil
+	}
+	if len(grid.Face_geometry) != len(grid.Faces) {
+		grid.Need_face_geometry()
+	}
+
+	umin := make([]float64, len(u))
+	umax := make([]float64, len(u))
+	copy(umin, u)
+	copy(umax, u)
+	for _, face := range grid.Faces_in_cel {
+		l, r := face[1].Left, face[1].Right
+		umin[l] = math.Min(umin[l], u[r])
+		umax[l] = math.Max(umax[l], u[r])
+		umin[r] = math.Min(umin[r], u[l])
+		umax[r] = math.Max(umax[r], u[l])
+	}
+	if bnd != nil {
+		for i, face := range grid.Faces_bnd_cel {
+			l := face[1].Left
+			umin[l] = math.Min(umin[l], bnd[i])
+			umax[l] = math.Max(umax[l], bnd[i])
+		}
+	}
+
+	phi := make([]float64, len(u))
+	for i := range phi {
+		phi[i] = 1.0
+	}
+	limit := func(i int, x Point) {
+		d2 := dot(grad[i], sub(x, grid.Cell_centers[i]))
+		var d1 float64
+		if d2 > 0 {
+			d1 = umax[i] - u[i]
+		} else {
+			d1 = umin[i] - u[i]
+		}
+		switch limiter {
+		case BarthJespersen:
+			if math.Abs(d2) > 1e-14 {
+				phi[i] = math.Min(phi[i], d1/d2)
+			}
+		case Venkatakrishnan:
+			if d2 == 0 {
+				return
+			}
+			h := grid.cell_size(grid.Cell_volumes[i])
+			eps2 := math.Pow(k*h, 3)
+			v := ((d1*d1+eps2)*d2 + 2.0*d2*d2*d1) / (d1*d1 + 2.0*d2*d2 + d1*d2 + eps2) / d2
+			phi[i] = math.Min(phi[i], v)
+		}
+	}
+	for i, face := range grid.Faces_in_cel {
+		center := grid.In_geometry(i).Center
+		limit(face[1].Left, center)
+		limit(face[1].Right, center)
+	}
+	for i, face := range grid.Faces_bnd_cel {
+		limit(face[1].Left, grid.Bnd_geometry(i).Center)
+	}
+	for i := range limited {
+		p := math.Max(0.0, phi[i])
+		limited[i] = Point{p * grad[i].X, p * grad[i].Y, p * grad[i].Z}
+	}
+	return limited, nil
+}
+
+// green_gauss вычисляет градиент поля u в центрах ячеек по формуле Гаусса–Остроградского.
+// Значение на внутренней грани интерполируется линейно, на граничной берётся
+// из bnd (по граням Faces_bnd_cel) или, если bnd == nil, из прилежащей ячейки.
+func green_gauss(grid *VTKGrid, u []float64, bnd []float64) []Point {
+	in := make([]float64, len(grid.Faces_in_cel))
+	for i, face := range grid.Faces_in_cel {
+		w := grid.In_geometry(i).Weight
+		in[i] = w*u[face[1].Left] + (1.0-w)*u[face[1].Right]
+	}
+	return face_sum(grid, u, in, bnd)
+}
+
+// green_gauss_node вычисляет градиент по формуле Гаусса–Остроградского,
+// беря значение на грани как среднее значений в её узлах. Узловые значения
+// усредняются по прилежащим ячейкам с весами, обратными расстоянию;
+// в граничных узлах при bnd != nil — по прилежащим граничным граням.
+func green_gauss_node(grid *VTKGrid, u []float64, bnd []float64) []Point {
+	node := make([]float64, len(grid.Points))
+	weight := make([]float64, len(grid.Points))
+	for i, cell := range grid.Cells {
+		for _, n := range cell.Indices {
+			d := sub(grid.Points[n], grid.Cell_centers[i])
+			w := 1.0 / math.Sqrt(dot(d, d))
+			node[n] += w * u[i]
+			weight[n] += w
+		}
+	}
+	if bnd != nil {
+		// Значения в граничных узлах определяются только граничными данными
+		on_bnd := make([]bool, len(grid.Points))
+		for i := range grid.Faces_bnd_cel {
+			for _, n := range grid.Face_nodes[grid.Faces_bnd_id[i]] {
+				if !on_bnd[n] {
+					on_bnd[n] = true
+					node[n], weight[n] = 0.0, 0.0
+				}
+			}
+		}
+		for i := range grid.Faces_bnd_cel {
+			geom := grid.Bnd_geometry(i)
+			for _, n := range grid.Face_nodes[grid.Faces_bnd_id[i]] {
+				d := sub(grid.Points[n], geom.Center)
+				w := 1.0 / math.Max(math.Sqrt(dot(d, d)), 1e-300)
+				node[n] += w * bnd[i]
+				weight[n] += w
+			}
+		}
+	}
+	for n := range node {
+		if weight[n] > 0 {
+			node[n] /= weight[n]
+		}
+	}
+	face_value := func(id int) float64 {
+		sum := 0.0
+		for _, n := range grid.Face_nodes[id] {
+			sum += node[n]
+		}
+		return sum / float64(len(grid.Face_nodes[id]))
+	}
+	in := make([]float64, len(grid.Faces_in_cel))
+	for i, id := range grid.Faces_in_id {
+		in[i] = face_value(id)
+	}
+	fb := make([]float64, len(grid.Faces_bnd_cel))
+	for i, id := range grid.Faces_bnd_id {
+		fb[i] = face_value(id)
+	}
+	return face_sum(grid, u, in, fb)
+}
+
+// face_sum возвращает sum(u_f * S_f) / V по значениям на внутренних (in)
+// и граничных (bnd, nil — из ячейки) гранях
+func face_sum(grid *VTKGrid, u []float64, in []float64, bnd []float64) []Point {
+	grad := make([]Point, len(grid.Cells))
+	for i, face := range grid.Faces_in_cel {
+		left := face[1].Left
+		right := face[1].Right
+		geom := grid.In_geometry(i)
+		s := Point{in[i] * geom.Area * geom.Normal.X, in[i] * geom.Area * geom.Normal.Y, in[i] * geom.Area * geom.Normal.Z}
+		grad[left] = Point{grad[left].X + s.X, grad[left].Y + s.Y, grad[left].Z + s.Z}
+		grad[right] = Point{grad[right].X - s.X, grad[right].Y - s.Y, grad[right].Z - s.Z}
+	}
+	for i, face := range grid.Faces_bnd_cel {
+		left := face[1].Left
+		geom := grid.Bnd_geometry(i)
+		uf := u[left]
+		if bnd != nil {
+			uf = bnd[i]
+		}
+		grad[left] = Point{
+			grad[left].X + uf*geom.Area*geom.Normal.X,
+			grad[left].Y + uf*geom.Area*geom.Normal.Y,
+			grad[left].Z + uf*geom.Area*geom.Normal.Z,
+		}
+	}
+	for i := range grad {
+		v := grid.Cell_volumes[i]
+		grad[i] = Point{grad[i].X / v, grad[i].Y / v, grad[i].Z / v}
+	}
+	return grad
+}
+
+// least_squares вычисляет градиент взвешенным методом наименьших квадратов
+// по соседним ячейкам и, при bnd != nil, по центрам граничных граней
+func least_squares(grid *VTKGrid, u []float64, bnd []float64) []Point {
+	nn := len(grid.Cells)
+	M := make([][6]float64, nn) // xx, xy, xz, yy, yz, zz
+	b := make([]Point, nn)
+	add := func(i int, d Point, du float64) {
+		w := 1.0 / dot(d, d)
+		M[i][0] += w * d.X * d.X
+		M[i][1] += w * d.X * d.Y
+		M[i][2] += w * d.X * d.Z
+		M[i][3] += w * d.Y * d.Y
+		M[i][4] += w * d.Y * d.Z
+		M[i][5] += w * d.Z * d.Z
+		b[i] = Point{b[i].X + w*d.X*du, b[i].Y + w*d.Y*du, b[i].Z + w*d.Z*du}
+	}
+	for _, face := range grid.Faces_in_cel {
+		l, r := face[1].Left, face[1].Right
+		d := sub(grid.Cell_centers[r], grid.Cell_centers[l])
+		add(l, d, u[r]-u[l])
+		add(r, Point{-d.X, -d.Y, -d.Z}, u[l]-u[r])
+	}
+	if bnd != nil {
+		for i, face := range grid.Faces_bnd_cel {
+			l := face[1].Left
+			add(l, grid.Bnd_geometry(i).D_own, bnd[i]-u[l])
+		}
+	}
+	grad := make([]Point, nn)
+	for i := range grad {
+		m := M[i]
+		// На плоской сетке система вырождается по z и решается в плоскости XY
+		if m[5] <= 1e-12*(m[0]+m[3]) {
+			det := m[0]*m[3] - m[1]*m[1]
+			if det == 0 {
+				continue
+			}
+			grad[i] = Point{X: (m[3]*b[i].X - m[1]*b[i].Y) / det, Y: (m[0]*b[i].Y - m[1]*b[i].X) / det}
+			continue
+		}
+		a := [3][3]float64{{m[0], m[1], m[2]}, {m[1], m[3], m[4]}, {m[2], m[4], m[5]}}
+		det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) -
+			a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) +
+			a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+		if det == 0 {
+			continue
+		}
+		// Правило Крамера
+		col := func(k int) float64 {
+			c := a
+			c[0][k], c[1][k], c[2][k] = b[i].X, b[i].Y, b[i].Z
+			return (c[0][0]*(c[1][1]*c[2][2]-c[1][2]*c[2][1]) -
+				c[0][1]*(c[1][0]*c[2][2]-c[1][2]*c[2][0]) +
+				c[0][2]*(c[1][0]*c[2][1]-c[1][1]*c[2][0])) / det
+		}
+		grad[i] = Point{col(0), col(1), col(2)}
+	}
+	return grad
+}
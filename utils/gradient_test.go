@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// linearField returns u = 1 + 2x - 3y at cell centers and boundary face centers
+func linearField(grid *VTKGrid) ([]float64, []float64) {
+	f := func(p Point) float64 { return 1 + 2*p.X - 3*p.Y }
+	u := make([]float64, len(grid.Cells))
+	for i, c := range grid.Cell_centers {
+		u[i] = f(c)
+	}
+	bnd := make([]float64, len(grid.Faces_bnd_cel))
+	for i := range bnd {
+		bnd[i] = f(grid.Bnd_geometry(i).Center)
+	}
+	return u, bnd
+}
+
+func maxGradientError(grad []Point, skip func(i int) bool) float64 {
+	e := 0.0
+	for i, g := range grad {
+		if skip != nil && skip(i) {
+			continue
+		}
+		e = math.Max(e, math.Max(math.Abs(g.X-2), math.Abs(g.Y+3)))
+	}
+	return e
+}
+
+func TestGradientLinearExactness(t *testing.T) {
+	rect, err := Rect_grid(0, 0, 1, 1, 8, 8, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	tetra, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	tetra.Need_face_geometry()
+
+	u, bnd := linearField(&rect)
+	for _, method := range []int{GreenGauss, GreenGaussNode, LeastSquares} {
+		grad, err := rect.Gradient(u, bnd, method)
+		if err != nil {
+			t.Fatalf("Gradient failed: %v", err)
+		}
+		// Corner node values interpolated from boundary data are not exact,
+		// so the node-based variant is checked away from the corners
+		var skip func(i int) bool
+		if method == GreenGaussNode {
+			skip = func(i int) bool { return i == 0 || i == 7 || i == 56 || i == 63 }
+		}
+		if e := maxGradientError(grad, skip); e > 1e-10 {
+			t.Errorf("Method %d on uniform grid: max gradient error %e", method, e)
+		}
+	}
+
+	u, bnd = linearField(&tetra)
+	grad, err := tetra.Gradient(u, bnd, LeastSquares)
+	if err != nil {
+		t.Fatalf("Gradient failed: %v", err)
+	}
+	if e := maxGradientError(grad, nil); e > 1e-10 {
+		t.Errorf("Least squares on unstructured grid: max gradient error %e", e)
+	}
+}
+
+func TestLimitGradientBounds(t *testing.T) {
+	grid, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	grid.Need_face_geometry()
+	// A discontinuous field: limited reconstruction must not create new extrema
+	u := make([]float64, len(grid.Cells))
+	for i, c := range grid.Cell_centers {
+		if c.X+0.5*c.Y > 0.6 {
+			u[i] = 1.0
+		}
+	}
+	grad, err := grid.Gradient(u, nil, LeastSquares)
+	if err != nil {
+		t.Fatalf("Gradient failed: %v", err)
+	}
+	for _, limiter := range []int{BarthJespersen, Venkatakrishnan} {
+		limited, err := grid.Limit_gradient(u, nil, grad, limiter, 1.0)
+		if err != nil {
+			t.Fatalf("Limit_gradient failed: %v", err)
+		}
+		over := 0.0
+		for i, face := range grid.Faces_in_cel {
+			x := grid.In_geometry(i).Center
+			for _, c := range []int{face[1].Left, face[1].Right} {
+				v := u[c] + dot(limited[c], sub(x, grid.Cell_centers[c]))
+				over = math.Max(over, math.Max(v-1.0, -v))
+			}
+		}
+		if limiter == BarthJespersen && over > 1e-12 {
+			t.Errorf("Barth-Jespersen reconstruction overshoots by %e", over)
+		}
+		if limiter == Venkatakrishnan && over > 0.05 {
+			t.Errorf("Venkatakrishnan reconstruction overshoots by %e", over)
+		}
+	}
+}
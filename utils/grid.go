@@ -1,223 +1,330 @@
-package utils
-
-import (
-	"bufio"
-	"fmt"
-	"os"
-	"strconv"
-	"strings"
-)
-
-// Point представляет точку в 3D пространстве
-type Point struct {
-	X, Y, Z float64
-}
-
-// Cell представляет ячейку, состоящую из индексов точек
-type Cell struct {
-	Indices []int
-}
-
-// Face представляет грань, по разные стороны от которых расположены ячейки с номерами left и right
-type Face struct {
-	Left, Right int
-}
-
-// VTKGrid представляет сетку в формате VTK
-type VTKGrid struct {
-	Title         string
-	Format        string
-	DatasetType   string
-	Points        []Point
-	Cells         []Cell
-	CellTypes     []int // Добавлено поле для типов ячеек
-	Faces         []Face
-	Faces_bnd_cel [][2]Face
-	Faces_in_cel  [][2]Face
-	Cell_centers  []Point
-	Cell_volumes  []float64
-}
-
-// Grid загружает VTK-файл
-func Grid(filename string) (VTKGrid, error) {
-	// Открываем файл
-	file, err := os.Open(filename)
-	if err != nil {
-		return VTKGrid{}, fmt.Errorf("ошибка при открытии файла: %v", err)
-	}
-	defer file.Close()
-
-	// Создаем экземпляр VTKGrid
-	grid := VTKGrid{}
-
-	// Читаем файл построчно
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Заголовок
-		if strings.HasPrefix(line, "# vtk DataFile Version") {
-			grid.Title = strings.TrimSpace(strings.TrimPrefix(line, "# vtk DataFile Version"))
-		}
-
-		// Формат
-		if line == "ASCII" {
-			grid.Format = "ASCII"
-		}
-
-		// Тип данных
-		if strings.HasPrefix(line, "DATASET") {
-			grid.DatasetType = strings.TrimSpace(strings.TrimPrefix(line, "DATASET"))
-		}
-
-		// Точки
-		if strings.HasPrefix(line, "POINTS") {
-			parts := strings.Fields(line)
-			numPoints, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return VTKGrid{}, fmt.Errorf("ошибка при парсинге количества точек: %v", err)
-			}
-			grid.Points = make([]Point, numPoints)
-
-			for i := 0; i < numPoints; i++ {
-				scanner.Scan()
-				coords := strings.Fields(scanner.Text())
-				if len(coords) < 3 {
-					return VTKGrid{}, fmt.Errorf("недостаточно координат для точки")
-				}
-				x, err := strconv.ParseFloat(coords[0], 64)
-				if err != nil {
-					return VTKGrid{}, fmt.Errorf("ошибка при парсинге координаты X: %v", err)
-				}
-				y, err := strconv.ParseFloat(coords[1], 64)
-				if err != nil {
-					return VTKGrid{}, fmt.Errorf("ошибка при парсинге координаты Y: %v", err)
-				}
-				z, err := strconv.ParseFloat(coords[2], 64)
-				if err != nil {
-					return VTKGrid{}, fmt.Errorf("ошибка при парсинге координаты Z: %v", err)
-				}
-				grid.Points[i] = Point{X: x, Y: y, Z: z}
-			}
-		}
-
-		// Ячейки
-		if strings.HasPrefix(line, "CELLS") {
-			parts := strings.Fields(line)
-			numCells, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return VTKGrid{}, fmt.Errorf("ошибка при парсинге количества ячеек: %v", err)
-			}
-			grid.Cells = make([]Cell, numCells)
-
-			for i := 0; i < numCells; i++ {
-				scanner.Scan()
-				cellData := strings.Fields(scanner.Text())
-				if len(cellData) == 0 {
-					return VTKGrid{}, fmt.Errorf("пустая строка для ячейки")
-				}
-				numIndices, err := strconv.Atoi(cellData[0])
-				if err != nil {
-					return VTKGrid{}, fmt.Errorf("ошибка при парсинге количества индексов: %v", err)
-				}
-				indices := make([]int, numIndices)
-				for j := 0; j < numIndices; j++ {
-					indices[j], err = strconv.Atoi(cellData[j+1])
-					if err != nil {
-						return VTKGrid{}, fmt.Errorf("ошибка при парсинге индекса: %v", err)
-					}
-				}
-				grid.Cells[i] = Cell{Indices: indices}
-			}
-		}
-
-		// Типы ячеек
-		if strings.HasPrefix(line, "CELL_TYPES") {
-			parts := strings.Fields(line)
-			numCellTypes, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return VTKGrid{}, fmt.Errorf("ошибка при парсинге количества типов ячеек: %v", err)
-			}
-			grid.CellTypes = make([]int, numCellTypes)
-
-			for i := 0; i < numCellTypes; i++ {
-				scanner.Scan()
-				cellType, err := strconv.Atoi(scanner.Text())
-				if err != nil {
-					return VTKGrid{}, fmt.Errorf("ошибка при парсинге типа ячейки: %v", err)
-				}
-				grid.CellTypes[i] = cellType
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return VTKGrid{}, fmt.Errorf("ошибка при чтении файла: %v", err)
-	}
-
-	// === Добавляем грани ===
-
-	// Карта для хранения граней и связанных с ними ячеек
-	faceMap := make(map[[2]int][]int)
-	// Перебираем все ячейки и их рёбра
-	for cellIndex, cell := range grid.Cells {
-		numIndices := len(cell.Indices)
-
-		for i := 0; i < numIndices; i++ {
-			// Грань = два соседних индекса (петляем обратно на первый)
-			a, b := cell.Indices[i], cell.Indices[(i+1)%numIndices]
-
-			// Сортируем индексы, чтобы избежать дублирования (a,b) и (b,a)
-			if a > b {
-				a, b = b, a
-			}
-			key := [2]int{a, b}
-			faceMap[key] = append(faceMap[key], cellIndex)
-		}
-	}
-	// Формируем список граней
-	for points, cells := range faceMap {
-		if len(cells) > 2 {
-			return VTKGrid{}, fmt.Errorf("грань принадлежит более чем двум ячейкам: %v", cells)
-		}
-		left := cells[0]
-		right := -1
-		if len(cells) > 1 {
-			right = cells[1] // Вторая ячейка (если есть)
-			grid.Faces_in_cel = append(grid.Faces_in_cel, [2]Face{Face{Left: points[0], Right: points[1]}, Face{Left: left, Right: right}})
-		} else {
-			grid.Faces_bnd_cel = append(grid.Faces_bnd_cel, [2]Face{Face{Left: points[0], Right: points[1]}, Face{Left: left, Right: right}})
-		}
-		grid.Faces = append(grid.Faces, Face{Left: left, Right: right})
-	}
-
-	return grid, nil
-}
-
-func (grid *VTKGrid) Need_cell_centers() {
-	grid.Cell_volumes = make([]float64, len(grid.Cells))
-	grid.Cell_centers = make([]Point, len(grid.Cells))
-	for i := 0; i < len(grid.Cells); i++ {
-		sum := 0.0
-		sum0 := 0.0
-		center := Point{0.0, 0.0, 0.0}
-		cel := grid.Cells[i]
-		numIndices := len(grid.Cells[i].Indices)
-		for j := 0; j < numIndices-1; j++ {
-			x0 := grid.Points[cel.Indices[0]]
-			xi := grid.Points[cel.Indices[j]]
-			xip := grid.Points[cel.Indices[j+1]]
-			sum = ((xi.X-x0.X)*(xip.Y-x0.Y) - (xi.Y-x0.Y)*(xip.X-x0.X)) / 2.0
-			sum0 += sum
-			center.X += (x0.X + xi.X + xip.X) * sum / 3.0
-			center.Y += (x0.Y + xi.Y + xip.Y) * sum / 3.0
-			center.Z += (x0.Z + xi.Z + xip.Z) * sum / 3.0
-		}
-		grid.Cell_volumes[i] = sum0
-		grid.Cell_centers[i] = Point{
-			X: center.X / sum0,
-			Y: center.Y / sum0,
-			Z: center.Z / sum0,
-		}
-	}
-}
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Point представляет точку в 3D пространстве
+type Point struct {
+	X, Y, Z float64
+}
+
+// Cell представляет ячейку, состоящую из индексов точек
+type Cell struct {
+	Indices []int
+}
+
+// Face представляет грань, по разные стороны от которых расположены ячейки с номерами left и right
+type Face struct {
+	Left, Right int
+}
+
+// VTKGrid представляет сетку в формате VTK
+type VTKGrid struct {
+	Title         string
+	Format        string
+	DatasetType   string
+	Points        []Point
+	Cells         []Cell
+	CellTypes     []int   // Добавлено поле для типов ячеек
+	Dim           int     // размерность сетки: 2 (многоугольники) или 3 (объёмные ячейки)
+	Faces         []Face  // ячейки по обе стороны грани в порядке глобальных номеров
+	Face_nodes    [][]int // узлы грани в порядке глобальных номеров
+	Faces_bnd_cel [][2]Face
+	Faces_in_cel  [][2]Face
+	Faces_bnd_id  []int // глобальные номера граней из Faces_bnd_cel
+	Faces_in_id   []int // глобальные номера граней из Faces_in_cel
+	Cell_centers  []Point
+	Cell_volumes  []float64
+	Face_geometry []FaceGeometry // геометрия граней по глобальным номерам
+	Bnd_patches   []BndPatch     // именованные участки границы
+	Cell_zones    []CellZone     // именованные группы ячеек (материалы, подобласти)
+	Fields        []Field        // поля в ячейках и узлах, прочитанные из файла
+}
+
+// CellZone — именованная группа ячеек
+type CellZone struct {
+	Name  string
+	Cells []int // номера ячеек
+}
+
+// Cell_field возвращает поле в ячейках по имени или nil, если такого нет
+func (grid *VTKGrid) Cell_field(name string) *Field {
+	return grid.find_field(name, false)
+}
+
+// Point_field возвращает поле в узлах по имени или nil, если такого нет
+func (grid *VTKGrid) Point_field(name string) *Field {
+	return grid.find_field(name, true)
+}
+
+func (grid *VTKGrid) find_field(name string, point bool) *Field {
+	for i := range grid.Fields {
+		if grid.Fields[i].Name == name && grid.Fields[i].Point == point {
+			return &grid.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Zone возвращает зону ячеек по имени или nil, если такой нет
+func (grid *VTKGrid) Zone(name string) *CellZone {
+	for i := range grid.Cell_zones {
+		if grid.Cell_zones[i].Name == name {
+			return &grid.Cell_zones[i]
+		}
+	}
+	return nil
+}
+
+// Grid загружает сетку из legacy VTK-файла в формате ASCII или BINARY,
+// из файла VTK XML с расширением .vtu или из сетки Gmsh с расширением .msh
+func Grid(filename string) (VTKGrid, error) {
+	// Открываем файл
+	file, err := os.Open(filename)
+	if err != nil {
+		return VTKGrid{}, fmt.Errorf("ошибка при открытии файла: %v", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".vtu":
+		return Read_vtu(file)
+	case ".msh":
+		return Read_gmsh(file)
+	}
+	return Read_legacy(file)
+}
+
+// build_faces строит списки граней по ячейкам сетки. Грани нумеруются
+// в порядке первого появления при обходе ячеек и их граней, поэтому нумерация
+// не зависит от запуска: грань i принадлежит ячейке-владельцу Faces[i].Left
+// с наименьшим номером, а узлы Face_nodes[i] идут в порядке обхода владельца.
+// Для объёмной сетки в Faces_in_cel и Faces_bnd_cel записываются первые два
+// узла грани, полный список узлов — в Face_nodes.
+func (grid *VTKGrid) build_faces() error {
+	if err := grid.check_cells(); err != nil {
+		return err
+	}
+	grid.Faces = nil
+	grid.Face_nodes = nil
+	grid.Faces_in_cel = nil
+	grid.Faces_bnd_cel = nil
+	grid.Faces_in_id = nil
+	grid.Faces_bnd_id = nil
+
+	// Номер грани по отсортированному набору её узлов
+	faceMap := make(map[[4]int]int)
+	for cellIndex := range grid.Cells {
+		for _, nodes := range grid.local_faces(cellIndex) {
+			// Сортируем индексы, чтобы одна грань соседних ячеек давала один ключ
+			key := face_key(nodes)
+			id, ok := faceMap[key]
+			if !ok {
+				faceMap[key] = len(grid.Faces)
+				grid.Faces = append(grid.Faces, Face{Left: cellIndex, Right: -1})
+				grid.Face_nodes = append(grid.Face_nodes, nodes)
+				continue
+			}
+			if grid.Faces[id].Right != -1 {
+				return fmt.Errorf("грань принадлежит более чем двум ячейкам: %v",
+					[]int{grid.Faces[id].Left, grid.Faces[id].Right, cellIndex})
+			}
+			grid.Faces[id].Right = cellIndex
+		}
+	}
+	// Формируем списки внутренних и граничных граней в порядке номеров
+	for id, face := range grid.Faces {
+		nodes := Face{Left: grid.Face_nodes[id][0], Right: grid.Face_nodes[id][1]}
+		if face.Right != -1 {
+			grid.Faces_in_cel = append(grid.Faces_in_cel, [2]Face{nodes, face})
+			grid.Faces_in_id = append(grid.Faces_in_id, id)
+		} else {
+			grid.Faces_bnd_cel = append(grid.Faces_bnd_cel, [2]Face{nodes, face})
+			grid.Faces_bnd_id = append(grid.Faces_bnd_id, id)
+		}
+	}
+
+	return nil
+}
+
+// Write_face_table записывает таблицу граней: номер, ячейки по обе стороны
+// (-1 для граничной) и узлы. Таблица однозначно определяется сеткой
+// и может сохраняться вместе с результатами для сравнения.
+func (grid *VTKGrid) Write_face_table(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "FACES %d\n", len(grid.Faces))
+	for id, face := range grid.Faces {
+		fmt.Fprintf(bw, "%d %d %d %d", id, face.Left, face.Right, len(grid.Face_nodes[id]))
+		for _, n := range grid.Face_nodes[id] {
+			fmt.Fprintf(bw, " %d", n)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// Rect_grid строит сетку из nx*ny прямоугольных ячеек в прямоугольнике
+// [x0, x1] x [y0, y1]. Если keep не nil, остаются только ячейки, центры
+// которых ему удовлетворяют (так можно получить, например, канал со ступенькой).
+func Rect_grid(x0, y0, x1, y1 float64, nx, ny int, keep func(c Point) bool) (VTKGrid, error) {
+	if nx <= 0 || ny <= 0 {
+		return VTKGrid{}, fmt.Errorf("неверное число ячеек: %d x %d", nx, ny)
+	}
+	grid := VTKGrid{
+		Title:       "3.0",
+		Format:      "ASCII",
+		DatasetType: "UNSTRUCTURED_GRID",
+	}
+	hx := (x1 - x0) / float64(nx)
+	hy := (y1 - y0) / float64(ny)
+	for j := 0; j <= ny; j++ {
+		for i := 0; i <= nx; i++ {
+			grid.Points = append(grid.Points, Point{X: x0 + float64(i)*hx, Y: y0 + float64(j)*hy})
+		}
+	}
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			center := Point{X: x0 + (float64(i)+0.5)*hx, Y: y0 + (float64(j)+0.5)*hy}
+			if keep != nil && !keep(center) {
+				continue
+			}
+			k := j*(nx+1) + i
+			grid.Cells = append(grid.Cells, Cell{Indices: []int{k, k + 1, k + nx + 2, k + nx + 1}})
+			grid.CellTypes = append(grid.CellTypes, VTKQuad)
+		}
+	}
+	if len(grid.Cells) == 0 {
+		return VTKGrid{}, fmt.Errorf("сетка не содержит ячеек")
+	}
+	if err := grid.build_faces(); err != nil {
+		return VTKGrid{}, err
+	}
+	grid.Need_face_geometry()
+	return grid, nil
+}
+
+func (grid *VTKGrid) Need_cell_centers() {
+	grid.Cell_volumes = make([]float64, len(grid.Cells))
+	grid.Cell_centers = make([]Point, len(grid.Cells))
+	for i := 0; i < len(grid.Cells); i++ {
+		if _, ok := cell_faces[grid.cell_type(i)]; ok {
+			grid.Cell_volumes[i], grid.Cell_centers[i] = grid.polyhedron_volume(i)
+			continue
+		}
+		sum := 0.0
+		sum0 := 0.0
+		center := Point{0.0, 0.0, 0.0}
+		cel := grid.Cells[i]
+		numIndices := len(grid.Cells[i].Indices)
+		for j := 0; j < numIndices-1; j++ {
+			x0 := grid.Points[cel.Indices[0]]
+			xi := grid.Points[cel.Indices[j]]
+			xip := grid.Points[cel.Indices[j+1]]
+			sum = ((xi.X-x0.X)*(xip.Y-x0.Y) - (xi.Y-x0.Y)*(xip.X-x0.X)) / 2.0
+			sum0 += sum
+			center.X += (x0.X + xi.X + xip.X) * sum / 3.0
+			center.Y += (x0.Y + xi.Y + xip.Y) * sum / 3.0
+			center.Z += (x0.Z + xi.Z + xip.Z) * sum / 3.0
+		}
+		grid.Cell_volumes[i] = math.Abs(sum0)
+		grid.Cell_centers[i] = Point{
+			X: center.X / sum0,
+			Y: center.Y / sum0,
+			Z: center.Z / sum0,
+		}
+	}
+}
+
+// Locate возвращает номер ячейки, содержащей точку p (для плоской сетки —
+// в плоскости XY), или -1. Объёмные ячейки считаются выпуклыми.
+func (grid *VTKGrid) Locate(p Point) int {
+	if grid.Dim == 3 {
+		return grid.locate_3d(p)
+	}
+	for i, cel := range grid.Cells {
+		inside := false
+		n := len(cel.Indices)
+		for j := 0; j < n; j++ {
+			a := grid.Points[cel.Indices[j]]
+			b := grid.Points[cel.Indices[(j+1)%n]]
+			if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+				inside = !inside
+			}
+		}
+		if inside {
+			return i
+		}
+	}
+	return -1
+}
+
+func (grid *VTKGrid) locate_3d(p Point) int {
+	if len(grid.Cell_centers) != len(grid.Cells) {
+		grid.Need_cell_centers()
+	}
+	for i := range grid.Cells {
+		inside := true
+		for _, nodes := range grid.local_faces(i) {
+			S, fc := grid.polygon_area(nodes)
+			// Нормаль грани ориентируется от центра ячейки
+			if dot(S, sub(fc, grid.Cell_centers[i])) < 0 {
+				S = Point{-S.X, -S.Y, -S.Z}
+			}
+			if dot(S, sub(p, fc)) > 1e-12*dot(S, S) {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			return i
+		}
+	}
+	return -1
+}
+
+// Box_grid строит сетку из nx*ny*nz шестигранников в параллелепипеде [lo, hi]
+func Box_grid(lo Point, hi Point, nx, ny, nz int) (VTKGrid, error) {
+	if nx <= 0 || ny <= 0 || nz <= 0 {
+		return VTKGrid{}, fmt.Errorf("неверное число ячеек: %d x %d x %d", nx, ny, nz)
+	}
+	grid := VTKGrid{
+		Title:       "3.0",
+		Format:      "ASCII",
+		DatasetType: "UNSTRUCTURED_GRID",
+	}
+	hx := (hi.X - lo.X) / float64(nx)
+	hy := (hi.Y - lo.Y) / float64(ny)
+	hz := (hi.Z - lo.Z) / float64(nz)
+	for k := 0; k <= nz; k++ {
+		for j := 0; j <= ny; j++ {
+			for i := 0; i <= nx; i++ {
+				grid.Points = append(grid.Points,
+					Point{X: lo.X + float64(i)*hx, Y: lo.Y + float64(j)*hy, Z: lo.Z + float64(k)*hz})
+			}
+		}
+	}
+	node := func(i, j, k int) int { return (k*(ny+1)+j)*(nx+1) + i }
+	for k := 0; k < nz; k++ {
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				grid.Cells = append(grid.Cells, Cell{Indices: []int{
+					node(i, j, k), node(i+1, j, k), node(i+1, j+1, k), node(i, j+1, k),
+					node(i, j, k+1), node(i+1, j, k+1), node(i+1, j+1, k+1), node(i, j+1, k+1),
+				}})
+				grid.CellTypes = append(grid.CellTypes, VTKHexahedron)
+			}
+		}
+	}
+	if err := grid.build_faces(); err != nil {
+		return VTKGrid{}, err
+	}
+	grid.Need_face_geometry()
+	return grid, nil
+}
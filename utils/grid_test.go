@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestFaceOrderingDeterministic(t *testing.T) {
+	var tables [3][]byte
+	for k := range tables {
+		grid, err := Grid("../test_data/tetragrid_2000.vtk")
+		if err != nil {
+			t.Fatalf("Grid failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := grid.Write_face_table(&buf); err != nil {
+			t.Fatalf("Write_face_table failed: %v", err)
+		}
+		tables[k] = buf.Bytes()
+	}
+	for k := 1; k < len(tables); k++ {
+		if !bytes.Equal(tables[0], tables[k]) {
+			t.Errorf("Face table differs between loads 0 and %d", k)
+		}
+	}
+}
+
+func TestFaceNumbering(t *testing.T) {
+	grid, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	if len(grid.Faces_in_cel)+len(grid.Faces_bnd_cel) != len(grid.Faces) {
+		t.Fatalf("Interior and boundary faces do not add up: %d + %d != %d",
+			len(grid.Faces_in_cel), len(grid.Faces_bnd_cel), len(grid.Faces))
+	}
+	for id := 1; id < len(grid.Faces); id++ {
+		if grid.Faces[id].Left < grid.Faces[id-1].Left {
+			t.Fatalf("Faces are not ordered by owner cell at %d", id)
+		}
+	}
+	for i, id := range grid.Faces_in_id {
+		face := grid.Faces[id]
+		if face != grid.Faces_in_cel[i][1] || face.Left >= face.Right {
+			t.Errorf("Interior face %d (global %d) has cells %v", i, id, face)
+		}
+	}
+	for i, id := range grid.Faces_bnd_id {
+		if grid.Faces[id] != grid.Faces_bnd_cel[i][1] || grid.Faces[id].Right != -1 {
+			t.Errorf("Boundary face %d (global %d) has cells %v", i, id, grid.Faces[id])
+		}
+	}
+}
+
+func TestFaceTableRectGrid(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 2, 1, 2, 1, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := grid.Write_face_table(&buf); err != nil {
+		t.Fatalf("Write_face_table failed: %v", err)
+	}
+	// Points: 0 1 2 on y = 0, 3 4 5 on y = 1; cells (0 1 4 3) and (1 2 5 4)
+	expected := "FACES 7\n" +
+		"0 0 -1 2 0 1\n" +
+		"1 0 1 2 1 4\n" +
+		"2 0 -1 2 4 3\n" +
+		"3 0 -1 2 3 0\n" +
+		"4 1 -1 2 1 2\n" +
+		"5 1 -1 2 2 5\n" +
+		"6 1 -1 2 5 4\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected face table:\n%s", buf.String())
+	}
+}
+
+func TestFaceGeometry(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 2, 1, 4, 2, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	for i, face := range grid.Faces_in_cel {
+		geom := grid.In_geometry(i)
+		d := sub(grid.Cell_centers[face[1].Right], grid.Cell_centers[face[1].Left])
+		if dot(d, geom.Normal) <= 0 {
+			t.Errorf("Interior face %d normal %v does not point from owner to neighbour", i, geom.Normal)
+		}
+		if math.Abs(geom.Area-0.5) > 1e-12 || math.Abs(geom.Delta-0.5) > 1e-12 || math.Abs(geom.Weight-0.5) > 1e-12 {
+			t.Errorf("Interior face %d: area %g, delta %g, weight %g", i, geom.Area, geom.Delta, geom.Weight)
+		}
+	}
+	for i := range grid.Faces_bnd_cel {
+		geom := grid.Bnd_geometry(i)
+		if dot(geom.D_own, geom.Normal) <= 0 {
+			t.Errorf("Boundary face %d normal %v does not point outward", i, geom.Normal)
+		}
+		if math.Abs(geom.Delta-0.25) > 1e-12 {
+			t.Errorf("Boundary face %d: delta %g", i, geom.Delta)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+
+	"test.com/mat/matrix"
+	"test.com/solvers"
+	_ "test.com/solvers/amgcl" // регистрирует решатель "amgcl" при сборке с тегом amgcl
+)
+
+// linear_config хранит выбор решателя СЛАУ; встраивается в Solver и FlowSolver
+type linear_config struct {
+	linear      string          // имя решателя в реестре solvers
+	linear_opts solvers.Options // число итераций и точность
+}
+
+// Set_linear_solver выбирает решатель СЛАУ по имени из реестра solvers
+// ("cg", "bicgstab", "amg", "amgcl", ...) и задаёт его параметры; нулевые
+// параметры оставляют значения по умолчанию решателя. По умолчанию
+// используется AMGCL (с BiCGSTAB для несимметричных систем), если программа
+// собрана с тегом amgcl, иначе BiCGSTAB с предобусловливателем AMG на чистом Go.
+func (lc *linear_config) Set_linear_solver(name string, opts solvers.Options) error {
+	for _, known := range solvers.Names() {
+		if known == name {
+			lc.linear, lc.linear_opts = name, opts
+			return nil
+		}
+	}
+	return fmt.Errorf("неизвестный решатель СЛАУ %q", name)
+}
+
+// linear_solver создаёт выбранный решатель СЛАУ для матрицы A. Для
+// несимметричной матрицы (конвекция, уравнения импульса) AMGCL по умолчанию
+// использует BiCGSTAB вместо CG.
+func (lc *linear_config) linear_solver(A *matrix.CSRMatrix, symmetric bool) (solvers.LinearSolver, error) {
+	name, opts := lc.linear, lc.linear_opts
+	if name == "" {
+		name = "bicgstab"
+		if opts.Preconditioner == "" {
+			opts.Preconditioner = "amg"
+		}
+		for _, known := range solvers.Names() {
+			if known == "amgcl" {
+				name = known
+			}
+		}
+	}
+	if name == "amgcl" && !symmetric && opts.Params["solver.type"] == "" {
+		params := map[string]string{}
+		for k, v := range opts.Params {
+			params[k] = v
+		}
+		params["solver.type"] = "bicgstab"
+		opts.Params = params
+	}
+	slv, err := solvers.New(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := slv.Setup(A); err != nil {
+		slv.Free()
+		return nil, err
+	}
+	return slv, nil
+}
+
+// solve_linear решает систему с правой частью b и возвращает ошибку,
+// если решатель не сошёлся
+func solve_linear(slv solvers.LinearSolver, b []float64) ([]float64, error) {
+	res, err := slv.SolveFrom(b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Converged {
+		return nil, fmt.Errorf("решатель СЛАУ не сошёлся за %d итераций, невязка %g",
+			res.Iterations, res.Residuals[len(res.Residuals)-1])
+	}
+	return res.X, nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"test.com/solvers"
+)
+
+func TestSetLinearSolver(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 8, 8, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	names := []string{"amg", "cg", "bicgstab", "gmres"}
+	solutions := make(map[string][]float64)
+	for _, name := range names {
+		var solver Solver
+		solver.Set_grid(grid)
+		if err := solver.Set_linear_solver(name, solvers.Options{Tolerance: 1e-12}); err != nil {
+			t.Fatalf("Set_linear_solver(%s) failed: %v", name, err)
+		}
+		solver.Set_bnd_type(Dirichlet)
+		if err := solver.Approximate_parts(); err != nil {
+			t.Fatalf("%s: Approximate_parts failed: %v", name, err)
+		}
+		solutions[name] = solver.Solution()
+		if len(solutions[name]) != len(grid.Cells) {
+			t.Fatalf("%s: got %d values for %d cells", name, len(solutions[name]), len(grid.Cells))
+		}
+	}
+	for _, name := range names[1:] {
+		for i, u := range solutions[name] {
+			if math.Abs(u-solutions["amg"][i]) > 1e-8 {
+				t.Fatalf("Cell %d: %s gives %g, amg %g", i, name, u, solutions["amg"][i])
+			}
+		}
+	}
+	var solver Solver
+	if err := solver.Set_linear_solver("no-such-solver", solvers.Options{}); err == nil {
+		t.Error("Expected an error for an unknown linear solver")
+	}
+}
@@ -0,0 +1,505 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+// Типы границ для течения несжимаемой жидкости
+const (
+	Inlet  = iota + 1 // заданная скорость
+	Outlet            // заданное давление, нулевая производная скорости
+	Wall              // прилипание; стенка может двигаться с заданной скоростью
+)
+
+// Алгоритмы связи скорости и давления
+const (
+	SIMPLE = iota
+	SIMPLEC
+)
+
+// FlowBC — граничное условие для течения на участке границы
+type FlowBC struct {
+	Type     int
+	Velocity VectorFunc // скорость на входе или скорость движения стенки; nil — нулевая
+	Pressure float64    // давление на выходе
+}
+
+// Flow — параметры расчёта течения несжимаемой жидкости
+//
+//	rho div(u u) - mu lap(u) = -grad p,  div(u) = 0
+type Flow struct {
+	Density   float64 // rho; 0 означает 1
+	Viscosity float64 // mu
+	Algorithm int     // SIMPLE или SIMPLEC
+	Alpha_u   float64 // релаксация скорости; 0 означает 0.7
+	Alpha_p   float64 // релаксация давления; 0 означает 0.3 для SIMPLE и 1 для SIMPLEC
+	Max_iter  int     // 0 означает 1000
+	Tolerance float64 // по относительной невязке уравнения неразрывности; 0 означает 1e-6
+}
+
+// flow_face — геометрия грани для расчёта течения
+type flow_face struct {
+	left, right int
+	normal      Point   // от left к right, для граничной грани — наружу
+	center      Point   // середина грани
+	area        float64 // длина грани
+	dist        float64 // расстояние между центрами ячеек (до грани для граничной) по нормали
+	weight      float64 // доля ячейки left при линейной интерполяции на грань
+}
+
+// build_flow_faces выбирает геометрию внутренних и граничных граней
+func build_flow_faces(g *VTKGrid) ([]flow_face, []flow_face) {
+	in := make([]flow_face, len(g.Faces_in_cel))
+	for i, face := range g.Faces_in_cel {
+		geom := g.In_geometry(i)
+		in[i] = flow_face{
+			left:   face[1].Left,
+			right:  face[1].Right,
+			normal: geom.Normal,
+			center: geom.Center,
+			area:   geom.Area,
+			dist:   geom.Delta,
+			weight: geom.Weight,
+		}
+	}
+	bnd := make([]flow_face, len(g.Faces_bnd_cel))
+	for i, face := range g.Faces_bnd_cel {
+		geom := g.Bnd_geometry(i)
+		bnd[i] = flow_face{
+			left:   face[1].Left,
+			right:  -1,
+			normal: geom.Normal,
+			center: geom.Center,
+			area:   geom.Area,
+			dist:   geom.Delta,
+			weight: 1.0,
+		}
+	}
+	return in, bnd
+}
+
+// FlowSolver решает стационарные уравнения Навье–Стокса для несжимаемой жидкости
+// методом конечных объёмов на совмещённой сетке (SIMPLE/SIMPLEC с интерполяцией Рхи–Чоу)
+type FlowSolver struct {
+	linear_config
+
+	grid VTKGrid
+	flow Flow
+	bcs  map[string]FlowBC
+
+	in     []flow_face
+	bnd    []flow_face
+	bnd_bc []FlowBC
+
+	u, v, p   []float64
+	d         []float64 // коэффициент в поправке скорости
+	drc       []float64 // V/a_P, коэффициент интерполяции Рхи–Чоу
+	flux_in   []float64 // массовые потоки через внутренние грани
+	flux_bnd  []float64 // массовые потоки через граничные грани
+	Residuals []float64 // история невязки уравнения неразрывности
+}
+
+// Set_grid задаёт сетку и вычисляет геометрию граней
+func (fs *FlowSolver) Set_grid(grid VTKGrid) {
+	fs.grid = grid
+	fs.grid.Need_cell_centers()
+	fs.grid.Need_face_geometry()
+	g := &fs.grid
+	fs.in, fs.bnd = build_flow_faces(g)
+	nn := len(g.Cells)
+	fs.u = make([]float64, nn)
+	fs.v = make([]float64, nn)
+	fs.p = make([]float64, nn)
+	fs.d = make([]float64, nn)
+	fs.drc = make([]float64, nn)
+	fs.flux_in = make([]float64, len(fs.in))
+	fs.flux_bnd = make([]float64, len(fs.bnd))
+	fs.Residuals = nil
+}
+
+// Set_flow задаёт физические параметры и параметры алгоритма
+func (fs *FlowSolver) Set_flow(flow Flow) error {
+	if flow.Viscosity <= 0 {
+		return fmt.Errorf("вязкость должна быть положительной")
+	}
+	if flow.Algorithm != SIMPLE && flow.Algorithm != SIMPLEC {
+		return fmt.Errorf("неизвестный алгоритм: %d", flow.Algorithm)
+	}
+	if flow.Density == 0 {
+		flow.Density = 1.0
+	}
+	if flow.Alpha_u == 0 {
+		flow.Alpha_u = 0.7
+	}
+	if flow.Alpha_p == 0 {
+		flow.Alpha_p = 0.3
+		if flow.Algorithm == SIMPLEC {
+			flow.Alpha_p = 1.0
+		}
+	}
+	if flow.Max_iter == 0 {
+		flow.Max_iter = 1000
+	}
+	if flow.Tolerance == 0 {
+		flow.Tolerance = 1e-6
+	}
+	fs.flow = flow
+	return nil
+}
+
+// Set_bc задаёт условие для течения на участке границы patch
+func (fs *FlowSolver) Set_bc(patch string, bc FlowBC) error {
+	if bc.Type < Inlet || bc.Type > Wall {
+		return fmt.Errorf("this type is not exist")
+	}
+	if fs.bcs == nil {
+		fs.bcs = make(map[string]FlowBC)
+	}
+	fs.bcs[patch] = bc
+	return nil
+}
+
+// Velocity возвращает компоненты скорости в центрах ячеек
+func (fs *FlowSolver) Velocity() ([]float64, []float64) {
+	return fs.u, fs.v
+}
+
+// Pressure возвращает давление в центрах ячеек
+func (fs *FlowSolver) Pressure() []float64 {
+	return fs.p
+}
+
+// Probe возвращает скорость и давление в точке p, восстановленные линейно
+// по значению и градиенту в содержащей её ячейке
+func (fs *FlowSolver) Probe(p Point) (Point, float64, error) {
+	cell := fs.grid.Locate(p)
+	if cell < 0 {
+		return Point{}, 0, fmt.Errorf("точка %v вне сетки", p)
+	}
+	c := fs.grid.Cell_centers[cell]
+	dx := Point{p.X - c.X, p.Y - c.Y, p.Z - c.Z}
+	value := func(f []float64, bnd []float64) float64 {
+		g := green_gauss(&fs.grid, f, bnd)[cell]
+		return f[cell] + g.X*dx.X + g.Y*dx.Y + g.Z*dx.Z
+	}
+	ub, vb := fs.velocity_bnd()
+	vel := Point{X: value(fs.u, ub), Y: value(fs.v, vb)}
+	return vel, value(fs.p, fs.pressure_bnd(fs.p)), nil
+}
+
+// resolve_bcs сопоставляет граничным граням условия; все грани должны быть покрыты
+func (fs *FlowSolver) resolve_bcs() error {
+	fs.bnd_bc = make([]FlowBC, len(fs.bnd))
+	for name, bc := range fs.bcs {
+		patch := fs.grid.Patch(name)
+		if patch == nil {
+			return fmt.Errorf("участок границы %q не найден", name)
+		}
+		for _, f := range patch.Faces {
+			fs.bnd_bc[f] = bc
+		}
+	}
+	for i, bc := range fs.bnd_bc {
+		if bc.Type == 0 {
+			return fmt.Errorf("для граничной грани %d не задано условие", i)
+		}
+	}
+	return nil
+}
+
+// bnd_velocity возвращает скорость, заданную на граничной грани i
+func (fs *FlowSolver) bnd_velocity(i int) Point {
+	bc := fs.bnd_bc[i]
+	if bc.Velocity == nil {
+		return Point{}
+	}
+	return bc.Velocity(fs.bnd[i].center)
+}
+
+// velocity_bnd возвращает компоненты скорости на граничных гранях
+func (fs *FlowSolver) velocity_bnd() ([]float64, []float64) {
+	ub := make([]float64, len(fs.bnd))
+	vb := make([]float64, len(fs.bnd))
+	for i, f := range fs.bnd {
+		if fs.bnd_bc[i].Type == Outlet {
+			ub[i], vb[i] = fs.u[f.left], fs.v[f.left]
+		} else {
+			vel := fs.bnd_velocity(i)
+			ub[i], vb[i] = vel.X, vel.Y
+		}
+	}
+	return ub, vb
+}
+
+// pressure_bnd возвращает давление (или поправку давления) на граничных гранях
+func (fs *FlowSolver) pressure_bnd(p []float64) []float64 {
+	pb := make([]float64, len(fs.bnd))
+	for i, f := range fs.bnd {
+		if fs.bnd_bc[i].Type == Outlet {
+			pb[i] = fs.bnd_bc[i].Pressure
+		} else {
+			pb[i] = p[f.left]
+		}
+	}
+	return pb
+}
+
+// momentum собирает уравнения для компонент скорости с учётом релаксации
+func (fs *FlowSolver) momentum(gp []Point) (*matrix.DOKMatrix, []float64, []float64, error) {
+	nn := len(fs.grid.Cells)
+	mu := fs.flow.Viscosity
+	alpha := fs.flow.Alpha_u
+	lhs, err := matrix.NewDOKMatrix(nn, nn)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bu := make([]float64, nn)
+	bv := make([]float64, nn)
+	for i, f := range fs.in {
+		D := mu * f.area / f.dist
+		F := fs.flux_in[i]
+		l, r := f.left, f.right
+		lhs.Set(l, l, lhs.Get(l, l)+D+math.Max(F, 0))
+		lhs.Set(r, r, lhs.Get(r, r)+D-math.Min(F, 0))
+		lhs.Set(l, r, lhs.Get(l, r)-D+math.Min(F, 0))
+		lhs.Set(r, l, lhs.Get(r, l)-D-math.Max(F, 0))
+	}
+	for i, f := range fs.bnd {
+		F := fs.flux_bnd[i]
+		l := f.left
+		if fs.bnd_bc[i].Type == Outlet {
+			lhs.Set(l, l, lhs.Get(l, l)+math.Max(F, 0))
+			continue
+		}
+		D := mu * f.area / f.dist
+		vel := fs.bnd_velocity(i)
+		lhs.Set(l, l, lhs.Get(l, l)+D)
+		bu[l] += (D - F) * vel.X
+		bv[l] += (D - F) * vel.Y
+	}
+	for i := 0; i < nn; i++ {
+		vol := math.Abs(fs.grid.Cell_volumes[i])
+		bu[i] -= gp[i].X * vol
+		bv[i] -= gp[i].Y * vol
+		// Неявная релаксация
+		aP := lhs.Get(i, i) / alpha
+		lhs.Set(i, i, aP)
+		bu[i] += (1.0 - alpha) * aP * fs.u[i]
+		bv[i] += (1.0 - alpha) * aP * fs.v[i]
+	}
+	return lhs, bu, bv, nil
+}
+
+// update_d вычисляет коэффициенты поправки скорости V/a_P (SIMPLE)
+// или V/(a_P - sum|a_nb|) (SIMPLEC) и коэффициенты V/a_P интерполяции Рхи–Чоу
+func (fs *FlowSolver) update_d(A *matrix.CSRMatrix) {
+	for i := 0; i < A.Rows; i++ {
+		aP, anb := 0.0, 0.0
+		for k := A.RowPtr[i]; k < A.RowPtr[i+1]; k++ {
+			if A.ColIndices[k] == i {
+				aP = A.Values[k]
+			} else {
+				anb += math.Abs(A.Values[k])
+			}
+		}
+		vol := math.Abs(fs.grid.Cell_volumes[i])
+		fs.drc[i] = vol / aP
+		if fs.flow.Algorithm == SIMPLEC && aP-anb > 1e-12*aP {
+			aP -= anb
+		}
+		fs.d[i] = vol / aP
+	}
+}
+
+// update_flux вычисляет массовые потоки через грани с интерполяцией Рхи–Чоу.
+// Если заданы скорости u0, v0 предыдущей итерации, добавляется поправка
+// Маджумдара (1 - alpha)(F_old - rho S u0_f·n), без которой сошедшееся
+// решение зависит от релаксации скорости Alpha_u.
+func (fs *FlowSolver) update_flux(gp []Point, u0, v0 []float64) {
+	rho := fs.flow.Density
+	relax := 0.0
+	if u0 != nil {
+		relax = 1.0 - fs.flow.Alpha_u
+	}
+	for i, f := range fs.in {
+		l, r := f.left, f.right
+		w := f.weight
+		un := (w*fs.u[l]+(1-w)*fs.u[r])*f.normal.X + (w*fs.v[l]+(1-w)*fs.v[r])*f.normal.Y
+		df := w*fs.drc[l] + (1-w)*fs.drc[r]
+		gpf := (w*gp[l].X+(1-w)*gp[r].X)*f.normal.X + (w*gp[l].Y+(1-w)*gp[r].Y)*f.normal.Y
+		flux := rho * f.area * (un - df*((fs.p[r]-fs.p[l])/f.dist-gpf))
+		if relax != 0 {
+			un0 := (w*u0[l]+(1-w)*u0[r])*f.normal.X + (w*v0[l]+(1-w)*v0[r])*f.normal.Y
+			flux += relax * (fs.flux_in[i] - rho*f.area*un0)
+		}
+		fs.flux_in[i] = flux
+	}
+	for i, f := range fs.bnd {
+		bc := fs.bnd_bc[i]
+		l := f.left
+		if bc.Type == Outlet {
+			un := fs.u[l]*f.normal.X + fs.v[l]*f.normal.Y
+			gpn := gp[l].X*f.normal.X + gp[l].Y*f.normal.Y
+			flux := rho * f.area * (un - fs.drc[l]*((bc.Pressure-fs.p[l])/f.dist-gpn))
+			if relax != 0 {
+				un0 := u0[l]*f.normal.X + v0[l]*f.normal.Y
+				flux += relax * (fs.flux_bnd[i] - rho*f.area*un0)
+			}
+			fs.flux_bnd[i] = flux
+		} else {
+			vel := fs.bnd_velocity(i)
+			fs.flux_bnd[i] = rho * f.area * (vel.X*f.normal.X + vel.Y*f.normal.Y)
+		}
+	}
+}
+
+// mass_imbalance возвращает дисбаланс массы в каждой ячейке (суммарный вытекающий поток)
+func (fs *FlowSolver) mass_imbalance() []float64 {
+	m := make([]float64, len(fs.grid.Cells))
+	for i, f := range fs.in {
+		m[f.left] += fs.flux_in[i]
+		m[f.right] -= fs.flux_in[i]
+	}
+	for i, f := range fs.bnd {
+		m[f.left] += fs.flux_bnd[i]
+	}
+	return m
+}
+
+// pressure_correction решает уравнение для поправки давления
+func (fs *FlowSolver) pressure_correction(imbalance []float64) ([]float64, error) {
+	nn := len(fs.grid.Cells)
+	rho := fs.flow.Density
+	lhs, err := matrix.NewDOKMatrix(nn, nn)
+	if err != nil {
+		return nil, err
+	}
+	rhs := make([]float64, nn)
+	for i := range rhs {
+		rhs[i] = -imbalance[i]
+	}
+	for _, f := range fs.in {
+		l, r := f.left, f.right
+		c := rho * f.area * (f.weight*fs.d[l] + (1-f.weight)*fs.d[r]) / f.dist
+		lhs.Set(l, l, lhs.Get(l, l)+c)
+		lhs.Set(r, r, lhs.Get(r, r)+c)
+		lhs.Set(l, r, lhs.Get(l, r)-c)
+		lhs.Set(r, l, lhs.Get(r, l)-c)
+	}
+	singular := true
+	for i, f := range fs.bnd {
+		if fs.bnd_bc[i].Type == Outlet {
+			c := rho * f.area * fs.d[f.left] / f.dist
+			lhs.Set(f.left, f.left, lhs.Get(f.left, f.left)+c)
+			singular = false
+		}
+	}
+	if singular {
+		// Давление определено с точностью до константы
+		set_unit_row(lhs, 0)
+		rhs[0] = 0.0
+	}
+	csr, err := lhs.ToCSR()
+	if err != nil {
+		return nil, err
+	}
+	slv, err := fs.linear_solver(csr, true)
+	if err != nil {
+		return nil, err
+	}
+	defer slv.Free()
+	return solve_linear(slv, rhs)
+}
+
+// correct вносит поправку давления в давление, скорости и потоки
+func (fs *FlowSolver) correct(pc []float64) {
+	rho := fs.flow.Density
+	pcb := make([]float64, len(fs.bnd))
+	for i, f := range fs.bnd {
+		if fs.bnd_bc[i].Type != Outlet {
+			pcb[i] = pc[f.left]
+		}
+	}
+	gpc := green_gauss(&fs.grid, pc, pcb)
+	for i := range fs.u {
+		fs.u[i] -= fs.d[i] * gpc[i].X
+		fs.v[i] -= fs.d[i] * gpc[i].Y
+		fs.p[i] += fs.flow.Alpha_p * pc[i]
+	}
+	for i, f := range fs.in {
+		l, r := f.left, f.right
+		c := rho * f.area * (f.weight*fs.d[l] + (1-f.weight)*fs.d[r]) / f.dist
+		fs.flux_in[i] -= c * (pc[r] - pc[l])
+	}
+	for i, f := range fs.bnd {
+		if fs.bnd_bc[i].Type == Outlet {
+			fs.flux_bnd[i] += rho * f.area * fs.d[f.left] / f.dist * pc[f.left]
+		}
+	}
+}
+
+// Solve выполняет итерации SIMPLE/SIMPLEC до сходимости и возвращает их число
+func (fs *FlowSolver) Solve() (int, error) {
+	if fs.flow.Viscosity == 0 {
+		return 0, fmt.Errorf("параметры течения не заданы")
+	}
+	if err := fs.resolve_bcs(); err != nil {
+		return 0, err
+	}
+	gp := green_gauss(&fs.grid, fs.p, fs.pressure_bnd(fs.p))
+	fs.update_flux(gp, nil, nil)
+	ref := 0.0
+	for iter := 1; iter <= fs.flow.Max_iter; iter++ {
+		gp = green_gauss(&fs.grid, fs.p, fs.pressure_bnd(fs.p))
+		lhs, bu, bv, err := fs.momentum(gp)
+		if err != nil {
+			return iter, err
+		}
+		A, err := lhs.ToCSR()
+		if err != nil {
+			return iter, err
+		}
+		slv, err := fs.linear_solver(A, false)
+		if err != nil {
+			return iter, err
+		}
+		u0, v0 := fs.u, fs.v
+		u, err := solve_linear(slv, bu)
+		if err == nil {
+			fs.v, err = solve_linear(slv, bv)
+		}
+		slv.Free()
+		if err != nil {
+			return iter, err
+		}
+		fs.u = u
+		fs.update_d(A)
+		fs.update_flux(gp, u0, v0)
+
+		imbalance := fs.mass_imbalance()
+		res := 0.0
+		for _, m := range imbalance {
+			res += math.Abs(m)
+		}
+		if ref == 0 {
+			ref = res
+		}
+		if ref > 0 {
+			res /= ref
+		}
+		fs.Residuals = append(fs.Residuals, res)
+
+		pc, err := fs.pressure_correction(imbalance)
+		if err != nil {
+			return iter, err
+		}
+		fs.correct(pc)
+		if res < fs.flow.Tolerance {
+			return iter, nil
+		}
+	}
+	return fs.flow.Max_iter, fmt.Errorf("SIMPLE не сошёлся за %d итераций", fs.flow.Max_iter)
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// cavity_solver prepares the lid-driven cavity at Re = 100
+func cavity_solver(t *testing.T, grid VTKGrid, max_iter int) *FlowSolver {
+	t.Helper()
+	if err := grid.Add_bnd_patch("lid", func(p Point) bool { return p.Y > 1-1e-6 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := grid.Add_bnd_patch("walls", func(p Point) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	fs := &FlowSolver{}
+	fs.Set_grid(grid)
+	if err := fs.Set_flow(Flow{Viscosity: 0.01, Max_iter: max_iter, Algorithm: SIMPLEC}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set_bc("lid", FlowBC{Type: Wall, Velocity: func(p Point) Point { return Point{X: 1.0} }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set_bc("walls", FlowBC{Type: Wall}); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// The first SIMPLE iterations start from a zero field, where the right-hand
+// sides of the v momentum and the pressure correction equations can be zero
+func TestCavityFirstIterations(t *testing.T) {
+	grid, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	grid.Need_cell_centers()
+	fs := cavity_solver(t, grid, 5)
+	iters, err := fs.Solve()
+	if iters != 5 || len(fs.Residuals) != 5 {
+		t.Fatalf("Stopped after %d iterations: %v", iters, err)
+	}
+	u, v := fs.Velocity()
+	for i := range u {
+		if math.IsNaN(u[i]) || math.IsNaN(v[i]) || math.IsNaN(fs.Pressure()[i]) {
+			t.Fatalf("NaN in cell %d", i)
+		}
+	}
+	if fs.Residuals[4] >= fs.Residuals[0] {
+		t.Errorf("Continuity residual did not decrease: %v", fs.Residuals)
+	}
+}
+
+func TestPoiseuilleChannel(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 2, 1, 32, 16, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	patches := []struct {
+		name string
+		sel  func(p Point) bool
+	}{
+		{"inlet", func(p Point) bool { return p.X < 1e-6 }},
+		{"outlet", func(p Point) bool { return p.X > 2-1e-6 }},
+		{"walls", func(p Point) bool { return true }},
+	}
+	for _, patch := range patches {
+		if err := grid.Add_bnd_patch(patch.name, patch.sel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Mean velocity 1 in a channel of height 1: u = 6y(1-y), dp/dx = -12 mu
+	exact := func(y float64) float64 { return 6 * y * (1 - y) }
+	mu := 0.01
+	for _, algorithm := range []int{SIMPLE, SIMPLEC} {
+		var fs FlowSolver
+		fs.Set_grid(grid)
+		if err := fs.Set_flow(Flow{Viscosity: mu, Algorithm: algorithm, Max_iter: 2000}); err != nil {
+			t.Fatal(err)
+		}
+		bcs := map[string]FlowBC{
+			"inlet":  {Type: Inlet, Velocity: func(p Point) Point { return Point{X: exact(p.Y)} }},
+			"outlet": {Type: Outlet},
+			"walls":  {Type: Wall},
+		}
+		for name, bc := range bcs {
+			if err := fs.Set_bc(name, bc); err != nil {
+				t.Fatal(err)
+			}
+		}
+		iters, err := fs.Solve()
+		if err != nil {
+			t.Fatalf("Algorithm %d: Solve failed after %d iterations: %v", algorithm, iters, err)
+		}
+		maxDiff := 0.0
+		for y := 0.05; y < 1; y += 0.1 {
+			vel, _, err := fs.Probe(Point{X: 1.5, Y: y})
+			if err != nil {
+				t.Fatalf("Probe failed: %v", err)
+			}
+			maxDiff = math.Max(maxDiff, math.Abs(vel.X-exact(y)))
+			if math.Abs(vel.Y) > 1e-3 {
+				t.Errorf("Algorithm %d: v = %g at y = %g", algorithm, vel.Y, y)
+			}
+		}
+		_, p1, _ := fs.Probe(Point{X: 1.0, Y: 0.5})
+		_, p2, _ := fs.Probe(Point{X: 1.5, Y: 0.5})
+		dpdx := (p2 - p1) / 0.5
+		t.Logf("Algorithm %d: %d iterations, max |u - u_exact| = %.3e, dp/dx = %.5f", algorithm, iters, maxDiff, dpdx)
+		if maxDiff > 0.02 {
+			t.Errorf("Algorithm %d: max |u - u_exact| = %.3e", algorithm, maxDiff)
+		}
+		if math.Abs(dpdx+12*mu) > 0.05*12*mu {
+			t.Errorf("Algorithm %d: dp/dx = %.5f, expected %.5f", algorithm, dpdx, -12*mu)
+		}
+	}
+}
+
+// u(0.5, y) at Re = 100 from Ghia, Ghia and Shin (1982)
+var ghia_y = []float64{0.9766, 0.9531, 0.8516, 0.7344, 0.6172, 0.5, 0.4531, 0.2813, 0.1719, 0.1016, 0.0625}
+var ghia_u = []float64{0.84123, 0.68717, 0.23151, 0.00332, -0.13641, -0.20581, -0.2109, -0.15662, -0.1015, -0.06434, -0.04192}
+
+func TestCavityGhia(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 32, 32, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	fs := cavity_solver(t, grid, 3000)
+	iters, err := fs.Solve()
+	if err != nil {
+		t.Fatalf("Solve failed after %d iterations: %v", iters, err)
+	}
+	maxDiff := 0.0
+	for i, y := range ghia_y {
+		vel, _, err := fs.Probe(Point{X: 0.5, Y: y})
+		if err != nil {
+			t.Fatalf("Probe failed: %v", err)
+		}
+		maxDiff = math.Max(maxDiff, math.Abs(vel.X-ghia_u[i]))
+	}
+	t.Logf("%d iterations, max |u - u_Ghia| = %.3e", iters, maxDiff)
+	if maxDiff > 0.04 {
+		t.Errorf("max |u - u_Ghia| = %.3e", maxDiff)
+	}
+}
+
+// The converged solution must not depend on the velocity under-relaxation
+func TestFlowRelaxationIndependent(t *testing.T) {
+	for _, algorithm := range []int{SIMPLE, SIMPLEC} {
+		var u [][]float64
+		for _, alpha := range []float64{0.5, 0.8} {
+			grid, err := Rect_grid(0, 0, 1, 1, 16, 16, nil)
+			if err != nil {
+				t.Fatalf("Rect_grid failed: %v", err)
+			}
+			fs := cavity_solver(t, grid, 0)
+			flow := Flow{Viscosity: 0.01, Algorithm: algorithm, Alpha_u: alpha, Max_iter: 3000, Tolerance: 1e-9}
+			if err := fs.Set_flow(flow); err != nil {
+				t.Fatal(err)
+			}
+			if iters, err := fs.Solve(); err != nil {
+				t.Fatalf("Algorithm %d, alpha %g: Solve failed after %d iterations: %v", algorithm, alpha, iters, err)
+			}
+			vel, _ := fs.Velocity()
+			u = append(u, vel)
+		}
+		maxDiff := 0.0
+		for i := range u[0] {
+			maxDiff = math.Max(maxDiff, math.Abs(u[0][i]-u[1][i]))
+		}
+		if maxDiff > 1e-6 {
+			t.Errorf("Algorithm %d: max |u(0.5) - u(0.8)| = %.3e", algorithm, maxDiff)
+		}
+	}
+}
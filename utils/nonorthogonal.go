@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+)
+
+// Способы учёта неортогональности сетки в диффузионном потоке. Вектор площади
+// грани S раскладывается на E, параллельный отрезку d между центрами ячеек,
+// и остаток T = S - E; часть по E аппроксимируется двухточечно (неявно),
+// а поток по T — по градиентам в ячейках отложенной коррекцией.
+const (
+	NoCorrection         = iota // только двухточечный поток A/h, как на ортогональной сетке
+	MinimumCorrection           // E = (d·S/|d|^2) d
+	OrthogonalCorrection        // E = (|S|/|d|) d
+	OverRelaxed                 // E = (|S|^2/(d·S)) d
+)
+
+// Set_nonorthogonal задаёт способ учёта неортогональности сетки. Поправка
+// вносится итерациями отложенной коррекции, не более iters (0 — по умолчанию 20).
+func (solver *Solver) Set_nonorthogonal(method int, iters int) error {
+	if method < NoCorrection || method > OverRelaxed {
+		return fmt.Errorf("неизвестный способ учёта неортогональности: %d", method)
+	}
+	solver.nonorth = method
+	solver.nonorth_iters = iters
+	return nil
+}
+
+// decompose возвращает |E|/|d| — коэффициент двухточечного потока —
+// и вектор T для грани с геометрией geom
+func decompose(method int, geom *FaceGeometry) (float64, Point) {
+	if method == NoCorrection {
+		return geom.Area / geom.Delta, Point{}
+	}
+	// Для граничной грани D_nei нулевой, и d идёт от центра ячейки к центру грани
+	d := Point{geom.D_own.X + geom.D_nei.X, geom.D_own.Y + geom.D_nei.Y, geom.D_own.Z + geom.D_nei.Z}
+	S := Point{geom.Area * geom.Normal.X, geom.Area * geom.Normal.Y, geom.Area * geom.Normal.Z}
+	dd := dot(d, d)
+	dS := dot(d, S)
+	var coef float64
+	switch method {
+	case MinimumCorrection:
+		coef = dS / dd
+	case OrthogonalCorrection:
+		coef = geom.Area / math.Sqrt(dd)
+	default:
+		coef = geom.Area * geom.Area / dS
+	}
+	return coef, Point{S.X - coef*d.X, S.Y - coef*d.Y, S.Z - coef*d.Z}
+}
+
+// nonorth_correction добавляет в правую часть поток k*grad(u)·T через грани,
+// вычисленный по полю u в момент t
+func (solver *Solver) nonorth_correction(rhs []float64, u []float64, t float64) error {
+	if solver.nonorth == NoCorrection {
+		return nil
+	}
+	k, err := solver.cell_conductivity()
+	if err != nil {
+		return err
+	}
+	bcs, err := solver.face_bcs()
+	if err != nil {
+		return err
+	}
+	grid := &solver.grid
+	grad, err := grid.Gradient(u, solver.bnd_values(u, bcs, t), solver.gradient_method())
+	if err != nil {
+		return err
+	}
+	for i, face := range grid.Faces_in_cel {
+		left, right := face[1].Left, face[1].Right
+		geom := grid.In_geometry(i)
+		_, T := decompose(solver.nonorth, geom)
+		w := geom.Weight
+		gf := Point{
+			w*grad[left].X + (1.0-w)*grad[right].X,
+			w*grad[left].Y + (1.0-w)*grad[right].Y,
+			w*grad[left].Z + (1.0-w)*grad[right].Z,
+		}
+		dl, dr := geom.owner_distances()
+		c := face_conductivity(k[left], k[right], dl, dr) * dot(gf, T)
+		rhs[left] += c
+		rhs[right] -= c
+	}
+	for i, face := range grid.Faces_bnd_cel {
+		left := face[1].Left
+		geom := grid.Bnd_geometry(i)
+		coef, T := decompose(solver.nonorth, geom)
+		c := k[left] * dot(grad[left], T)
+		switch bcs[i].Type {
+		case Dirichlet:
+			rhs[left] += c
+		case Robin:
+			// Поправка входит в исключённое значение на грани (см. assemble_matrix)
+			a := bcs[i].Alpha(geom.Center)
+			rhs[left] += a * geom.Area * c / (k[left]*coef + a*geom.Area)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// skewedGrid builds an n x n quadrilateral grid by a smooth non-orthogonal
+// mapping of the unit square
+func skewedGrid(t *testing.T, n int) VTKGrid {
+	grid, err := Rect_grid(0, 0, 1, 1, n, n, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	for i, p := range grid.Points {
+		grid.Points[i] = Point{X: p.X + 0.3*p.Y, Y: p.Y + 0.05*math.Sin(2*math.Pi*p.X)}
+	}
+	if err := grid.Add_bnd_patch("all", func(p Point) bool { return true }); err != nil {
+		t.Fatalf("Add_bnd_patch failed: %v", err)
+	}
+	return grid
+}
+
+func solveSkewed(t *testing.T, n int, method int) (float64, float64) {
+	exact := func(p Point) float64 { return math.Sin(math.Pi*p.X) * math.Cos(math.Pi*p.Y) }
+	problem := Problem{
+		Source: func(p Point) float64 { return 2 * math.Pi * math.Pi * exact(p) },
+		Exact:  exact,
+	}
+	var solver Solver
+	solver.Set_grid(skewedGrid(t, n))
+	if err := solver.Set_problem(problem); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	if err := solver.Set_bc("all", DirichletBC(exact)); err != nil {
+		t.Fatalf("Set_bc failed: %v", err)
+	}
+	if err := solver.Set_nonorthogonal(method, 50); err != nil {
+		t.Fatalf("Set_nonorthogonal failed: %v", err)
+	}
+	if err := solver.Approximate_parts(); err != nil {
+		t.Fatalf("Approximate_parts failed: %v", err)
+	}
+	norms, err := solver.Errors(exact)
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	return solver.grid.Mesh_size(), norms.L2
+}
+
+func TestNonorthogonalCorrectionOrder(t *testing.T) {
+	for _, method := range []int{NoCorrection, MinimumCorrection, OrthogonalCorrection, OverRelaxed} {
+		h0, e0 := solveSkewed(t, 16, method)
+		h1, e1 := solveSkewed(t, 32, method)
+		h2, e2 := solveSkewed(t, 64, method)
+		p0 := observed_order(h0, e0, h1, e1)
+		p1 := observed_order(h1, e1, h2, e2)
+		t.Logf("method %d: L2 %.3e %.3e %.3e, order %.2f %.2f", method, e0, e1, e2, p0, p1)
+		if method != NoCorrection && p1 < 1.8 {
+			t.Errorf("Method %d: observed order %.2f, expected about 2", method, p1)
+		}
+	}
+}
+
+// The correction with the default gradient recovers second order on the
+// triangular meshes of test_data, where the two-point flux alone does not
+// converge
+func TestNonorthogonalTetragrid(t *testing.T) {
+	var h, e []float64
+	for _, name := range []string{"tetragrid_2000.vtk", "tetragrid_10000.vtk", "tetragrid_40k.vtk"} {
+		grid, err := Grid("../test_data/" + name)
+		if err != nil {
+			t.Fatalf("Grid failed: %v", err)
+		}
+		grid.Need_cell_centers()
+		var solver Solver
+		solver.Set_grid(grid)
+		if err := solver.Set_bnd_type(Dirichlet); err != nil {
+			t.Fatalf("Set_bnd_type failed: %v", err)
+		}
+		if err := solver.Set_nonorthogonal(OverRelaxed, 0); err != nil {
+			t.Fatalf("Set_nonorthogonal failed: %v", err)
+		}
+		if err := solver.Approximate_parts(); err != nil {
+			t.Fatalf("%s: Approximate_parts failed: %v", name, err)
+		}
+		norms, err := solver.Errors(exact_solution)
+		if err != nil {
+			t.Fatalf("Errors failed: %v", err)
+		}
+		h, e = append(h, grid.Mesh_size()), append(e, norms.L2)
+	}
+	for i := 1; i < len(e); i++ {
+		if p := observed_order(h[i-1], e[i-1], h[i], e[i]); p < 1.8 {
+			t.Errorf("L2 %.3e -> %.3e, observed order %.2f, expected about 2", e[i-1], e[i], p)
+		}
+	}
+}
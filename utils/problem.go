@@ -0,0 +1,100 @@
+package utils
+
+import "fmt"
+
+// Problem описывает стационарную задачу
+//
+//	div(v u) - div(k grad u) + c*u = f
+//
+// Граничные условия задаются отдельно через Solver.Set_bc.
+type Problem struct {
+	Source            ScalarFunc // f; nil означает f = 0
+	Source_t          TimeFunc   // f(x, t), имеет приоритет над Source
+	Conductivity      ScalarFunc // k(x), вычисляется в центрах ячеек; nil означает k = 1
+	Cell_conductivity []float64  // k по ячейкам, имеет приоритет над Conductivity
+	Reaction          ScalarFunc // c(x) >= 0, необязательный член
+	Exact             ScalarFunc // точное решение, если известно
+
+	Velocity VectorFunc // поле скорости v для конвективного члена, необязательное
+	Flux     *FaceFlux  // готовые потоки через грани, имеют приоритет над Velocity
+}
+
+// Manufactured_problem возвращает встроенную тестовую задачу с известным точным решением
+func Manufactured_problem() Problem {
+	return Problem{
+		Source: exact_rhs,
+		Exact:  exact_solution,
+	}
+}
+
+// Set_problem задаёт решаемую задачу вместо встроенной тестовой
+func (solver *Solver) Set_problem(problem Problem) error {
+	if problem.Cell_conductivity != nil && len(solver.grid.Cells) > 0 &&
+		len(problem.Cell_conductivity) != len(solver.grid.Cells) {
+		return fmt.Errorf("число коэффициентов %d не совпадает с числом ячеек %d",
+			len(problem.Cell_conductivity), len(solver.grid.Cells))
+	}
+	for i, k := range problem.Cell_conductivity {
+		if !(k > 0) {
+			return fmt.Errorf("коэффициент k должен быть положительным (ячейка %d)", i)
+		}
+	}
+	solver.problem = &problem
+	return nil
+}
+
+// source возвращает значение источника в точке p в момент t
+func (problem Problem) source(p Point, t float64) float64 {
+	if problem.Source_t != nil {
+		return problem.Source_t(p, t)
+	}
+	if problem.Source != nil {
+		return problem.Source(p)
+	}
+	return 0.0
+}
+
+// problem_def возвращает текущую задачу (по умолчанию — тестовую)
+func (solver *Solver) problem_def() Problem {
+	if solver.problem == nil {
+		return Manufactured_problem()
+	}
+	return *solver.problem
+}
+
+// cell_conductivity возвращает коэффициент k в каждой ячейке
+func (solver *Solver) cell_conductivity() ([]float64, error) {
+	problem := solver.problem_def()
+	nn := len(solver.grid.Cells)
+	k := problem.Cell_conductivity
+	if k != nil {
+		if len(k) != nn {
+			return nil, fmt.Errorf("число коэффициентов %d не совпадает с числом ячеек %d",
+				len(k), nn)
+		}
+	} else {
+		k = make([]float64, nn)
+		for i := 0; i < nn; i++ {
+			if problem.Conductivity != nil {
+				k[i] = problem.Conductivity(solver.grid.Cell_centers[i])
+			} else {
+				k[i] = 1.0
+			}
+		}
+	}
+	for i := range k {
+		if !(k[i] > 0) {
+			return nil, fmt.Errorf("коэффициент k должен быть положительным (ячейка %d)", i)
+		}
+	}
+	return k, nil
+}
+
+// face_conductivity возвращает коэффициент на внутренней грани как гармоническое
+// среднее с весами по расстояниям dl, dr от центров ячеек до грани
+func face_conductivity(kl, kr, dl, dr float64) float64 {
+	if kl == kr {
+		return kl
+	}
+	return kl * kr * (dl + dr) / (kl*dr + kr*dl)
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// solve_dirichlet solves problem on an n x n grid of the unit square with
+// u = exact on the boundary and returns the L2 error
+func solve_dirichlet(t *testing.T, n int, problem func(grid VTKGrid) Problem) float64 {
+	t.Helper()
+	grid, err := Rect_grid(0, 0, 1, 1, n, n, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("all", func(p Point) bool { return true }); err != nil {
+		t.Fatalf("Add_bnd_patch failed: %v", err)
+	}
+	var solver Solver
+	solver.Set_grid(grid)
+	prb := problem(grid)
+	if err := solver.Set_problem(prb); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	if err := solver.Set_bc("all", DirichletBC(prb.Exact)); err != nil {
+		t.Fatalf("Set_bc failed: %v", err)
+	}
+	if err := solver.Approximate_parts(); err != nil {
+		t.Fatalf("Approximate_parts failed: %v", err)
+	}
+	norms, err := solver.Errors(prb.Exact)
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	return norms.L2
+}
+
+func TestProblemTerms(t *testing.T) {
+	sin := func(p Point) float64 { return math.Sin(math.Pi*p.X) * math.Sin(math.Pi*p.Y) }
+	// u = x^2 + y^2 with k = 1 + x: -div(k grad u) = -(6x + 4)
+	quadratic := func(p Point) float64 { return p.X*p.X + p.Y*p.Y }
+	k := func(p Point) float64 { return 1 + p.X }
+	cases := []struct {
+		name    string
+		problem func(grid VTKGrid) Problem
+	}{
+		{"source", func(VTKGrid) Problem {
+			return Problem{Exact: sin, Source: func(p Point) float64 { return 2 * math.Pi * math.Pi * sin(p) }}
+		}},
+		{"conductivity", func(VTKGrid) Problem {
+			return Problem{Exact: quadratic, Conductivity: k,
+				Source: func(p Point) float64 { return -(6*p.X + 4) }}
+		}},
+		{"cell conductivity", func(grid VTKGrid) Problem {
+			cells := make([]float64, len(grid.Cells))
+			for i := range cells {
+				cells[i] = k(grid.Cell_centers[i])
+			}
+			return Problem{Exact: quadratic, Cell_conductivity: cells,
+				Source: func(p Point) float64 { return -(6*p.X + 4) }}
+		}},
+		{"reaction", func(VTKGrid) Problem {
+			return Problem{Exact: sin, Reaction: Const(10),
+				Source: func(p Point) float64 { return (2*math.Pi*math.Pi + 10) * sin(p) }}
+		}},
+	}
+	for _, c := range cases {
+		e0 := solve_dirichlet(t, 16, c.problem)
+		e1 := solve_dirichlet(t, 32, c.problem)
+		if order := math.Log2(e0 / e1); e1 > 2e-3 || order < 1.8 {
+			t.Errorf("%s: L2 error %.3e -> %.3e, order %.2f", c.name, e0, e1, order)
+		}
+	}
+}
+
+func TestProblemConductivityValidated(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 2, 2, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	var solver Solver
+	solver.Set_grid(grid)
+	for _, k := range [][]float64{{1, 1, 0, 1}, {1, -2, 1, 1}, {1, 1, 1, math.NaN()}, {1, 1}} {
+		if err := solver.Set_problem(Problem{Cell_conductivity: k}); err == nil {
+			t.Errorf("Expected an error for k = %v", k)
+		}
+	}
+	// A callback coefficient is checked when the system is assembled
+	if err := solver.Set_problem(Problem{Conductivity: func(p Point) float64 { return p.X - 0.5 }}); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	if err := solver.Approximate_parts(); err == nil {
+		t.Error("Expected an error for a negative k(x)")
+	}
+}
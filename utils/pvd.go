@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Series записывает результаты по моментам времени: каждый момент — отдельный
+// файл <Path без расширения>_<номер><Extension> рядом с индексом ParaView Path
+// (.pvd). Индекс перезаписывается целиком после каждого файла через временный
+// файл, поэтому прерванный расчёт оставляет корректную серию.
+type Series struct {
+	Path      string // путь к индексу .pvd
+	Extension string // расширение файлов: ".vtu" (по умолчанию) или ".vtk"
+	Format    string // формат файлов, см. VTKGrid.Write_file
+
+	times []float64 // моменты времени записанных файлов
+	files []string  // имена файлов относительно директории индекса
+}
+
+// Len возвращает число записанных моментов времени
+func (s *Series) Len() int {
+	return len(s.files)
+}
+
+// Write записывает сетку и поля в момент t как очередной файл серии
+// и обновляет индекс
+func (s *Series) Write(grid *VTKGrid, t float64, fields ...Field) error {
+	if s.Path == "" {
+		return fmt.Errorf("не задан путь к индексу серии")
+	}
+	if n := len(s.times); n > 0 && t <= s.times[n-1] {
+		return fmt.Errorf("момент времени %g не больше предыдущего %g", t, s.times[n-1])
+	}
+	ext := s.Extension
+	if ext == "" {
+		ext = ".vtu"
+	}
+	stem := strings.TrimSuffix(filepath.Base(s.Path), filepath.Ext(s.Path))
+	name := fmt.Sprintf("%s_%04d%s", stem, len(s.files), ext)
+	if err := grid.Write_file(filepath.Join(filepath.Dir(s.Path), name), s.Format, fields...); err != nil {
+		return err
+	}
+	s.times = append(s.times, t)
+	s.files = append(s.files, name)
+	return s.write_index()
+}
+
+// reload восстанавливает первые n записей из существующего индекса, чтобы
+// продолжить серию после перезапуска расчёта
+func (s *Series) reload(n int) error {
+	s.times, s.files = nil, nil
+	if n == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении индекса серии: %v", err)
+	}
+	var index struct {
+		DataSets []struct {
+			Timestep float64 `xml:"timestep,attr"`
+			File     string  `xml:"file,attr"`
+		} `xml:"Collection>DataSet"`
+	}
+	if err := xml.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("ошибка при разборе индекса серии: %v", err)
+	}
+	if len(index.DataSets) < n {
+		return fmt.Errorf("индекс серии содержит %d записей вместо %d", len(index.DataSets), n)
+	}
+	for _, ds := range index.DataSets[:n] {
+		s.times = append(s.times, ds.Timestep)
+		s.files = append(s.files, ds.File)
+	}
+	return nil
+}
+
+// write_index записывает индекс .pvd во временный файл и переименовывает его
+func (s *Series) write_index() error {
+	var b bytes.Buffer
+	b.WriteString("<?xml version=\"1.0\"?>\n<VTKFile type=\"Collection\" version=\"0.1\" byte_order=\"LittleEndian\">\n<Collection>\n")
+	for i, name := range s.files {
+		fmt.Fprintf(&b, "<DataSet timestep=\"%s\" group=\"\" part=\"0\" file=\"%s\"/>\n",
+			strconv.FormatFloat(s.times[i], 'g', -1, 64), xml_escape(name))
+	}
+	b.WriteString("</Collection>\n</VTKFile>\n")
+
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("ошибка при записи индекса серии: %v", err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("ошибка при записи индекса серии: %v", err)
+	}
+	return nil
+}
+
+// Write_series записывает текущее решение в момент Time() как очередной
+// файл серии; набор полей такой же, как у Write_to_file
+func (solver *Solver) Write_series(series *Series, fields ...Field) error {
+	all, err := solver.output_fields()
+	if err != nil {
+		return err
+	}
+	return series.Write(&solver.grid, solver.t, append(all, fields...)...)
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type pvdIndex struct {
+	DataSets []struct {
+		Timestep float64 `xml:"timestep,attr"`
+		File     string  `xml:"file,attr"`
+	} `xml:"Collection>DataSet"`
+}
+
+func readPvd(t *testing.T, path string) pvdIndex {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading %s failed: %v", path, err)
+	}
+	var index pvdIndex
+	if err := xml.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Parsing %s failed: %v", path, err)
+	}
+	return index
+}
+
+func TestSeriesIncremental(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 3, 3, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	dir := t.TempDir()
+	series := Series{Path: filepath.Join(dir, "run.pvd")}
+	u := Field{Name: "u", Values: make([]float64, len(grid.Cells))}
+	times := []float64{0, 0.1, 0.25}
+	for k, tm := range times {
+		if err := series.Write(&grid, tm, u); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		// The index is complete after every step
+		index := readPvd(t, series.Path)
+		if len(index.DataSets) != k+1 || index.DataSets[k].Timestep != tm {
+			t.Fatalf("After step %d the index holds %v", k, index.DataSets)
+		}
+		if _, err := Grid(filepath.Join(dir, index.DataSets[k].File)); err != nil {
+			t.Errorf("Step file %s is not readable: %v", index.DataSets[k].File, err)
+		}
+	}
+	if series.Len() != 3 {
+		t.Errorf("Len = %d, expected 3", series.Len())
+	}
+	if err := series.Write(&grid, 0.2, u); err == nil {
+		t.Error("Expected an error for a time stamp going backwards")
+	}
+	if _, err := os.Stat(series.Path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Temporary index file was left behind")
+	}
+}
+
+func TestTransientSeriesOutput(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 4, 4, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	var solver Solver
+	solver.Set_grid(grid)
+	if err := solver.Set_problem(Problem{Source: Const(1.0)}); err != nil {
+		t.Fatalf("Set_problem failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "heat.pvd")
+	tr := Transient{Scheme: BackwardEuler, Dt: 0.05, T_end: 0.2, Snapshot_every: 0.1, Output: path}
+	if err := solver.Solve_transient(tr); err != nil {
+		t.Fatalf("Solve_transient failed: %v", err)
+	}
+	index := readPvd(t, path)
+	want := []string{"heat_0000.vtu", "heat_0001.vtu", "heat_0002.vtu"}
+	if len(index.DataSets) != len(want) {
+		t.Fatalf("Index holds %d data sets, expected %d", len(index.DataSets), len(want))
+	}
+	for i, ds := range index.DataSets {
+		if ds.File != want[i] || ds.Timestep < 0.1*float64(i)-1e-12 || ds.Timestep > 0.1*float64(i)+1e-12 {
+			t.Errorf("Data set %d: %s at t = %g", i, ds.File, ds.Timestep)
+		}
+	}
+}
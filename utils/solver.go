@@ -1,148 +1,344 @@
-package utils
-
-import (
-	"fmt"
-	"math"
-
-	"test.com/mat/matrix"
-	"test.com/solvers/amgcl"
-)
-
-func exact_solution(p Point) float64 {
-	x := p.X
-	y := p.Y
-	return math.Cos(10.0*x*x)*math.Sin(10.0*y) + math.Sin(10.0*x*x)*math.Cos(10.0*x)
-}
-
-func exact_rhs(p Point) float64 {
-	x := p.X
-	y := p.Y
-	return (20.0*math.Sin(10.0*x*x)+(400.0*x*x+100.0)*math.Cos(10.0*x*x))*math.Sin(10.0*y) + (400.0*x*x+100.0)*math.Cos(10.0*x)*math.Sin(10.0*x*x) + (400.0*x*math.Sin(10*x)-20.0*math.Cos(10.0*x))*math.Cos(10.0*x*x)
-}
-
-func exact_dudx(p Point) float64 {
-	x := p.X
-	y := p.Y
-	return 20.0*x*math.Cos(10.0*x)*math.Cos(10.0*x*x) - 10.0*math.Sin(10.0*x*x)*(math.Sin(10.0*x)+2.0*x*math.Sin(10.0*y))
-}
-
-func exact_dudy(p Point) float64 {
-	x := p.X
-	y := p.Y
-	return 10.0 * math.Cos(10.0*x*x) * math.Cos(10.0*y)
-}
-
-func exact_dudn(p Point) float64 {
-	x := p.X
-	y := p.Y
-	if math.Abs(x) < 1e-6 {
-		return -exact_dudx(p)
-	} else if math.Abs(x-1.0) < 1e-6 {
-		return exact_dudx(p)
-	}
-	if math.Abs(y) < 1e-6 {
-		return -exact_dudy(p)
-	} else if math.Abs(y-1.0) < 1e-6 {
-		return exact_dudy(p)
-	}
-	return 0.0
-}
-
-type Solver struct {
-	x    []float64
-	grid VTKGrid
-	bnd  int
-}
-
-func (solver *Solver) Set_bnd_type(bnd int) error {
-	if bnd >= 1 && bnd <= 3 {
-		solver.bnd = bnd
-	} else {
-		return fmt.Errorf("this type is not exist")
-	}
-	return nil
-}
-
-func (solver *Solver) Set_grid(grid VTKGrid) {
-	solver.grid = grid
-	grid.Need_cell_centers()
-}
-
-func find_normal(pi Point, pj Point) Point {
-	x := pj.Y - pi.Y
-	y := -(pj.X - pi.X)
-	return Point{x / math.Sqrt(x*x+y*y), y / math.Sqrt(x*x+y*y), 0.0}
-}
-
-func set_unit_row(m *matrix.DOKMatrix, row int) {
-	cols := m.Cols
-	for i := 0; i < cols; i++ {
-		m.Set(row, i, 0.0)
-	}
-	m.Set(row, row, 1.0)
-}
-
-func (solver *Solver) Approximate_parts() {
-	nn := len(solver.grid.Cells)
-	// lhs := sparse.NewDOK(nn, nn)
-	lhs, _ := matrix.NewDOKMatrix(nn, nn)
-	rhs0 := make([]float64, nn)
-	// fmt.Println(solver.grid.Faces_in_cel)
-	for i := 0; i < len(solver.grid.Faces_in_cel); i++ {
-		face := solver.grid.Faces_in_cel[i]
-		left := face[1].Left
-		right := face[1].Right
-		pi := solver.grid.Points[face[0].Left]
-		pj := solver.grid.Points[face[0].Right]
-		normal := find_normal(pi, pj)
-		ci := solver.grid.Cell_centers[left]
-		cj := solver.grid.Cell_centers[right]
-		hij := math.Abs((cj.X-ci.X)*normal.X + (cj.Y-ci.Y)*normal.Y + (cj.Z-ci.Z)*normal.Z)
-		gij := math.Sqrt((pi.X-pj.X)*(pi.X-pj.X) + (pi.Y-pj.Y)*(pi.Y-pj.Y) + (pi.Z-pj.Z)*(pi.Z-pj.Z))
-		v := gij / hij
-
-		lhs.Set(left, left, lhs.Get(left, left)+v)
-		lhs.Set(right, right, lhs.Get(right, right)+v)
-		lhs.Set(left, right, lhs.Get(left, right)-v)
-		lhs.Set(right, left, lhs.Get(right, left)-v)
-
-	}
-	set_unit_row(lhs, 0)
-	for i := 0; i < len(solver.grid.Cells); i++ {
-		rhs0[i] = exact_rhs(solver.grid.Cell_centers[i]) * solver.grid.Cell_volumes[i]
-	}
-	for i := 0; i < len(solver.grid.Faces_bnd_cel); i++ {
-		face := solver.grid.Faces_bnd_cel[i]
-		left := face[1].Left
-		pi := solver.grid.Points[face[0].Left]
-		pj := solver.grid.Points[face[0].Right]
-		gij := math.Sqrt((pi.X-pj.X)*(pi.X-pj.X) + (pi.Y-pj.Y)*(pi.Y-pj.Y) + (pi.Z-pj.Z)*(pi.Z-pj.Z))
-		center := Point{X: pi.X/2.0 + pj.X/2.0, Y: pi.Y/2.0 + pj.Y/2.0, Z: pi.Z/2.0 + pj.Z/2.0}
-		rhs0[left] += gij * exact_dudn(center)
-	}
-	rhs0[0] = exact_solution(solver.grid.Cell_centers[0])
-
-	csr, _ := lhs.ToCSR()
-	slv, err := amgcl.NewSolver(csr)
-	if err != nil {
-		fmt.Println("error", err)
-	}
-	solver.x, err = slv.Solve(rhs0)
-	if err != nil {
-		fmt.Println("error", err)
-	}
-	slv.Free()
-}
-
-func (solver *Solver) Write_to_file(filename string) error {
-	var cell_data []float64
-	for i := 0; i < len(solver.grid.Cells); i++ {
-		cell_data = append(cell_data, solver.x[i])
-	}
-	writefile(filename, cell_data)
-	err := writefile(filename, cell_data)
-	if err != nil {
-		return err
-	}
-	return nil
-}
+package utils
+
+import (
+	"fmt"
+	"math"
+
+	"test.com/mat/matrix"
+)
+
+func exact_solution(p Point) float64 {
+	x := p.X
+	y := p.Y
+	return math.Cos(10.0*x*x)*math.Sin(10.0*y) + math.Sin(10.0*x*x)*math.Cos(10.0*x)
+}
+
+func exact_rhs(p Point) float64 {
+	x := p.X
+	y := p.Y
+	return (20.0*math.Sin(10.0*x*x)+(400.0*x*x+100.0)*math.Cos(10.0*x*x))*math.Sin(10.0*y) + (400.0*x*x+100.0)*math.Cos(10.0*x)*math.Sin(10.0*x*x) + (400.0*x*math.Sin(10*x)-20.0*math.Cos(10.0*x))*math.Cos(10.0*x*x)
+}
+
+func exact_dudx(p Point) float64 {
+	x := p.X
+	y := p.Y
+	return 20.0*x*math.Cos(10.0*x)*math.Cos(10.0*x*x) - 10.0*math.Sin(10.0*x*x)*(math.Sin(10.0*x)+2.0*x*math.Sin(10.0*y))
+}
+
+func exact_dudy(p Point) float64 {
+	x := p.X
+	y := p.Y
+	return 10.0 * math.Cos(10.0*x*x) * math.Cos(10.0*y)
+}
+
+func exact_dudn(p Point) float64 {
+	x := p.X
+	y := p.Y
+	if math.Abs(x) < 1e-6 {
+		return -exact_dudx(p)
+	} else if math.Abs(x-1.0) < 1e-6 {
+		return exact_dudx(p)
+	}
+	if math.Abs(y) < 1e-6 {
+		return -exact_dudy(p)
+	} else if math.Abs(y-1.0) < 1e-6 {
+		return exact_dudy(p)
+	}
+	return 0.0
+}
+
+type Solver struct {
+	linear_config
+
+	x    []float64
+	grid VTKGrid
+	bnd  int
+	bcs  map[string]BoundaryCondition
+
+	problem *Problem
+	t       float64         // текущее время нестационарного расчёта
+	state   transient_state // состояние нестационарного расчёта для продолжения
+
+	scheme           int  // схема для конвективного члена
+	correction_iters int  // число итераций отложенной коррекции
+	nonorth          int  // способ учёта неортогональности сетки
+	grad_method      int  // способ вычисления градиента для поправок
+	grad_set         bool // способ задан через Set_gradient, иначе МНК
+	nonorth_iters    int  // число итераций поправки на неортогональность
+}
+
+// Set_bnd_type задаёт тип условия (Dirichlet, Neumann или Robin) для граней,
+// не покрытых участками с условиями из Set_bc. Для встроенной тестовой задачи
+// данные берутся из точного решения, для задачи из Set_problem они нулевые.
+func (solver *Solver) Set_bnd_type(bnd int) error {
+	if bnd >= 1 && bnd <= 3 {
+		solver.bnd = bnd
+	} else {
+		return fmt.Errorf("this type is not exist")
+	}
+	return nil
+}
+
+func (solver *Solver) Set_grid(grid VTKGrid) {
+	solver.grid = grid
+	solver.grid.Need_cell_centers()
+	solver.grid.Need_face_geometry()
+}
+
+func find_normal(pi Point, pj Point) Point {
+	x := pj.Y - pi.Y
+	y := -(pj.X - pi.X)
+	return Point{x / math.Sqrt(x*x+y*y), y / math.Sqrt(x*x+y*y), 0.0}
+}
+
+func set_unit_row(m *matrix.DOKMatrix, row int) {
+	cols := m.Cols
+	for i := 0; i < cols; i++ {
+		m.Set(row, i, 0.0)
+	}
+	m.Set(row, row, 1.0)
+}
+
+// assemble_matrix собирает матрицу конечнообъёмной аппроксимации оператора
+// -div(k grad u) + c*u с учётом граничных условий. singular означает, что
+// решение определено с точностью до константы (нигде не задано его значение).
+func (solver *Solver) assemble_matrix() (lhs *matrix.DOKMatrix, singular bool, err error) {
+	nn := len(solver.grid.Cells)
+	problem := solver.problem_def()
+	k, err := solver.cell_conductivity()
+	if err != nil {
+		return nil, false, err
+	}
+	bcs, err := solver.face_bcs()
+	if err != nil {
+		return nil, false, err
+	}
+	// lhs := sparse.NewDOK(nn, nn)
+	lhs, err = matrix.NewDOKMatrix(nn, nn)
+	if err != nil {
+		return nil, false, err
+	}
+	// fmt.Println(solver.grid.Faces_in_cel)
+	for i := 0; i < len(solver.grid.Faces_in_cel); i++ {
+		face := solver.grid.Faces_in_cel[i]
+		left := face[1].Left
+		right := face[1].Right
+		geom := solver.grid.In_geometry(i)
+		dl, dr := geom.owner_distances()
+		coef, _ := decompose(solver.nonorth, geom)
+		v := face_conductivity(k[left], k[right], dl, dr) * coef
+
+		lhs.Set(left, left, lhs.Get(left, left)+v)
+		lhs.Set(right, right, lhs.Get(right, right)+v)
+		lhs.Set(left, right, lhs.Get(left, right)-v)
+		lhs.Set(right, left, lhs.Get(right, left)-v)
+
+	}
+	singular = true
+	if problem.Reaction != nil {
+		for i := 0; i < nn; i++ {
+			c := problem.Reaction(solver.grid.Cell_centers[i])
+			lhs.Set(i, i, lhs.Get(i, i)+c*solver.grid.Cell_volumes[i])
+			if c > 0 {
+				singular = false
+			}
+		}
+	}
+	for i := 0; i < len(solver.grid.Faces_bnd_cel); i++ {
+		face := solver.grid.Faces_bnd_cel[i]
+		left := face[1].Left
+		geom := solver.grid.Bnd_geometry(i)
+		gij := geom.Area
+		center := geom.Center
+		coef, _ := decompose(solver.nonorth, geom)
+		bc := bcs[i]
+		switch bc.Type {
+		case Dirichlet:
+			v := k[left] * coef
+			lhs.Set(left, left, lhs.Get(left, left)+v)
+			singular = false
+		case Robin:
+			// Значение на грани исключается из двухточечной аппроксимации k*du/dn
+			a := bc.Alpha(center)
+			v := k[left] * coef * gij / (k[left]*coef + a*gij)
+			lhs.Set(left, left, lhs.Get(left, left)+v*a)
+			if a > 0 {
+				singular = false
+			}
+		}
+	}
+	flux, err := solver.face_flux()
+	if err != nil {
+		return nil, false, err
+	}
+	if flux != nil {
+		solver.add_convection(lhs, flux, bcs)
+	}
+	return lhs, singular, nil
+}
+
+// assemble_rhs собирает правую часть в момент времени t
+func (solver *Solver) assemble_rhs(t float64) ([]float64, error) {
+	nn := len(solver.grid.Cells)
+	problem := solver.problem_def()
+	k, err := solver.cell_conductivity()
+	if err != nil {
+		return nil, err
+	}
+	bcs, err := solver.face_bcs()
+	if err != nil {
+		return nil, err
+	}
+	rhs0 := make([]float64, nn)
+	for i := 0; i < nn; i++ {
+		rhs0[i] = problem.source(solver.grid.Cell_centers[i], t) * solver.grid.Cell_volumes[i]
+	}
+	for i := 0; i < len(solver.grid.Faces_bnd_cel); i++ {
+		face := solver.grid.Faces_bnd_cel[i]
+		left := face[1].Left
+		geom := solver.grid.Bnd_geometry(i)
+		gij := geom.Area
+		center := geom.Center
+		coef, _ := decompose(solver.nonorth, geom)
+		bc := bcs[i]
+		switch bc.Type {
+		case Dirichlet:
+			v := k[left] * coef
+			rhs0[left] += v * bc.value(center, t)
+		case Neumann:
+			rhs0[left] += gij * bc.value(center, t)
+		case Robin:
+			a := bc.Alpha(center)
+			v := k[left] * coef * gij / (k[left]*coef + a*gij)
+			rhs0[left] += v * bc.value(center, t)
+		}
+	}
+	flux, err := solver.face_flux()
+	if err != nil {
+		return nil, err
+	}
+	if flux != nil {
+		solver.convection_rhs(rhs0, flux, bcs, t)
+	}
+	return rhs0, nil
+}
+
+// Approximate_parts собирает и решает систему стационарной задачи; решение
+// доступно через Solution. Ошибки в условиях, задаче и решателе СЛАУ
+// возвращаются вызывающему.
+func (solver *Solver) Approximate_parts() error {
+	problem := solver.problem_def()
+	lhs, singular, err := solver.assemble_matrix()
+	if err != nil {
+		return err
+	}
+	rhs0, err := solver.assemble_rhs(solver.t)
+	if err != nil {
+		return err
+	}
+	if singular {
+		set_unit_row(lhs, 0)
+		rhs0[0] = 0.0
+		if problem.Exact != nil {
+			rhs0[0] = problem.Exact(solver.grid.Cell_centers[0])
+		}
+	}
+
+	flux, err := solver.face_flux()
+	if err != nil {
+		return err
+	}
+	csr, err := lhs.ToCSR()
+	if err != nil {
+		return err
+	}
+	slv, err := solver.linear_solver(csr, flux == nil)
+	if err != nil {
+		return err
+	}
+	defer slv.Free()
+	solver.x, err = solve_linear(slv, rhs0)
+	if err != nil {
+		return err
+	}
+	tvd := flux != nil && solver.scheme >= Minmod
+	iters := 0
+	if tvd {
+		iters = solver.correction_iters
+		if iters <= 0 {
+			iters = 20
+		}
+	}
+	if solver.nonorth != NoCorrection {
+		n := solver.nonorth_iters
+		if n <= 0 {
+			n = 20
+		}
+		if n > iters {
+			iters = n
+		}
+	}
+	// Отложенная коррекция: поток высокого порядка и поправка на
+	// неортогональность по предыдущему приближению
+	for it := 0; it < iters; it++ {
+		rhs := make([]float64, len(rhs0))
+		copy(rhs, rhs0)
+		if tvd {
+			if err := solver.deferred_correction(rhs, solver.x, flux, solver.t); err != nil {
+				return err
+			}
+		}
+		if err := solver.nonorth_correction(rhs, solver.x, solver.t); err != nil {
+			return err
+		}
+		if singular {
+			rhs[0] = rhs0[0]
+		}
+		x, err := solve_linear(slv, rhs)
+		if err != nil {
+			return err
+		}
+		change, norm := 0.0, 0.0
+		for i := range x {
+			change = math.Max(change, math.Abs(x[i]-solver.x[i]))
+			norm = math.Max(norm, math.Abs(x[i]))
+		}
+		solver.x = x
+		if change <= 1e-10*norm {
+			break
+		}
+	}
+	return nil
+}
+
+// Write_to_file записывает сетку и решение (поле numerical) в файл path
+// в формате legacy VTK или .vtu в зависимости от расширения. Если точное
+// решение задачи известно, записываются также поля exact и error.
+// Дополнительные поля (градиенты, скорость и т.п.) передаются в fields.
+func (solver *Solver) Write_to_file(path string, fields ...Field) error {
+	all, err := solver.output_fields()
+	if err != nil {
+		return err
+	}
+	return solver.grid.Write_file(path, "", append(all, fields...)...)
+}
+
+// output_fields возвращает решение и, если известно точное решение,
+// поля exact и error
+func (solver *Solver) output_fields() ([]Field, error) {
+	grid := &solver.grid
+	if len(solver.x) != len(grid.Cells) {
+		return nil, fmt.Errorf("решение не вычислено")
+	}
+	all := []Field{{Name: "numerical", Values: solver.x}}
+	if exact := solver.problem_def().Exact; exact != nil {
+		u := make([]float64, len(grid.Cells))
+		e := make([]float64, len(grid.Cells))
+		for i := range u {
+			u[i] = exact(grid.Cell_centers[i])
+			e[i] = solver.x[i] - u[i]
+		}
+		all = append(all, Field{Name: "exact", Values: u}, Field{Name: "error", Values: e})
+	}
+	return all, nil
+}
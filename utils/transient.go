@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"test.com/mat/matrix"
+	"test.com/solvers"
+)
+
+// Схемы интегрирования по времени
+const (
+	BackwardEuler = iota // неявная схема Эйлера, первый порядок
+	CrankNicolson        // схема Кранка–Николсон, второй порядок
+	BDF2                 // двухшаговая схема BDF2, первый шаг — неявный Эйлер
+)
+
+// Transient — параметры нестационарного расчёта
+//
+//	rho * du/dt - div(k grad u) + c*u = f(x, t)
+//
+// Коэффициенты, источник и граничные условия берутся из Set_problem и Set_bc,
+// зависимость от времени задаётся через Source_t и BoundaryCondition.Value_t.
+type Transient struct {
+	Scheme   int
+	Dt       float64    // шаг по времени; уменьшается так, чтобы T_end делилось нацело
+	T_end    float64    // конечное время
+	Initial  ScalarFunc // начальное условие; nil означает u = 0
+	Capacity ScalarFunc // rho(x); nil означает rho = 1
+
+	Snapshot_every float64 // интервал между записями; 0 — только начальный и конечный моменты
+	// Snapshot вызывается для каждой записи. Если не задан, а задан Output,
+	// решение записывается в файлы <Output без расширения>_<номер><расширение>
+	// с индексом <Output без расширения>.pvd (см. Series); для Output с
+	// расширением .pvd файлы записываются в формате .vtu.
+	Snapshot func(step int, t float64, u []float64) error
+	Output   string
+
+	// Контрольная точка (см. Save_checkpoint) записывается в Checkpoint каждые
+	// Checkpoint_every шагов и в конце расчёта; 0 — только в конце
+	Checkpoint       string
+	Checkpoint_every int
+}
+
+// transient_state — состояние нестационарного расчёта между шагами,
+// сохраняемое в контрольной точке
+type transient_state struct {
+	scheme     int
+	dt         float64
+	step       int       // число выполненных шагов
+	x_old      []float64 // решение на предыдущем слое (для BDF2)
+	written    int       // число записанных снимков
+	next_write float64   // время следующего снимка
+	resume     bool      // продолжить расчёт с сохранённого шага (после Load_checkpoint)
+}
+
+// Time возвращает текущее время расчёта
+func (solver *Solver) Time() float64 {
+	return solver.t
+}
+
+// Step возвращает число выполненных шагов по времени
+func (solver *Solver) Step() int {
+	return solver.state.step
+}
+
+// Solve_transient решает нестационарную задачу до момента T_end. После
+// Load_checkpoint расчёт продолжается с сохранённого шага, при этом шаг
+// по времени и схема должны совпадать с сохранёнными.
+func (solver *Solver) Solve_transient(tr Transient) error {
+	if tr.Dt <= 0 || tr.T_end <= 0 {
+		return fmt.Errorf("шаг и конечное время должны быть положительными")
+	}
+	if tr.Scheme < BackwardEuler || tr.Scheme > BDF2 {
+		return fmt.Errorf("неизвестная схема по времени: %d", tr.Scheme)
+	}
+	nn := len(solver.grid.Cells)
+	steps := int(math.Ceil(tr.T_end/tr.Dt - 1e-9))
+	dt := tr.T_end / float64(steps)
+	resume := solver.state.resume
+	solver.state.resume = false
+	if resume {
+		if solver.state.scheme != tr.Scheme || solver.state.dt != dt {
+			return fmt.Errorf("схема %d и шаг %g не совпадают с сохранёнными в контрольной точке (%d, %g)",
+				tr.Scheme, dt, solver.state.scheme, solver.state.dt)
+		}
+		if len(solver.x) != nn || solver.state.step > steps {
+			return fmt.Errorf("контрольная точка не соответствует расчёту")
+		}
+	}
+
+	stiff, _, err := solver.assemble_matrix()
+	if err != nil {
+		return err
+	}
+	K, err := stiff.ToCSR()
+	if err != nil {
+		return err
+	}
+	// Диагональная матрица масс
+	mass := make([]float64, nn)
+	for i := 0; i < nn; i++ {
+		mass[i] = solver.grid.Cell_volumes[i]
+		if tr.Capacity != nil {
+			mass[i] *= tr.Capacity(solver.grid.Cell_centers[i])
+		}
+	}
+
+	u := make([]float64, nn)
+	if tr.Initial != nil {
+		for i := 0; i < nn; i++ {
+			u[i] = tr.Initial(solver.grid.Cell_centers[i])
+		}
+	}
+	var u_old []float64
+	if resume {
+		u = append([]float64(nil), solver.x...)
+		u_old = solver.state.x_old
+	} else {
+		solver.t = 0.0
+		solver.x = u
+		solver.state = transient_state{scheme: tr.Scheme, dt: dt}
+	}
+
+	snapshot := tr.Snapshot
+	if snapshot == nil && tr.Output != "" {
+		ext := filepath.Ext(tr.Output)
+		series := &Series{Path: strings.TrimSuffix(tr.Output, ext) + ".pvd", Extension: ext}
+		if strings.EqualFold(ext, ".pvd") {
+			series.Extension = ".vtu"
+		}
+		if resume {
+			// Серия продолжается: файлы после контрольной точки перезаписываются
+			if err := series.reload(solver.state.written); err != nil {
+				return err
+			}
+		}
+		snapshot = func(step int, t float64, u []float64) error {
+			return series.Write(&solver.grid, t, Field{Name: "numerical", Values: u})
+		}
+	}
+	state := &solver.state
+	write := func(force bool) error {
+		if snapshot == nil || !(force || solver.t >= state.next_write-1e-9*dt) {
+			return nil
+		}
+		if err := snapshot(state.written, solver.t, solver.x); err != nil {
+			return err
+		}
+		state.written++
+		for state.next_write <= solver.t+1e-9*dt {
+			if tr.Snapshot_every <= 0 {
+				state.next_write = math.Inf(1)
+				break
+			}
+			state.next_write += tr.Snapshot_every
+		}
+		return nil
+	}
+	if !resume {
+		if err := write(true); err != nil {
+			return err
+		}
+	}
+
+	flux, err := solver.face_flux()
+	if err != nil {
+		return err
+	}
+	// Системы вида (s*M/dt + theta*K) u = ... собираются по мере надобности
+	systems := make(map[[2]float64]solvers.LinearSolver)
+	defer func() {
+		for _, slv := range systems {
+			slv.Free()
+		}
+	}()
+	system := func(s, theta float64) (solvers.LinearSolver, error) {
+		key := [2]float64{s, theta}
+		if slv, ok := systems[key]; ok {
+			return slv, nil
+		}
+		A, err := shifted(K, mass, s/dt, theta)
+		if err != nil {
+			return nil, err
+		}
+		slv, err := solver.linear_solver(A, flux == nil)
+		if err != nil {
+			return nil, err
+		}
+		systems[key] = slv
+		return slv, nil
+	}
+
+	b0, err := solver.assemble_rhs(float64(state.step) * dt)
+	if err != nil {
+		return err
+	}
+	for step := state.step + 1; step <= steps; step++ {
+		t := float64(step) * dt
+		b1, err := solver.assemble_rhs(t)
+		if err != nil {
+			return err
+		}
+		// Поправки TVD-схемы и на неортогональность сетки берутся с
+		// предыдущего слоя и хранятся отдельно от правой части: для схемы
+		// Кранка–Николсон усредняется только правая часть
+		corr := make([]float64, nn)
+		if flux != nil {
+			if err := solver.deferred_correction(corr, u, flux, float64(step-1)*dt); err != nil {
+				return err
+			}
+		}
+		if err := solver.nonorth_correction(corr, u, float64(step-1)*dt); err != nil {
+			return err
+		}
+		rhs := make([]float64, nn)
+		var slv solvers.LinearSolver
+		switch {
+		case tr.Scheme == CrankNicolson:
+			Ku, err := K.MatVec(u)
+			if err != nil {
+				return err
+			}
+			for i := range rhs {
+				rhs[i] = mass[i]/dt*u[i] - 0.5*Ku[i] + 0.5*(b0[i]+b1[i]) + corr[i]
+			}
+			slv, err = system(1.0, 0.5)
+			if err != nil {
+				return err
+			}
+		case tr.Scheme == BDF2 && u_old != nil:
+			for i := range rhs {
+				rhs[i] = mass[i]/dt*(2.0*u[i]-0.5*u_old[i]) + b1[i] + corr[i]
+			}
+			slv, err = system(1.5, 1.0)
+			if err != nil {
+				return err
+			}
+		default:
+			for i := range rhs {
+				rhs[i] = mass[i]/dt*u[i] + b1[i] + corr[i]
+			}
+			slv, err = system(1.0, 1.0)
+			if err != nil {
+				return err
+			}
+		}
+		u_new, err := solve_linear(slv, rhs)
+		if err != nil {
+			return err
+		}
+		u_old, u, b0 = u, u_new, b1
+		solver.t = t
+		solver.x = u
+		state.step = step
+		state.x_old = u_old
+		if err := write(step == steps); err != nil {
+			return err
+		}
+		if tr.Checkpoint != "" && (step == steps || tr.Checkpoint_every > 0 && step%tr.Checkpoint_every == 0) {
+			if err := solver.Save_checkpoint(tr.Checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shifted возвращает матрицу diag(d)*s + theta*K
+func shifted(K *matrix.CSRMatrix, d []float64, s float64, theta float64) (*matrix.CSRMatrix, error) {
+	n := len(d)
+	values := make([]float64, n)
+	rowPtr := make([]int, n+1)
+	colIndices := make([]int, n)
+	for i := 0; i < n; i++ {
+		values[i] = d[i] * s
+		rowPtr[i+1] = i + 1
+		colIndices[i] = i
+	}
+	D, err := matrix.NewCSRMatrix(values, rowPtr, colIndices, n, n)
+	if err != nil {
+		return nil, err
+	}
+	scaled := make([]float64, len(K.Values))
+	for i, v := range K.Values {
+		scaled[i] = theta * v
+	}
+	A, err := matrix.NewCSRMatrix(scaled, K.RowPtr, K.ColIndices, K.Rows, K.Cols)
+	if err != nil {
+		return nil, err
+	}
+	return A.Add(D)
+}
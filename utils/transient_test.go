@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// The solution u = cos(2t) (1 + x + 2y) is linear in space, which the
+// two-point flux reproduces exactly on an orthogonal grid, so the error
+// at t = 1 is the error of the time scheme alone
+func TestTransientOrder(t *testing.T) {
+	exact := func(p Point, tm float64) float64 { return math.Cos(2*tm) * (1 + p.X + 2*p.Y) }
+	source := func(p Point, tm float64) float64 { return -2 * math.Sin(2*tm) * (1 + p.X + 2*p.Y) }
+	grid, err := Rect_grid(0, 0, 1, 1, 4, 4, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	if err := grid.Add_bnd_patch("all", func(p Point) bool { return true }); err != nil {
+		t.Fatalf("Add_bnd_patch failed: %v", err)
+	}
+	cases := []struct {
+		name   string
+		scheme int
+		order  float64
+	}{
+		{"BackwardEuler", BackwardEuler, 1},
+		{"CrankNicolson", CrankNicolson, 2},
+		{"BDF2", BDF2, 2},
+	}
+	for _, c := range cases {
+		var errs []float64
+		for _, dt := range []float64{0.02, 0.01, 0.005} {
+			var solver Solver
+			solver.Set_grid(grid)
+			if err := solver.Set_problem(Problem{Source_t: source}); err != nil {
+				t.Fatalf("Set_problem failed: %v", err)
+			}
+			bc := DirichletBC(func(p Point) float64 { return exact(p, 0) }).With_time(exact)
+			if err := solver.Set_bc("all", bc); err != nil {
+				t.Fatalf("Set_bc failed: %v", err)
+			}
+			tr := Transient{Scheme: c.scheme, Dt: dt, T_end: 1.0,
+				Initial: func(p Point) float64 { return exact(p, 0) }}
+			if err := solver.Solve_transient(tr); err != nil {
+				t.Fatalf("%s: Solve_transient failed: %v", c.name, err)
+			}
+			norms, err := solver.Errors(func(p Point) float64 { return exact(p, 1.0) })
+			if err != nil {
+				t.Fatalf("%s: Errors failed: %v", c.name, err)
+			}
+			errs = append(errs, norms.Linf)
+		}
+		for i := 1; i < len(errs); i++ {
+			order := math.Log2(errs[i-1] / errs[i])
+			if math.Abs(order-c.order) > 0.15 {
+				t.Errorf("%s: errors %.3e, order %.2f, expected %g", c.name, errs, order, c.order)
+			}
+		}
+	}
+}
@@ -0,0 +1,621 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Размеры типов данных legacy VTK в двоичном представлении
+var legacy_sizes = map[string]int{
+	"char": 1, "unsigned_char": 1, "short": 2, "unsigned_short": 2,
+	"int": 4, "unsigned_int": 4, "vtktypeint32": 4, "vtktypeuint32": 4,
+	"long": 8, "unsigned_long": 8, "vtktypeint64": 8, "vtktypeuint64": 8, "vtkIdType": 8,
+	"float": 4, "double": 8,
+}
+
+// legacy_reader читает секции legacy VTK: строки с ключевыми словами всегда
+// текстовые, а массивы после них — текстовые или двоичные big-endian
+type legacy_reader struct {
+	r      *bufio.Reader
+	binary bool
+}
+
+// line возвращает следующую непустую строку без пробелов по краям
+// или io.EOF в конце файла
+func (lr *legacy_reader) line() (string, error) {
+	for {
+		s, err := lr.r.ReadString('\n')
+		s = strings.TrimSpace(s)
+		if s != "" {
+			return s, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// token возвращает следующее слово текстового массива
+func (lr *legacy_reader) token() (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := lr.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			continue
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// values читает n чисел типа kind
+func (lr *legacy_reader) values(kind string, n int) ([]float64, error) {
+	size, ok := legacy_sizes[kind]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый тип данных %q", kind)
+	}
+	if n < 0 || n > legacy_max_count {
+		return nil, fmt.Errorf("неверный размер массива %s: %d", kind, n)
+	}
+	v := make([]float64, n)
+	if !lr.binary {
+		for i := range v {
+			s, err := lr.token()
+			if err != nil {
+				return nil, fmt.Errorf("ошибка при чтении массива %s: %v", kind, err)
+			}
+			v[i], err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка при парсинге числа: %v", err)
+			}
+		}
+		return v, nil
+	}
+	buf := make([]byte, size*n)
+	if _, err := io.ReadFull(lr.r, buf); err != nil {
+		return nil, fmt.Errorf("ошибка при чтении двоичного массива %s: %v", kind, err)
+	}
+	be := binary.BigEndian
+	for i := range v {
+		b := buf[i*size:]
+		switch kind {
+		case "char":
+			v[i] = float64(int8(b[0]))
+		case "unsigned_char":
+			v[i] = float64(b[0])
+		case "short":
+			v[i] = float64(int16(be.Uint16(b)))
+		case "unsigned_short":
+			v[i] = float64(be.Uint16(b))
+		case "int", "vtktypeint32":
+			v[i] = float64(int32(be.Uint32(b)))
+		case "unsigned_int", "vtktypeuint32":
+			v[i] = float64(be.Uint32(b))
+		case "long", "vtktypeint64", "vtkIdType":
+			v[i] = float64(int64(be.Uint64(b)))
+		case "unsigned_long", "vtktypeuint64":
+			v[i] = float64(be.Uint64(b))
+		case "float":
+			v[i] = float64(math.Float32frombits(be.Uint32(b)))
+		case "double":
+			v[i] = math.Float64frombits(be.Uint64(b))
+		}
+	}
+	return v, nil
+}
+
+// ints читает n целых чисел типа kind
+func (lr *legacy_reader) ints(kind string, n int) ([]int, error) {
+	v, err := lr.values(kind, n)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, n)
+	for i, x := range v {
+		if x != math.Trunc(x) {
+			return nil, fmt.Errorf("ожидалось целое число, а не %g", x)
+		}
+		res[i] = int(x)
+	}
+	return res, nil
+}
+
+// Верхняя граница размеров секций, защищающая от испорченных заголовков
+const legacy_max_count = 1 << 30
+
+// legacy_count разбирает размер секции what из заголовка
+func legacy_count(s, what string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > legacy_max_count {
+		return 0, fmt.Errorf("неверное количество %s: %q", what, s)
+	}
+	return n, nil
+}
+
+// section разбирает строку с ключевым словом и проверяет число её полей
+func section(line string, n int) ([]string, error) {
+	parts := strings.Fields(line)
+	if len(parts) < n {
+		return nil, fmt.Errorf("неполная строка %q", line)
+	}
+	return parts, nil
+}
+
+// Read_legacy читает сетку в формате legacy VTK (ASCII или BINARY).
+// Поддерживаются секции POINTS, CELLS и CELL_TYPES, в том числе
+// CELLS с массивами OFFSETS/CONNECTIVITY из версии формата 5.x.
+// Данные из секций CELL_DATA и POINT_DATA (SCALARS, VECTORS, NORMALS,
+// TENSORS, TEXTURE_COORDINATES, COLOR_SCALARS и массивы FIELD) попадают
+// в grid.Fields; таблицы цветов LOOKUP_TABLE пропускаются.
+func Read_legacy(r io.Reader) (VTKGrid, error) {
+	lr := legacy_reader{r: bufio.NewReader(r)}
+	grid := VTKGrid{}
+
+	// Заголовок: версия, название и формат
+	version, err := lr.r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(version, "# vtk DataFile Version") {
+		return VTKGrid{}, fmt.Errorf("файл не является legacy VTK")
+	}
+	grid.Title = strings.TrimSpace(strings.TrimPrefix(version, "# vtk DataFile Version"))
+	if _, err := lr.r.ReadString('\n'); err != nil {
+		return VTKGrid{}, fmt.Errorf("ошибка при чтении заголовка: %v", err)
+	}
+	format, err := lr.line()
+	if err != nil {
+		return VTKGrid{}, fmt.Errorf("ошибка при чтении заголовка: %v", err)
+	}
+	switch strings.ToUpper(format) {
+	case "ASCII":
+		grid.Format = "ASCII"
+	case "BINARY":
+		grid.Format = "BINARY"
+		lr.binary = true
+	default:
+		return VTKGrid{}, fmt.Errorf("неизвестный формат %q", format)
+	}
+	// В версии 5.x ячейки задаются массивами OFFSETS и CONNECTIVITY
+	major, _ := strconv.ParseFloat(grid.Title, 64)
+	offsets := major >= 5
+
+	// Текущая секция данных: число значений (-1 — вне секции) и место
+	data_size, point := -1, false
+	for {
+		line, err := lr.line()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return VTKGrid{}, fmt.Errorf("ошибка при чтении файла: %v", err)
+		}
+		keyword := strings.Fields(line)[0]
+
+		switch keyword {
+		case "DATASET":
+			grid.DatasetType = strings.TrimSpace(strings.TrimPrefix(line, "DATASET"))
+
+		case "POINTS":
+			parts, err := section(line, 3)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			numPoints, err := legacy_count(parts[1], "точек")
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			coords, err := lr.values(parts[2], 3*numPoints)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			grid.Points = make([]Point, numPoints)
+			for i := range grid.Points {
+				grid.Points[i] = Point{X: coords[3*i], Y: coords[3*i+1], Z: coords[3*i+2]}
+			}
+
+		case "CELLS":
+			parts, err := section(line, 3)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			numCells, err := legacy_count(parts[1], "ячеек")
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			size, err := legacy_count(parts[2], "номеров узлов")
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			if offsets {
+				grid.Cells, err = lr.offset_cells(numCells, size)
+			} else {
+				grid.Cells, err = lr.counted_cells(numCells, size)
+			}
+			if err != nil {
+				return VTKGrid{}, err
+			}
+
+		case "CELL_TYPES":
+			parts, err := section(line, 2)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			numCellTypes, err := legacy_count(parts[1], "типов ячеек")
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			grid.CellTypes, err = lr.ints("int", numCellTypes)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+
+		case "CELL_DATA", "POINT_DATA":
+			parts, err := section(line, 2)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			if data_size, err = strconv.Atoi(parts[1]); err != nil || data_size < 0 {
+				return VTKGrid{}, fmt.Errorf("ошибка при парсинге размера секции %s", keyword)
+			}
+			point = keyword == "POINT_DATA"
+
+		case "SCALARS", "VECTORS", "NORMALS", "TENSORS", "TENSORS6", "TEXTURE_COORDINATES",
+			"COLOR_SCALARS", "LOOKUP_TABLE", "FIELD":
+			if data_size < 0 {
+				return VTKGrid{}, fmt.Errorf("секция %s вне CELL_DATA и POINT_DATA", keyword)
+			}
+			fields, err := lr.attribute(line, data_size)
+			if err != nil {
+				return VTKGrid{}, err
+			}
+			for _, f := range fields {
+				f.Point = point
+				grid.Fields = append(grid.Fields, f)
+			}
+		}
+	}
+
+	if err := grid.build_faces(); err != nil {
+		return VTKGrid{}, err
+	}
+	return grid, nil
+}
+
+// attribute читает массив данных из секции CELL_DATA или POINT_DATA
+// с n значениями в каждой компоненте
+func (lr *legacy_reader) attribute(line string, n int) ([]Field, error) {
+	parts, err := section(line, 3)
+	if err != nil {
+		return nil, err
+	}
+	name := parts[1]
+	read := func(kind string, components int) ([]Field, error) {
+		values, err := lr.values(kind, n*components)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении поля %s: %v", name, err)
+		}
+		return []Field{{Name: name, Values: values, Components: components}}, nil
+	}
+	// Цвета записываются числами float в тексте и байтами 0..255 в двоичном виде
+	colors := func(count int) ([]float64, error) {
+		if !lr.binary {
+			return lr.values("float", count)
+		}
+		v, err := lr.values("unsigned_char", count)
+		for i := range v {
+			v[i] /= 255.0
+		}
+		return v, err
+	}
+
+	switch parts[0] {
+	case "SCALARS":
+		components := 1
+		if len(parts) > 3 {
+			if components, err = strconv.Atoi(parts[3]); err != nil || components < 1 {
+				return nil, fmt.Errorf("неверное число компонент поля %s", name)
+			}
+		}
+		table, err := lr.line()
+		if err != nil || !strings.HasPrefix(table, "LOOKUP_TABLE") {
+			return nil, fmt.Errorf("после SCALARS %s ожидалась строка LOOKUP_TABLE", name)
+		}
+		return read(parts[2], components)
+	case "VECTORS", "NORMALS":
+		return read(parts[2], 3)
+	case "TENSORS":
+		return read(parts[2], 9)
+	case "TENSORS6":
+		return read(parts[2], 6)
+	case "TEXTURE_COORDINATES":
+		parts, err := section(line, 4)
+		if err != nil {
+			return nil, err
+		}
+		dim, err := strconv.Atoi(parts[2])
+		if err != nil || dim < 1 || dim > 3 {
+			return nil, fmt.Errorf("неверная размерность текстурных координат %s", name)
+		}
+		return read(parts[3], dim)
+	case "COLOR_SCALARS":
+		components, err := strconv.Atoi(parts[2])
+		if err != nil || components < 1 {
+			return nil, fmt.Errorf("неверное число компонент цвета %s", name)
+		}
+		values, err := colors(n * components)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении поля %s: %v", name, err)
+		}
+		return []Field{{Name: name, Values: values, Components: components}}, nil
+	case "LOOKUP_TABLE":
+		size, err := strconv.Atoi(parts[2])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("неверный размер таблицы цветов %s", name)
+		}
+		_, err = colors(4 * size)
+		return nil, err
+	}
+
+	// FIELD: число массивов, затем для каждого "имя компоненты кортежи тип"
+	count, err := strconv.Atoi(parts[2])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("неверное число массивов в FIELD %s", name)
+	}
+	var fields []Field
+	for k := 0; k < count; k++ {
+		header, err := lr.line()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении FIELD %s: %v", name, err)
+		}
+		parts := strings.Fields(header)
+		if len(parts) == 1 && parts[0] == "NULL_ARRAY" {
+			continue
+		}
+		if len(parts) < 4 {
+			return nil, fmt.Errorf("неполная строка %q", header)
+		}
+		components, err1 := strconv.Atoi(parts[1])
+		tuples, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil || components < 1 || tuples < 0 {
+			return nil, fmt.Errorf("неверный размер массива %q", header)
+		}
+		values, err := lr.values(parts[3], components*tuples)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении массива %s: %v", parts[0], err)
+		}
+		fields = append(fields, Field{Name: parts[0], Values: values, Components: components})
+	}
+	return fields, nil
+}
+
+// counted_cells читает ячейки в классической записи: число узлов, затем узлы
+func (lr *legacy_reader) counted_cells(numCells, size int) ([]Cell, error) {
+	// Для каждой ячейки записано хотя бы число её узлов
+	if numCells > size {
+		return nil, fmt.Errorf("размер секции CELLS %d меньше числа ячеек %d", size, numCells)
+	}
+	data, err := lr.ints("int", size)
+	if err != nil {
+		return nil, err
+	}
+	cells := make([]Cell, numCells)
+	pos := 0
+	for i := range cells {
+		if pos >= size || pos+1+data[pos] > size || data[pos] < 0 {
+			return nil, fmt.Errorf("список узлов ячейки %d выходит за размер секции CELLS", i)
+		}
+		cells[i] = Cell{Indices: data[pos+1 : pos+1+data[pos] : pos+1+data[pos]]}
+		pos += 1 + data[pos]
+	}
+	return cells, nil
+}
+
+// offset_cells читает ячейки в записи версии 5.x: numOffsets смещений
+// (на одно больше числа ячеек) и size номеров узлов
+func (lr *legacy_reader) offset_cells(numOffsets, size int) ([]Cell, error) {
+	arrays := make(map[string][]int)
+	for _, name := range []string{"OFFSETS", "CONNECTIVITY"} {
+		line, err := lr.line()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении секции %s: %v", name, err)
+		}
+		parts, err := section(line, 2)
+		if err != nil {
+			return nil, err
+		}
+		if parts[0] != name {
+			return nil, fmt.Errorf("ожидалась секция %s, а не %q", name, line)
+		}
+		n := numOffsets
+		if name == "CONNECTIVITY" {
+			n = size
+		}
+		if arrays[name], err = lr.ints(parts[1], n); err != nil {
+			return nil, err
+		}
+	}
+	offs, conn := arrays["OFFSETS"], arrays["CONNECTIVITY"]
+	if numOffsets == 0 {
+		return nil, nil
+	}
+	cells := make([]Cell, numOffsets-1)
+	for i := range cells {
+		if offs[i] < 0 || offs[i] > offs[i+1] || offs[i+1] > size {
+			return nil, fmt.Errorf("неверные смещения ячейки %d", i)
+		}
+		cells[i] = Cell{Indices: conn[offs[i]:offs[i+1]:offs[i+1]]}
+	}
+	return cells, nil
+}
+
+// legacy_writer записывает массивы legacy VTK; после первой ошибки запись
+// прекращается, а ошибка сохраняется в err
+type legacy_writer struct {
+	w      *bufio.Writer
+	binary bool
+	err    error
+}
+
+func (lw *legacy_writer) printf(format string, args ...interface{}) {
+	if lw.err == nil {
+		_, lw.err = fmt.Fprintf(lw.w, format, args...)
+	}
+}
+
+// doubles записывает массив double; в текстовом виде по row чисел в строке
+func (lw *legacy_writer) doubles(v []float64, row int) {
+	if lw.err != nil {
+		return
+	}
+	if lw.binary {
+		var b [8]byte
+		for _, x := range v {
+			binary.BigEndian.PutUint64(b[:], math.Float64bits(x))
+			if _, lw.err = lw.w.Write(b[:]); lw.err != nil {
+				return
+			}
+		}
+		lw.printf("\n")
+		return
+	}
+	for i, x := range v {
+		sep := " "
+		if (i+1)%row == 0 || i == len(v)-1 {
+			sep = "\n"
+		}
+		lw.printf("%s%s", strconv.FormatFloat(x, 'g', -1, 64), sep)
+	}
+}
+
+// ints записывает строку массива int
+func (lw *legacy_writer) ints(v []int) {
+	if lw.err != nil {
+		return
+	}
+	if lw.binary {
+		var b [4]byte
+		for _, x := range v {
+			binary.BigEndian.PutUint32(b[:], uint32(int32(x)))
+			if _, lw.err = lw.w.Write(b[:]); lw.err != nil {
+				return
+			}
+		}
+		return
+	}
+	for i, x := range v {
+		sep := " "
+		if i == len(v)-1 {
+			sep = "\n"
+		}
+		lw.printf("%d%s", x, sep)
+	}
+}
+
+// end завершает двоичный массив переводом строки
+func (lw *legacy_writer) end() {
+	if lw.binary {
+		lw.printf("\n")
+	}
+}
+
+// Write_legacy записывает сетку и поля в ячейках и узлах в формате
+// legacy VTK версии 3.0. Поля с 1, 3 и 9 компонентами записываются как
+// SCALARS, VECTORS и TENSORS, остальные — массивами секции FIELD.
+// format — "ASCII" или "BINARY"; в двоичном виде координаты и данные
+// записываются как double, а номера узлов как int в порядке big-endian.
+// Числа в тексте записываются без потери точности.
+func (grid *VTKGrid) Write_legacy(w io.Writer, format string, fields ...Field) error {
+	lw := legacy_writer{w: bufio.NewWriter(w)}
+	switch format {
+	case "ASCII":
+	case "BINARY":
+		lw.binary = true
+	default:
+		return fmt.Errorf("неизвестный формат %q", format)
+	}
+	if err := grid.check_fields(fields); err != nil {
+		return err
+	}
+
+	lw.printf("# vtk DataFile Version 3.0\nvtk output\n%s\nDATASET UNSTRUCTURED_GRID\n", format)
+
+	lw.printf("POINTS %d double\n", len(grid.Points))
+	coords := make([]float64, 0, 3*len(grid.Points))
+	for _, p := range grid.Points {
+		coords = append(coords, p.X, p.Y, p.Z)
+	}
+	lw.doubles(coords, 3)
+
+	size := 0
+	for _, c := range grid.Cells {
+		size += 1 + len(c.Indices)
+	}
+	lw.printf("CELLS %d %d\n", len(grid.Cells), size)
+	row := make([]int, 0, 9)
+	for _, c := range grid.Cells {
+		row = append(append(row[:0], len(c.Indices)), c.Indices...)
+		lw.ints(row)
+	}
+	lw.end()
+
+	lw.printf("CELL_TYPES %d\n", len(grid.Cells))
+	for i := range grid.Cells {
+		lw.ints([]int{grid.cell_type(i)})
+	}
+	lw.end()
+
+	for _, point := range []bool{false, true} {
+		selected := select_fields(fields, point)
+		if len(selected) == 0 {
+			continue
+		}
+		if point {
+			lw.printf("POINT_DATA %d\n", len(grid.Points))
+		} else {
+			lw.printf("CELL_DATA %d\n", len(grid.Cells))
+		}
+		var arrays []Field
+		for _, f := range selected {
+			switch f.components() {
+			case 1:
+				lw.printf("SCALARS %s double 1\nLOOKUP_TABLE default\n", f.Name)
+				lw.doubles(f.Values, 1)
+			case 3:
+				lw.printf("VECTORS %s double\n", f.Name)
+				lw.doubles(f.Values, 3)
+			case 9:
+				lw.printf("TENSORS %s double\n", f.Name)
+				lw.doubles(f.Values, 3)
+			default:
+				arrays = append(arrays, f)
+			}
+		}
+		if len(arrays) > 0 {
+			lw.printf("FIELD FieldData %d\n", len(arrays))
+		}
+		for _, f := range arrays {
+			n := f.components()
+			lw.printf("%s %d %d double\n", f.Name, n, len(f.Values)/n)
+			lw.doubles(f.Values, n)
+		}
+	}
+
+	if lw.err != nil {
+		return fmt.Errorf("ошибка при записи в файл: %v", lw.err)
+	}
+	if err := lw.w.Flush(); err != nil {
+		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sameGrid(t *testing.T, name string, got, want VTKGrid) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Points, want.Points) {
+		t.Errorf("%s: points differ after round trip", name)
+	}
+	if !reflect.DeepEqual(got.Cells, want.Cells) {
+		t.Errorf("%s: cells differ after round trip", name)
+	}
+	if !reflect.DeepEqual(got.CellTypes, want.CellTypes) {
+		t.Errorf("%s: cell types differ after round trip", name)
+	}
+	if !reflect.DeepEqual(got.Faces, want.Faces) {
+		t.Errorf("%s: faces differ after round trip", name)
+	}
+}
+
+func TestLegacyRoundTrip(t *testing.T) {
+	for _, name := range []string{"grid.vtk", "3.vtk", "tetragrid_2000.vtk"} {
+		ascii, err := Grid("../test_data/" + name)
+		if err != nil {
+			t.Fatalf("Grid(%s) failed: %v", name, err)
+		}
+		field := Field{Name: "index", Values: make([]float64, len(ascii.Cells))}
+		for i := range field.Values {
+			field.Values[i] = math.Pi * float64(i)
+		}
+		for _, format := range []string{"BINARY", "ASCII"} {
+			var buf bytes.Buffer
+			if err := ascii.Write_legacy(&buf, format, field); err != nil {
+				t.Fatalf("Write_legacy(%s) failed: %v", format, err)
+			}
+			grid, err := Read_legacy(&buf)
+			if err != nil {
+				t.Fatalf("%s %s: Read_legacy failed: %v", name, format, err)
+			}
+			if grid.Format != format {
+				t.Errorf("%s: format %q, expected %q", name, grid.Format, format)
+			}
+			sameGrid(t, name+" "+format, grid, ascii)
+		}
+	}
+}
+
+func TestLegacyBinaryFloatOffsets(t *testing.T) {
+	// Two triangles in the 5.1 layout with float points and 64-bit offsets
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	fmt.Fprintf(&buf, "# vtk DataFile Version 5.1\ntriangles\nBINARY\nDATASET UNSTRUCTURED_GRID\n")
+	fmt.Fprintf(&buf, "POINTS 4 float\n")
+	binary.Write(&buf, be, []float32{0, 0, 0, 1, 0, 0, 0.5, 1, 0, 1.5, 1, 0})
+	fmt.Fprintf(&buf, "\nCELLS 3 6\nOFFSETS vtktypeint64\n")
+	binary.Write(&buf, be, []int64{0, 3, 6})
+	fmt.Fprintf(&buf, "\nCONNECTIVITY vtktypeint64\n")
+	binary.Write(&buf, be, []int64{0, 1, 2, 1, 3, 2})
+	fmt.Fprintf(&buf, "\nCELL_TYPES 2\n")
+	binary.Write(&buf, be, []int32{VTKTriangle, VTKTriangle})
+	fmt.Fprintf(&buf, "\n")
+
+	grid, err := Read_legacy(&buf)
+	if err != nil {
+		t.Fatalf("Read_legacy failed: %v", err)
+	}
+	want, err := Grid("../test_data/3.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	sameGrid(t, "5.1 binary", grid, want)
+}
+
+func TestLegacyTruncated(t *testing.T) {
+	ascii, err := Grid("../test_data/3.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ascii.Write_legacy(&buf, "BINARY"); err != nil {
+		t.Fatalf("Write_legacy failed: %v", err)
+	}
+	data := buf.Bytes()
+	if _, err := Read_legacy(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Error("Expected an error for a truncated binary file")
+	}
+	// Negative and oversized counts in the section headers
+	header := "# vtk DataFile Version 3.0\nbad counts\nASCII\nDATASET UNSTRUCTURED_GRID\n"
+	points := "POINTS 3 float\n0 0 0 1 0 0 0 1 0\n"
+	for _, text := range []string{
+		"POINTS -1 float\n",
+		"POINTS 4294967296 float\n",
+		points + "CELLS -1 4\n3 0 1 2\n",
+		points + "CELLS 1 -4\n3 0 1 2\n",
+		points + "CELLS 5 4\n3 0 1 2\n",
+		points + "CELLS 1 4\n3 0 1 2\nCELL_TYPES -1\n5\n",
+		points + "CELLS 1 4\n3 0 1 2\nCELL_TYPES 2\n5 5\n",
+	} {
+		if _, err := Read_legacy(strings.NewReader(header + text)); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+	}
+}
+
+func TestLegacyDataSections(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 3, 2, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	nc, np := len(grid.Cells), len(grid.Points)
+	fill := func(n int, v float64) []float64 {
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = v + 0.1*float64(i)
+		}
+		return values
+	}
+	fields := []Field{
+		{Name: "u", Values: fill(nc, 1), Components: 1},
+		{Name: "grad", Values: fill(3*nc, 2), Components: 3},
+		{Name: "stress", Values: fill(9*nc, 3), Components: 9},
+		{Name: "pair", Values: fill(2*nc, 4), Components: 2},
+		{Name: "u", Values: fill(np, 5), Components: 1, Point: true},
+	}
+	for _, format := range []string{"ASCII", "BINARY"} {
+		var buf bytes.Buffer
+		if err := grid.Write_legacy(&buf, format, fields...); err != nil {
+			t.Fatalf("Write_legacy(%s) failed: %v", format, err)
+		}
+		back, err := Read_legacy(&buf)
+		if err != nil {
+			t.Fatalf("Read_legacy(%s) failed: %v", format, err)
+		}
+		if !reflect.DeepEqual(back.Fields, fields) {
+			t.Errorf("%s: fields differ after round trip: %v", format, back.Fields)
+		}
+		if f := back.Point_field("u"); f == nil || f.Values[0] != 5 {
+			t.Errorf("%s: point field u not found", format)
+		}
+		if back.Cell_field("missing") != nil {
+			t.Errorf("%s: found a field that was not written", format)
+		}
+	}
+
+	// Sections written by other tools: a custom lookup table,
+	// multi-component scalars, normals and colors
+	text := `# vtk DataFile Version 2.0
+two triangles
+ASCII
+DATASET UNSTRUCTURED_GRID
+POINTS 4 float
+0 0 0 1 0 0 0.5 1 0 1.5 1 0
+CELLS 2 8
+3 0 1 2
+3 1 3 2
+CELL_TYPES 2
+5 5
+CELL_DATA 2
+SCALARS material int
+LOOKUP_TABLE materials
+1 2
+LOOKUP_TABLE materials 2
+0 0 0 1
+1 1 1 1
+SCALARS pair float 2
+LOOKUP_TABLE default
+1 2 3 4
+POINT_DATA 4
+NORMALS n float
+0 0 1 0 0 1 0 0 1 0 0 1
+COLOR_SCALARS rgb 3
+0 0 0 1 1 1 0.5 0.5 0.5 0 0 1
+FIELD extra 2
+id 1 4 int
+7 8 9 10
+NULL_ARRAY
+`
+	back, err := Read_legacy(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("Read_legacy failed: %v", err)
+	}
+	want := []struct {
+		name       string
+		point      bool
+		components int
+		values     []float64
+	}{
+		{"material", false, 1, []float64{1, 2}},
+		{"pair", false, 2, []float64{1, 2, 3, 4}},
+		{"n", true, 3, []float64{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}},
+		{"rgb", true, 3, []float64{0, 0, 0, 1, 1, 1, 0.5, 0.5, 0.5, 0, 0, 1}},
+		{"id", true, 1, []float64{7, 8, 9, 10}},
+	}
+	if len(back.Fields) != len(want) {
+		t.Fatalf("Read %d fields, expected %d", len(back.Fields), len(want))
+	}
+	for i, w := range want {
+		f := back.Fields[i]
+		if f.Name != w.name || f.Point != w.point || f.Components != w.components ||
+			!reflect.DeepEqual(f.Values, w.values) {
+			t.Errorf("Field %d: got %+v, expected %+v", i, f, w)
+		}
+	}
+
+	bad := strings.Replace(text, "SCALARS pair float 2\nLOOKUP_TABLE default\n", "SCALARS pair float 2\n", 1)
+	if _, err := Read_legacy(strings.NewReader(bad)); err == nil {
+		t.Error("Expected an error for SCALARS without LOOKUP_TABLE")
+	}
+}
+
+func TestLegacyNodeIndexOutOfRange(t *testing.T) {
+	for _, cell := range []string{"3 0 1 7", "3 0 -1 2"} {
+		text := `# vtk DataFile Version 3.0
+bad indices
+ASCII
+DATASET UNSTRUCTURED_GRID
+POINTS 3 float
+0 0 0 1 0 0 0 1 0
+CELLS 1 4
+` + cell + `
+CELL_TYPES 1
+5
+`
+		if _, err := Read_legacy(strings.NewReader(text)); err == nil {
+			t.Errorf("Expected an error for the cell %q", cell)
+		}
+	}
+	// Offsets of the 5.x format with negative counts and a negative offset
+	for _, cells := range []string{
+		"CELLS -2 3\nOFFSETS vtktypeint64\n0 3\nCONNECTIVITY vtktypeint64\n0 1 2\n",
+		"CELLS 2 -3\nOFFSETS vtktypeint64\n0 3\nCONNECTIVITY vtktypeint64\n0 1 2\n",
+		"CELLS 2 3\nOFFSETS vtktypeint64\n-1 3\nCONNECTIVITY vtktypeint64\n0 1 2\n",
+	} {
+		text := "# vtk DataFile Version 5.1\nbad offsets\nASCII\nDATASET UNSTRUCTURED_GRID\n" +
+			"POINTS 3 float\n0 0 0 1 0 0 0 1 0\n" + cells + "CELL_TYPES 1\n5\n"
+		if _, err := Read_legacy(strings.NewReader(text)); err == nil {
+			t.Errorf("Expected an error for %q", cells)
+		}
+	}
+}
@@ -1,74 +1,110 @@
-package utils
-
-import (
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-)
-
-func writefile(filename string, cell_data []float64) error {
-	// Определяем целевую директорию
-	destinationDir := "output_directory"
-	destinationFile := filepath.Join(destinationDir, filename)
-
-	// Создаем целевую директорию, если она не существует
-	err := os.MkdirAll(destinationDir, 0755) // 0755 - права доступа
-	if err != nil {
-		return fmt.Errorf("ошибка при создании директории: %v", err)
-	}
-
-	// Копируем файл из test_data в output_directory
-	sourceFile := filepath.Join("test_data", filename)
-	err = copyFile(sourceFile, destinationFile)
-	if err != nil {
-		return fmt.Errorf("ошибка при копировании файла: %v", err)
-	}
-
-	// Открываем файл для записи (перезаписываем, если файл уже существует)
-	file, err := os.OpenFile(destinationFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("ошибка при создании файла: %v", err)
-	}
-	defer file.Close()
-
-	// Записываем первую строку "cell_data"
-	_, err = fmt.Fprintf(file, "CELL_DATA %d\nSCALARS numerical double 1\nLOOKUP_TABLE default\n", len(cell_data))
-	if err != nil {
-		return fmt.Errorf("ошибка при записи в файл: %v", err)
-	}
-
-	// Записываем каждое число из массива cell_data в отдельной строке
-	for _, value := range cell_data {
-		_, err = fmt.Fprintf(file, "%f\n", value)
-		if err != nil {
-			return fmt.Errorf("ошибка при записи в файл: %v", err)
-		}
-	}
-
-	return nil
-}
-
-func copyFile(src, dst string) error {
-	// Открываем исходный файл
-	source, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("не удалось открыть исходный файл: %v", err)
-	}
-	defer source.Close()
-
-	// Создаем целевой файл
-	destination, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("не удалось создать целевой файл: %v", err)
-	}
-	defer destination.Close()
-
-	// Копируем данные из исходного файла в целевой
-	_, err = io.Copy(destination, source)
-	if err != nil {
-		return fmt.Errorf("ошибка при копировании данных: %v", err)
-	}
-
-	return nil
-}
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Field — именованный массив данных в ячейках или узлах сетки. Значения
+// многокомпонентного поля хранятся подряд для каждой ячейки (узла): 3 компоненты
+// записываются как вектор, 9 — как тензор 3x3 по строкам, другое число
+// компонент — как массив FIELD.
+type Field struct {
+	Name       string
+	Values     []float64
+	Components int  // число компонент; 0 означает 1
+	Point      bool // данные в узлах, а не в ячейках
+}
+
+// Vector_field возвращает векторное поле в ячейках из значений v
+func Vector_field(name string, v []Point) Field {
+	values := make([]float64, 0, 3*len(v))
+	for _, p := range v {
+		values = append(values, p.X, p.Y, p.Z)
+	}
+	return Field{Name: name, Values: values, Components: 3}
+}
+
+func (f Field) components() int {
+	if f.Components <= 0 {
+		return 1
+	}
+	return f.Components
+}
+
+// select_fields возвращает поля в узлах (point) или в ячейках
+func select_fields(fields []Field, point bool) []Field {
+	var res []Field
+	for _, f := range fields {
+		if f.Point == point {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// check_fields проверяет имена и размеры полей
+func (grid *VTKGrid) check_fields(fields []Field) error {
+	type key struct {
+		name  string
+		point bool
+	}
+	names := make(map[key]bool)
+	for _, f := range fields {
+		if f.Name == "" || strings.ContainsAny(f.Name, " \t\r\n") {
+			return fmt.Errorf("недопустимое имя поля %q", f.Name)
+		}
+		if names[key{f.Name, f.Point}] {
+			return fmt.Errorf("поле %s задано дважды", f.Name)
+		}
+		names[key{f.Name, f.Point}] = true
+		n, where := len(grid.Cells), "ячеек"
+		if f.Point {
+			n, where = len(grid.Points), "узлов"
+		}
+		if len(f.Values) != n*f.components() {
+			return fmt.Errorf("размер поля %s (%d) не совпадает с числом %s %d, умноженным на число компонент %d",
+				f.Name, len(f.Values), where, n, f.components())
+		}
+	}
+	return nil
+}
+
+// Write_file записывает сетку и поля в ячейках и узлах в файл path, создавая
+// недостающие директории. Формат файла выбирается по расширению: .vtu —
+// VTK XML, остальные — legacy VTK. format — "ascii" или "binary", для .vtu
+// также "appended" (сжатые данные); пустая строка означает ascii для legacy
+// VTK и appended для .vtu.
+func (grid *VTKGrid) Write_file(path string, format string, fields ...Field) error {
+	xml := strings.EqualFold(filepath.Ext(path), ".vtu")
+	format = strings.ToLower(format)
+	switch {
+	case format == "" && xml:
+		format = "appended"
+	case format == "":
+		format = "ascii"
+	case format == "appended" && !xml:
+		return fmt.Errorf("формат appended поддерживается только для файлов .vtu")
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("ошибка при создании директории: %v", err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла: %v", err)
+	}
+	if xml {
+		err = grid.Write_vtu(file, format, fields...)
+	} else {
+		err = grid.Write_legacy(file, strings.ToUpper(format), fields...)
+	}
+	if cerr := file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("ошибка при записи в файл: %v", cerr)
+	}
+	return err
+}
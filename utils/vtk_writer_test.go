@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	grid, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	dir := t.TempDir()
+	field := Field{Name: "u", Values: make([]float64, len(grid.Cells))}
+	cases := []struct{ path, format, stored string }{
+		{"a/b/grid.vtk", "", "ASCII"},
+		{"grid_bin.vtk", "binary", "BINARY"},
+		{"nested/grid.vtu", "", "appended"},
+		{"grid_ascii.vtu", "ascii", "ascii"},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.path)
+		if err := grid.Write_file(path, c.format, field); err != nil {
+			t.Fatalf("Write_file(%s) failed: %v", c.path, err)
+		}
+		back, err := Grid(path)
+		if err != nil {
+			t.Fatalf("Grid(%s) failed: %v", c.path, err)
+		}
+		if back.Format != c.stored {
+			t.Errorf("%s: format %q, expected %q", c.path, back.Format, c.stored)
+		}
+		sameGrid(t, c.path, back, grid)
+	}
+	if err := grid.Write_file(filepath.Join(dir, "x.vtk"), "appended"); err == nil {
+		t.Error("Expected an error for appended legacy output")
+	}
+}
+
+func TestSolverWriteToFile(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 4, 4, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	var solver Solver
+	solver.Set_grid(grid)
+	path := filepath.Join(t.TempDir(), "out", "rect.vtk")
+	if err := solver.Write_to_file(path); err == nil {
+		t.Error("Expected an error before the solution is computed")
+	}
+	solver.Set_bnd_type(Dirichlet)
+	if err := solver.Approximate_parts(); err != nil {
+		t.Fatalf("Approximate_parts failed: %v", err)
+	}
+	if err := solver.Write_to_file(path); err != nil {
+		t.Fatalf("Write_to_file failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("CELL_DATA 16\nSCALARS numerical double 1\n")) {
+		t.Error("Output has no numerical cell data")
+	}
+	for _, name := range []string{"exact", "error"} {
+		if !bytes.Contains(data, []byte("SCALARS "+name+" double 1\n")) {
+			t.Errorf("Output has no %s field", name)
+		}
+	}
+	back, err := Grid(path)
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	sameGrid(t, "rect", back, grid)
+}
+
+func TestWriteMultipleFields(t *testing.T) {
+	grid, err := Rect_grid(0, 0, 1, 1, 3, 2, nil)
+	if err != nil {
+		t.Fatalf("Rect_grid failed: %v", err)
+	}
+	nc, np := len(grid.Cells), len(grid.Points)
+	fill := func(n int, v float64) []float64 {
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = v + float64(i)
+		}
+		return values
+	}
+	fields := []Field{
+		{Name: "u", Values: fill(nc, 0.30000000000000004)},
+		Vector_field("grad", grid.Cell_centers),
+		{Name: "stress", Values: fill(9*nc, 1), Components: 9},
+		{Name: "pair", Values: fill(2*nc, 2), Components: 2},
+		{Name: "u", Values: fill(np, 3), Point: true},
+	}
+
+	var buf bytes.Buffer
+	if err := grid.Write_legacy(&buf, "ASCII", fields...); err != nil {
+		t.Fatalf("Write_legacy failed: %v", err)
+	}
+	text := buf.String()
+	for _, s := range []string{
+		"CELL_DATA 6\nSCALARS u double 1\nLOOKUP_TABLE default\n0.30000000000000004\n",
+		"VECTORS grad double\n", "TENSORS stress double\n",
+		"FIELD FieldData 1\npair 2 6 double\n2 3\n",
+		"POINT_DATA 12\nSCALARS u double 1\n",
+	} {
+		if !strings.Contains(text, s) {
+			t.Errorf("Legacy output lacks %q", s)
+		}
+	}
+	if err := grid.Write_legacy(&buf, "BINARY", fields...); err != nil {
+		t.Fatalf("Write_legacy failed: %v", err)
+	}
+
+	buf.Reset()
+	if err := grid.Write_vtu(&buf, "appended", fields...); err != nil {
+		t.Fatalf("Write_vtu failed: %v", err)
+	}
+	f, err := parse_vtu(buf.Bytes())
+	if err != nil {
+		t.Fatalf("parse_vtu failed: %v", err)
+	}
+	piece := f.Pieces[0]
+	if len(piece.Cell_data) != 4 || len(piece.Point_data) != 1 {
+		t.Fatalf("Got %d cell and %d point arrays", len(piece.Cell_data), len(piece.Point_data))
+	}
+	for i, a := range piece.Cell_data {
+		want := fields[i]
+		values, err := f.values(a, len(want.Values))
+		if err != nil {
+			t.Fatalf("Reading %s failed: %v", a.Name, err)
+		}
+		if a.Name != want.Name || want.components() > 1 && a.Components != want.components() ||
+			!reflect.DeepEqual(values, want.Values) {
+			t.Errorf("Array %s differs after round trip", a.Name)
+		}
+	}
+	if !strings.Contains(buf.String(), `<CellData Scalars="u" Vectors="grad" Tensors="stress">`) {
+		t.Error("Active attributes are not set on CellData")
+	}
+
+	for _, bad := range [][]Field{
+		{{Name: "u", Values: fill(nc+1, 0)}},
+		{{Name: "v", Values: fill(nc, 0), Components: 3}},
+		{{Name: "u", Values: fill(nc, 0)}, {Name: "u", Values: fill(nc, 0)}},
+		{{Name: "two words", Values: fill(nc, 0)}},
+	} {
+		if err := grid.Write_legacy(&buf, "ASCII", bad...); err == nil {
+			t.Errorf("Expected an error for fields %v", bad[len(bad)-1].Name)
+		}
+	}
+}
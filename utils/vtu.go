@@ -0,0 +1,612 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Размер блока при сжатии массивов zlib (как в VTK)
+const vtu_block_size = 1 << 15
+
+// Размеры типов данных VTK XML
+var vtu_sizes = map[string]int{
+	"Int8": 1, "UInt8": 1, "Int16": 2, "UInt16": 2, "Int32": 4, "UInt32": 4,
+	"Int64": 8, "UInt64": 8, "Float32": 4, "Float64": 8,
+}
+
+type vtu_array struct {
+	Type       string `xml:"type,attr"`
+	Name       string `xml:"Name,attr"`
+	Components int    `xml:"NumberOfComponents,attr"`
+	Format     string `xml:"format,attr"`
+	Offset     int    `xml:"offset,attr"`
+	Data       string `xml:",chardata"`
+}
+
+type vtu_piece struct {
+	Points     int         `xml:"NumberOfPoints,attr"`
+	Cells      int         `xml:"NumberOfCells,attr"`
+	Coords     vtu_array   `xml:"Points>DataArray"`
+	Topology   []vtu_array `xml:"Cells>DataArray"`
+	Cell_data  []vtu_array `xml:"CellData>DataArray"`
+	Point_data []vtu_array `xml:"PointData>DataArray"`
+}
+
+type vtu_file struct {
+	Type       string      `xml:"type,attr"`
+	Version    string      `xml:"version,attr"`
+	Byte_order string      `xml:"byte_order,attr"`
+	Header     string      `xml:"header_type,attr"`
+	Compressor string      `xml:"compressor,attr"`
+	Pieces     []vtu_piece `xml:"UnstructuredGrid>Piece"`
+
+	encoding string // кодировка AppendedData: raw или base64
+	appended []byte // содержимое AppendedData после символа '_'
+	offsets  []int  // начала массивов в AppendedData по возрастанию
+	order    binary.ByteOrder
+}
+
+// parse_vtu разбирает XML-часть файла .vtu и отделяет от неё блок AppendedData,
+// который в кодировке raw не является корректным XML
+func parse_vtu(data []byte) (*vtu_file, error) {
+	var f vtu_file
+	doc := data
+	if i := bytes.Index(data, []byte("<AppendedData")); i >= 0 {
+		tag := data[i:]
+		end := bytes.IndexByte(tag, '>')
+		start := bytes.IndexByte(tag, '_')
+		if end < 0 || start < end {
+			return nil, fmt.Errorf("неверный блок AppendedData")
+		}
+		f.encoding = "raw"
+		if bytes.Contains(tag[:end], []byte(`encoding="base64"`)) {
+			f.encoding = "base64"
+		}
+		f.appended = tag[start+1:]
+		if k := bytes.LastIndex(f.appended, []byte("</AppendedData>")); k >= 0 {
+			f.appended = f.appended[:k]
+		}
+		// AppendedData — последний дочерний элемент VTKFile
+		doc = append(append([]byte{}, data[:i]...), "</VTKFile>"...)
+	}
+	if err := xml.Unmarshal(doc, &f); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе XML: %v", err)
+	}
+	if f.Type != "UnstructuredGrid" {
+		return nil, fmt.Errorf("неподдерживаемый тип данных VTK XML %q", f.Type)
+	}
+	switch f.Byte_order {
+	case "", "LittleEndian":
+		f.order = binary.LittleEndian
+	case "BigEndian":
+		f.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("неизвестный порядок байтов %q", f.Byte_order)
+	}
+	switch f.Header {
+	case "":
+		f.Header = "UInt32"
+	case "UInt32", "UInt64":
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип заголовка %q", f.Header)
+	}
+	if f.Compressor != "" && f.Compressor != "vtkZLibDataCompressor" {
+		return nil, fmt.Errorf("неподдерживаемый метод сжатия %q", f.Compressor)
+	}
+	for _, p := range f.Pieces {
+		for _, a := range append(append(append([]vtu_array{p.Coords}, p.Topology...), p.Cell_data...), p.Point_data...) {
+			if a.Format == "appended" {
+				f.offsets = append(f.offsets, a.Offset)
+			}
+		}
+	}
+	sort.Ints(f.offsets)
+	return &f, nil
+}
+
+// decode_base64 декодирует base64, в том числе несколько подряд записанных
+// потоков с выравниванием '=' (VTK кодирует заголовок сжатого массива отдельно)
+func decode_base64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	var out []byte
+	for len(s) > 0 {
+		n := len(s)
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			n = (i/4 + 1) * 4
+			if n > len(s) {
+				n = len(s)
+			}
+		}
+		b, err := base64.StdEncoding.DecodeString(s[:n])
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при декодировании base64: %v", err)
+		}
+		out = append(out, b...)
+		s = s[n:]
+	}
+	return out, nil
+}
+
+// header_size возвращает размер одного числа заголовка двоичного массива
+func (f *vtu_file) header_size() int {
+	if f.Header == "UInt64" {
+		return 8
+	}
+	return 4
+}
+
+func (f *vtu_file) header_value(b []byte) int {
+	if f.Header == "UInt64" {
+		return int(f.order.Uint64(b))
+	}
+	return int(f.order.Uint32(b))
+}
+
+// unpack возвращает данные двоичного массива без заголовка, распаковывая блоки zlib
+func (f *vtu_file) unpack(b []byte) ([]byte, error) {
+	hs := f.header_size()
+	if len(b) < hs {
+		return nil, fmt.Errorf("неполный заголовок двоичного массива")
+	}
+	if f.Compressor == "" {
+		n := f.header_value(b)
+		if n < 0 || hs+n > len(b) {
+			return nil, fmt.Errorf("двоичный массив короче заявленных %d байт", n)
+		}
+		return b[hs : hs+n], nil
+	}
+	nb := f.header_value(b)
+	if nb < 0 || len(b) < (3+nb)*hs {
+		return nil, fmt.Errorf("неполный заголовок сжатого массива")
+	}
+	bs := f.header_value(b[hs:])
+	last := f.header_value(b[2*hs:])
+	pos := (3 + nb) * hs
+	var data []byte
+	for k := 0; k < nb; k++ {
+		cs := f.header_value(b[(3+k)*hs:])
+		if cs < 0 || pos+cs > len(b) {
+			return nil, fmt.Errorf("сжатый блок %d выходит за границы массива", k)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(b[pos : pos+cs]))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при распаковке блока %d: %v", k, err)
+		}
+		block, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при распаковке блока %d: %v", k, err)
+		}
+		size := bs
+		if k == nb-1 && last != 0 {
+			size = last
+		}
+		if len(block) != size {
+			return nil, fmt.Errorf("блок %d содержит %d байт вместо %d", k, len(block), size)
+		}
+		data = append(data, block...)
+		pos += cs
+	}
+	return data, nil
+}
+
+// Верхняя граница размеров массивов, защищающая от испорченных заголовков
+const vtu_max_count = 1 << 30
+
+// values возвращает значения массива a; n — ожидаемое число значений
+func (f *vtu_file) values(a vtu_array, n int) ([]float64, error) {
+	size, ok := vtu_sizes[a.Type]
+	if !ok {
+		return nil, fmt.Errorf("массив %s: неподдерживаемый тип %q", a.Name, a.Type)
+	}
+	if n < 0 || n > vtu_max_count {
+		return nil, fmt.Errorf("массив %s: неверное число значений %d", a.Name, n)
+	}
+	v := make([]float64, n)
+	var raw []byte
+	switch a.Format {
+	case "ascii":
+		words := strings.Fields(a.Data)
+		if len(words) != n {
+			return nil, fmt.Errorf("массив %s содержит %d значений вместо %d", a.Name, len(words), n)
+		}
+		for i, w := range words {
+			x, err := strconv.ParseFloat(w, 64)
+			if err != nil {
+				return nil, fmt.Errorf("массив %s: ошибка при парсинге числа: %v", a.Name, err)
+			}
+			v[i] = x
+		}
+		return v, nil
+	case "binary":
+		b, err := decode_base64(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("массив %s: %v", a.Name, err)
+		}
+		if raw, err = f.unpack(b); err != nil {
+			return nil, fmt.Errorf("массив %s: %v", a.Name, err)
+		}
+	case "appended":
+		if a.Offset < 0 || a.Offset > len(f.appended) {
+			return nil, fmt.Errorf("массив %s: смещение %d вне блока AppendedData", a.Name, a.Offset)
+		}
+		b := f.appended[a.Offset:]
+		if f.encoding == "base64" {
+			// В base64 массив заканчивается там, где начинается следующий
+			k := sort.SearchInts(f.offsets, a.Offset+1)
+			if k < len(f.offsets) {
+				b = f.appended[a.Offset:f.offsets[k]]
+			}
+			var err error
+			if b, err = decode_base64(string(b)); err != nil {
+				return nil, fmt.Errorf("массив %s: %v", a.Name, err)
+			}
+		}
+		var err error
+		if raw, err = f.unpack(b); err != nil {
+			return nil, fmt.Errorf("массив %s: %v", a.Name, err)
+		}
+	default:
+		return nil, fmt.Errorf("массив %s: неизвестный формат %q", a.Name, a.Format)
+	}
+	if len(raw) != n*size {
+		return nil, fmt.Errorf("массив %s содержит %d байт вместо %d", a.Name, len(raw), n*size)
+	}
+	o := f.order
+	for i := range v {
+		b := raw[i*size:]
+		switch a.Type {
+		case "Int8":
+			v[i] = float64(int8(b[0]))
+		case "UInt8":
+			v[i] = float64(b[0])
+		case "Int16":
+			v[i] = float64(int16(o.Uint16(b)))
+		case "UInt16":
+			v[i] = float64(o.Uint16(b))
+		case "Int32":
+			v[i] = float64(int32(o.Uint32(b)))
+		case "UInt32":
+			v[i] = float64(o.Uint32(b))
+		case "Int64":
+			v[i] = float64(int64(o.Uint64(b)))
+		case "UInt64":
+			v[i] = float64(o.Uint64(b))
+		case "Float32":
+			v[i] = float64(math.Float32frombits(o.Uint32(b)))
+		case "Float64":
+			v[i] = math.Float64frombits(o.Uint64(b))
+		}
+	}
+	return v, nil
+}
+
+// ints возвращает значения целочисленного массива
+func (f *vtu_file) ints(a vtu_array, n int) ([]int, error) {
+	v, err := f.values(a, n)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, n)
+	for i, x := range v {
+		if x != math.Trunc(x) {
+			return nil, fmt.Errorf("массив %s: ожидалось целое число, а не %g", a.Name, x)
+		}
+		res[i] = int(x)
+	}
+	return res, nil
+}
+
+// Read_vtu читает сетку из файла VTK XML UnstructuredGrid (.vtu). Массивы
+// могут быть записаны текстом (ascii), в base64 (binary) или в блоке
+// AppendedData (raw или base64), в том числе со сжатием zlib.
+// Несколько частей (Piece) объединяются в одну сетку.
+func Read_vtu(r io.Reader) (VTKGrid, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return VTKGrid{}, fmt.Errorf("ошибка при чтении файла: %v", err)
+	}
+	f, err := parse_vtu(data)
+	if err != nil {
+		return VTKGrid{}, err
+	}
+	grid := VTKGrid{Title: f.Version, DatasetType: "UNSTRUCTURED_GRID"}
+	for k, p := range f.Pieces {
+		if k == 0 {
+			grid.Format = p.Coords.Format
+		}
+		if p.Coords.Components != 0 && p.Coords.Components != 3 {
+			return VTKGrid{}, fmt.Errorf("точки должны иметь 3 компоненты, а не %d", p.Coords.Components)
+		}
+		if p.Points < 0 || p.Points > vtu_max_count || p.Cells < 0 || p.Cells > vtu_max_count {
+			return VTKGrid{}, fmt.Errorf("неверный размер части %d: %d точек, %d ячеек", k, p.Points, p.Cells)
+		}
+		coords, err := f.values(p.Coords, 3*p.Points)
+		if err != nil {
+			return VTKGrid{}, err
+		}
+		topology := make(map[string]vtu_array)
+		for _, a := range p.Topology {
+			topology[a.Name] = a
+		}
+		for _, name := range []string{"connectivity", "offsets", "types"} {
+			if _, ok := topology[name]; !ok {
+				return VTKGrid{}, fmt.Errorf("в секции Cells нет массива %s", name)
+			}
+		}
+		offsets, err := f.ints(topology["offsets"], p.Cells)
+		if err != nil {
+			return VTKGrid{}, err
+		}
+		size := 0
+		if p.Cells > 0 {
+			size = offsets[p.Cells-1]
+		}
+		if size < 0 || size > vtu_max_count {
+			return VTKGrid{}, fmt.Errorf("неверный размер массива connectivity: %d", size)
+		}
+		conn, err := f.ints(topology["connectivity"], size)
+		if err != nil {
+			return VTKGrid{}, err
+		}
+		types, err := f.ints(topology["types"], p.Cells)
+		if err != nil {
+			return VTKGrid{}, err
+		}
+
+		base := len(grid.Points)
+		for i := 0; i < p.Points; i++ {
+			grid.Points = append(grid.Points, Point{X: coords[3*i], Y: coords[3*i+1], Z: coords[3*i+2]})
+		}
+		start := 0
+		for i := 0; i < p.Cells; i++ {
+			if offsets[i] < start || offsets[i] > size {
+				return VTKGrid{}, fmt.Errorf("неверные смещения ячейки %d", i)
+			}
+			indices := make([]int, offsets[i]-start)
+			for j := range indices {
+				indices[j] = base + conn[start+j]
+			}
+			grid.Cells = append(grid.Cells, Cell{Indices: indices})
+			start = offsets[i]
+		}
+		grid.CellTypes = append(grid.CellTypes, types...)
+
+		if err := f.read_fields(&grid, p.Cell_data, p.Cells, false, k == 0); err != nil {
+			return VTKGrid{}, err
+		}
+		if err := f.read_fields(&grid, p.Point_data, p.Points, true, k == 0); err != nil {
+			return VTKGrid{}, err
+		}
+	}
+	for _, field := range grid.Fields {
+		n := len(grid.Cells)
+		if field.Point {
+			n = len(grid.Points)
+		}
+		if len(field.Values) != n*field.components() {
+			return VTKGrid{}, fmt.Errorf("поле %s задано не во всех кусках сетки", field.Name)
+		}
+	}
+
+	if err := grid.build_faces(); err != nil {
+		return VTKGrid{}, err
+	}
+	return grid, nil
+}
+
+// read_fields добавляет массивы CellData или PointData куска из n ячеек
+// или узлов к полям сетки; массивы следующих кусков дописываются к полям
+// с тем же именем
+func (f *vtu_file) read_fields(grid *VTKGrid, arrays []vtu_array, n int, point, first bool) error {
+	for _, a := range arrays {
+		components := a.Components
+		if components == 0 {
+			components = 1
+		}
+		values, err := f.values(a, n*components)
+		if err != nil {
+			return fmt.Errorf("ошибка при чтении поля %s: %v", a.Name, err)
+		}
+		if first {
+			grid.Fields = append(grid.Fields, Field{Name: a.Name, Values: values, Components: components, Point: point})
+			continue
+		}
+		field := grid.find_field(a.Name, point)
+		if field == nil || field.components() != components {
+			return fmt.Errorf("поле %s различается в кусках сетки", a.Name)
+		}
+		field.Values = append(field.Values, values...)
+	}
+	return nil
+}
+
+// vtu_writer формирует массивы DataArray в выбранном формате
+type vtu_writer struct {
+	format   string       // ascii, binary или appended
+	appended bytes.Buffer // данные блока AppendedData
+}
+
+// pack возвращает двоичный массив с заголовком UInt64; в формате appended
+// данные сжимаются блоками zlib
+func (vw *vtu_writer) pack(raw []byte) ([]byte, error) {
+	var out bytes.Buffer
+	le := binary.LittleEndian
+	if vw.format != "appended" {
+		binary.Write(&out, le, uint64(len(raw)))
+		out.Write(raw)
+		return out.Bytes(), nil
+	}
+	nb := (len(raw) + vtu_block_size - 1) / vtu_block_size
+	header := []uint64{uint64(nb), vtu_block_size, uint64(len(raw) % vtu_block_size)}
+	var blocks bytes.Buffer
+	for k := 0; k < nb; k++ {
+		end := (k + 1) * vtu_block_size
+		if end > len(raw) {
+			end = len(raw)
+		}
+		before := blocks.Len()
+		zw := zlib.NewWriter(&blocks)
+		if _, err := zw.Write(raw[k*vtu_block_size : end]); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		header = append(header, uint64(blocks.Len()-before))
+	}
+	binary.Write(&out, le, header)
+	out.Write(blocks.Bytes())
+	return out.Bytes(), nil
+}
+
+// array записывает элемент DataArray со значениями v типа kind
+// (Float64, Int64 или UInt8)
+func (vw *vtu_writer) array(w io.Writer, kind, name string, components int, v []float64) error {
+	attrs := fmt.Sprintf(`type="%s" Name="%s"`, kind, name)
+	if components > 1 {
+		attrs += fmt.Sprintf(` NumberOfComponents="%d"`, components)
+	}
+	if vw.format == "ascii" {
+		var sb strings.Builder
+		for i, x := range v {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(strconv.FormatFloat(x, 'g', -1, 64))
+		}
+		_, err := fmt.Fprintf(w, "<DataArray %s format=\"ascii\">%s</DataArray>\n", attrs, sb.String())
+		return err
+	}
+	raw := make([]byte, vtu_sizes[kind]*len(v))
+	le := binary.LittleEndian
+	for i, x := range v {
+		switch kind {
+		case "Float64":
+			le.PutUint64(raw[8*i:], math.Float64bits(x))
+		case "Int64":
+			le.PutUint64(raw[8*i:], uint64(int64(x)))
+		case "UInt8":
+			raw[i] = uint8(x)
+		}
+	}
+	b, err := vw.pack(raw)
+	if err != nil {
+		return fmt.Errorf("ошибка при сжатии массива %s: %v", name, err)
+	}
+	if vw.format == "binary" {
+		_, err = fmt.Fprintf(w, "<DataArray %s format=\"binary\">%s</DataArray>\n",
+			attrs, base64.StdEncoding.EncodeToString(b))
+		return err
+	}
+	_, err = fmt.Fprintf(w, "<DataArray %s format=\"appended\" offset=\"%d\"/>\n", attrs, vw.appended.Len())
+	vw.appended.Write(b)
+	return err
+}
+
+func xml_escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Write_vtu записывает сетку и поля в ячейках и узлах в формате
+// VTK XML UnstructuredGrid. format — "ascii" (текст), "binary" (base64 внутри
+// DataArray) или "appended" (сжатые zlib массивы в блоке AppendedData).
+func (grid *VTKGrid) Write_vtu(w io.Writer, format string, fields ...Field) error {
+	vw := vtu_writer{format: format}
+	switch format {
+	case "ascii", "binary", "appended":
+	default:
+		return fmt.Errorf("неизвестный формат %q", format)
+	}
+	if err := grid.check_fields(fields); err != nil {
+		return err
+	}
+
+	// Элементы Piece собираются отдельно: смещения в AppendedData
+	// известны только после записи всех массивов
+	var piece bytes.Buffer
+	fmt.Fprintf(&piece, "<Piece NumberOfPoints=\"%d\" NumberOfCells=\"%d\">\n<Points>\n",
+		len(grid.Points), len(grid.Cells))
+	coords := make([]float64, 0, 3*len(grid.Points))
+	for _, p := range grid.Points {
+		coords = append(coords, p.X, p.Y, p.Z)
+	}
+	if err := vw.array(&piece, "Float64", "Points", 3, coords); err != nil {
+		return err
+	}
+	piece.WriteString("</Points>\n<Cells>\n")
+	var conn, offsets, types []float64
+	for i, c := range grid.Cells {
+		for _, n := range c.Indices {
+			conn = append(conn, float64(n))
+		}
+		offsets = append(offsets, float64(len(conn)))
+		types = append(types, float64(grid.cell_type(i)))
+	}
+	for _, a := range []struct {
+		kind, name string
+		v          []float64
+	}{{"Int64", "connectivity", conn}, {"Int64", "offsets", offsets}, {"UInt8", "types", types}} {
+		if err := vw.array(&piece, a.kind, a.name, 1, a.v); err != nil {
+			return err
+		}
+	}
+	piece.WriteString("</Cells>\n")
+	for _, point := range []bool{false, true} {
+		selected := select_fields(fields, point)
+		if len(selected) == 0 {
+			continue
+		}
+		section := "CellData"
+		if point {
+			section = "PointData"
+		}
+		// Активные скаляры, векторы и тензоры — первые поля каждого вида
+		attrs := ""
+		active := map[int]string{1: "Scalars", 3: "Vectors", 9: "Tensors"}
+		for _, f := range selected {
+			if kind, ok := active[f.components()]; ok {
+				attrs += fmt.Sprintf(` %s="%s"`, kind, xml_escape(f.Name))
+				delete(active, f.components())
+			}
+		}
+		fmt.Fprintf(&piece, "<%s%s>\n", section, attrs)
+		for _, f := range selected {
+			if err := vw.array(&piece, "Float64", xml_escape(f.Name), f.components(), f.Values); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(&piece, "</%s>\n", section)
+	}
+	piece.WriteString("</Piece>\n")
+
+	compressor := ""
+	if format == "appended" {
+		compressor = ` compressor="vtkZLibDataCompressor"`
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "<?xml version=\"1.0\"?>\n<VTKFile type=\"UnstructuredGrid\" version=\"1.0\" "+
+		"byte_order=\"LittleEndian\" header_type=\"UInt64\"%s>\n<UnstructuredGrid>\n", compressor)
+	out.Write(piece.Bytes())
+	out.WriteString("</UnstructuredGrid>\n")
+	if format == "appended" {
+		out.WriteString("<AppendedData encoding=\"raw\">\n_")
+		out.Write(vw.appended.Bytes())
+		out.WriteString("\n</AppendedData>\n")
+	}
+	out.WriteString("</VTKFile>\n")
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("ошибка при записи в файл: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVtuRoundTrip(t *testing.T) {
+	for _, name := range []string{"grid.vtk", "tetragrid_2000.vtk"} {
+		legacy, err := Grid("../test_data/" + name)
+		if err != nil {
+			t.Fatalf("Grid(%s) failed: %v", name, err)
+		}
+		field := Field{Name: "u", Values: make([]float64, len(legacy.Cells))}
+		for i := range field.Values {
+			field.Values[i] = math.Sin(float64(i)) / 3
+		}
+		for _, format := range []string{"ascii", "binary", "appended"} {
+			var buf bytes.Buffer
+			if err := legacy.Write_vtu(&buf, format, field); err != nil {
+				t.Fatalf("Write_vtu(%s) failed: %v", format, err)
+			}
+			f, err := parse_vtu(buf.Bytes())
+			if err != nil {
+				t.Fatalf("%s %s: parse_vtu failed: %v", name, format, err)
+			}
+			values, err := f.values(f.Pieces[0].Cell_data[0], len(legacy.Cells))
+			if err != nil {
+				t.Fatalf("%s %s: reading field failed: %v", name, format, err)
+			}
+			if !reflect.DeepEqual(values, field.Values) {
+				t.Errorf("%s %s: field values differ after round trip", name, format)
+			}
+			grid, err := Read_vtu(&buf)
+			if err != nil {
+				t.Fatalf("%s %s: Read_vtu failed: %v", name, format, err)
+			}
+			if grid.Format != format {
+				t.Errorf("%s: format %q, expected %q", name, grid.Format, format)
+			}
+			sameGrid(t, name+" "+format, grid, legacy)
+			if u := grid.Cell_field("u"); len(grid.Fields) != 1 || u == nil || !reflect.DeepEqual(u.Values, field.Values) {
+				t.Errorf("%s %s: cell field u differs after Read_vtu", name, format)
+			}
+		}
+	}
+}
+
+func TestVtuCompressionShrinksOutput(t *testing.T) {
+	grid, err := Grid("../test_data/tetragrid_2000.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	var ascii, appended bytes.Buffer
+	if err := grid.Write_vtu(&ascii, "ascii"); err != nil {
+		t.Fatalf("Write_vtu failed: %v", err)
+	}
+	if err := grid.Write_vtu(&appended, "appended"); err != nil {
+		t.Fatalf("Write_vtu failed: %v", err)
+	}
+	if appended.Len() >= ascii.Len() {
+		t.Errorf("Compressed file (%d bytes) is not smaller than ascii (%d bytes)", appended.Len(), ascii.Len())
+	}
+}
+
+// vtkBinary encodes an array the way VTK does with a UInt32 header:
+// the compression header and the zlib blocks as separate base64 streams
+func vtkBinary(data interface{}) string {
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, data)
+	var block bytes.Buffer
+	zw := zlib.NewWriter(&block)
+	zw.Write(raw.Bytes())
+	zw.Close()
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, []uint32{1, 1 << 15, uint32(raw.Len()), uint32(block.Len())})
+	return base64.StdEncoding.EncodeToString(header.Bytes()) + base64.StdEncoding.EncodeToString(block.Bytes())
+}
+
+func TestVtuCompressedInline(t *testing.T) {
+	points := vtkBinary([]float32{0, 0, 0, 1, 0, 0, 0.5, 1, 0, 1.5, 1, 0})
+	conn := vtkBinary([]int32{0, 1, 2, 1, 3, 2})
+	offsets := vtkBinary([]int32{3, 6})
+	types := vtkBinary([]uint8{VTKTriangle, VTKTriangle})
+	doc := fmt.Sprintf(`<?xml version="1.0"?>
+<VTKFile type="UnstructuredGrid" version="0.1" byte_order="LittleEndian" compressor="vtkZLibDataCompressor">
+  <UnstructuredGrid>
+    <Piece NumberOfPoints="4" NumberOfCells="2">
+      <Points>
+        <DataArray type="Float32" NumberOfComponents="3" format="binary">
+          %s
+        </DataArray>
+      </Points>
+      <Cells>
+        <DataArray type="Int32" Name="connectivity" format="binary">%s</DataArray>
+        <DataArray type="Int32" Name="offsets" format="binary">%s</DataArray>
+        <DataArray type="UInt8" Name="types" format="binary">%s</DataArray>
+      </Cells>
+    </Piece>
+  </UnstructuredGrid>
+</VTKFile>
+`, points, conn, offsets, types)
+	grid, err := Read_vtu(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Read_vtu failed: %v", err)
+	}
+	want, err := Grid("../test_data/3.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	sameGrid(t, "compressed inline", grid, want)
+}
+
+func TestVtuGridByExtension(t *testing.T) {
+	legacy, err := Grid("../test_data/3.vtk")
+	if err != nil {
+		t.Fatalf("Grid failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "3.vtu")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Write_vtu(file, "appended"); err != nil {
+		t.Fatalf("Write_vtu failed: %v", err)
+	}
+	file.Close()
+	grid, err := Grid(path)
+	if err != nil {
+		t.Fatalf("Grid(%s) failed: %v", path, err)
+	}
+	sameGrid(t, "3.vtu", grid, legacy)
+}
+
+func TestVtuNodeIndexOutOfRange(t *testing.T) {
+	piece := func(points, cells, conn, offsets string) string {
+		return `<?xml version="1.0"?>
+<VTKFile type="UnstructuredGrid" version="0.1" byte_order="LittleEndian">
+  <UnstructuredGrid>
+    <Piece NumberOfPoints="` + points + `" NumberOfCells="` + cells + `">
+      <Points>
+        <DataArray type="Float32" NumberOfComponents="3" format="ascii">0 0 0 1 0 0 0 1 0</DataArray>
+      </Points>
+      <Cells>
+        <DataArray type="Int32" Name="connectivity" format="ascii">` + conn + `</DataArray>
+        <DataArray type="Int32" Name="offsets" format="ascii">` + offsets + `</DataArray>
+        <DataArray type="UInt8" Name="types" format="ascii">5</DataArray>
+      </Cells>
+    </Piece>
+  </UnstructuredGrid>
+</VTKFile>
+`
+	}
+	if _, err := Read_vtu(strings.NewReader(piece("3", "1", "0 1 2", "3"))); err != nil {
+		t.Fatalf("Read_vtu failed: %v", err)
+	}
+	cases := []struct {
+		name                         string
+		points, cells, conn, offsets string
+	}{
+		{"node index out of range", "3", "1", "0 1 7", "3"},
+		{"negative NumberOfPoints", "-1", "1", "0 1 2", "3"},
+		{"negative NumberOfCells", "3", "-1", "0 1 2", "3"},
+		{"oversized NumberOfPoints", "4294967296", "1", "0 1 2", "3"},
+		{"negative last offset", "3", "1", "0 1 2", "-3"},
+	}
+	for _, c := range cases {
+		if _, err := Read_vtu(strings.NewReader(piece(c.points, c.cells, c.conn, c.offsets))); err == nil {
+			t.Errorf("Expected an error for a %s", c.name)
+		}
+	}
+}